@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Everything that depends on the current time asks clock rather than calling
+// time.Now, so tests can control it. In dev mode clock is a devClock, which
+// POST /api/dev/clock can freeze or move forward so end-to-end tests can
+// check next week's instances without waiting for next week. Auth tokens
+// keep using the real time.
+
+// Clock tells the time
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the real time
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+var clock Clock = systemClock{}
+
+// devClock is a clock that can be frozen at a time or offset from the real
+// time
+type devClock struct {
+	mu     sync.Mutex
+	frozen *time.Time
+	offset time.Duration
+}
+
+func (c *devClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.frozen != nil {
+		return *c.frozen
+	}
+	return time.Now().Add(c.offset)
+}
+
+// freeze stops the clock at t
+func (c *devClock) freeze(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.frozen = &t
+}
+
+// unfreeze lets the clock run on from its current time
+func (c *devClock) unfreeze() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.frozen != nil {
+		c.offset = time.Until(*c.frozen)
+		c.frozen = nil
+	}
+}
+
+// advance moves the clock forward by d, or back if d is negative
+func (c *devClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.frozen != nil {
+		t := c.frozen.Add(d)
+		c.frozen = &t
+		return
+	}
+	c.offset += d
+}
+
+// reset returns the clock to the real time
+func (c *devClock) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.frozen = nil
+	c.offset = 0
+}
+
+// ClockStatus is the response of the dev clock endpoints
+type ClockStatus struct {
+	Now     time.Time `json:"now"`
+	Frozen  bool      `json:"frozen"`
+	Offset  string    `json:"offset"`            // How far the clock is from the real time
+	Created *int      `json:"created,omitempty"` // Instances created by runScheduler
+}
+
+func (c *devClock) status() ClockStatus {
+	now := c.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return ClockStatus{
+		Now:    now,
+		Frozen: c.frozen != nil,
+		Offset: time.Until(now).Round(time.Second).String(),
+	}
+}
+
+// ClockRequest changes the dev clock. Reset is applied first, then Freeze or
+// Unfreeze, then Advance; RunScheduler then runs the recurrence scheduler
+// once at the new time, even if it is disabled.
+type ClockRequest struct {
+	Reset        bool       `json:"reset,omitempty"`
+	Freeze       *time.Time `json:"freeze,omitempty"`
+	Unfreeze     bool       `json:"unfreeze,omitempty"`
+	Advance      string     `json:"advance,omitempty"` // Go duration, e.g. "168h"
+	RunScheduler bool       `json:"runScheduler,omitempty"`
+}
+
+// devClockHandlers returns the handlers for GET and POST /api/dev/clock
+func devClockHandlers(c *devClock) (get, set http.HandlerFunc) {
+	get = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(c.status())
+	}
+
+	set = func(w http.ResponseWriter, r *http.Request) {
+		var request ClockRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if request.Freeze != nil && request.Unfreeze {
+			http.Error(w, "freeze and unfreeze cannot be combined", http.StatusBadRequest)
+			return
+		}
+		var advance time.Duration
+		if request.Advance != "" {
+			var err error
+			if advance, err = time.ParseDuration(request.Advance); err != nil {
+				http.Error(w, fmt.Sprintf("Invalid advance: %v", err), http.StatusBadRequest)
+				return
+			}
+		}
+
+		if request.Reset {
+			c.reset()
+		}
+		switch {
+		case request.Freeze != nil:
+			c.freeze(*request.Freeze)
+		case request.Unfreeze:
+			c.unfreeze()
+		}
+		c.advance(advance)
+
+		status := c.status()
+		if request.RunScheduler {
+			created, err := scheduler.RunNow()
+			if err != nil {
+				http.Error(w, "Scheduler run failed: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			status.Created = &created
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status)
+	}
+	return get, set
+}
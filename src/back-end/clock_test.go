@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDevClock(t *testing.T) {
+	c := &devClock{}
+	if d := time.Since(c.Now()); d < 0 || d > time.Second {
+		t.Fatalf("new dev clock is %v from the real time, want none", d)
+	}
+
+	frozen := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	c.freeze(frozen)
+	c.advance(7 * 24 * time.Hour)
+	want := frozen.AddDate(0, 0, 7)
+	if got := c.Now(); !got.Equal(want) {
+		t.Fatalf("frozen and advanced clock = %v, want %v", got, want)
+	}
+
+	// Unfreezing carries on from the frozen time
+	c.unfreeze()
+	if got := c.Now(); got.Before(want) || got.Sub(want) > time.Second {
+		t.Errorf("unfrozen clock = %v, want just after %v", got, want)
+	}
+	if status := c.status(); status.Frozen {
+		t.Errorf("status after unfreezing = %+v, want not frozen", status)
+	}
+
+	c.reset()
+	if d := time.Since(c.Now()); d < 0 || d > time.Second {
+		t.Errorf("reset clock is %v from the real time, want none", d)
+	}
+}
+
+func TestSchedulerRunsAtClockTime(t *testing.T) {
+	c := &devClock{}
+	clock = c
+	t.Cleanup(func() { clock = systemClock{} })
+
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	c.freeze(start)
+	s := newMemoryStore()
+	def, _ := createDailyDefinition(t, s, start.Add(time.Hour))
+	sc := newRecurrenceScheduler(s, 0, 24*time.Hour)
+
+	// A week later the scheduler catches up to the clock, not the real time
+	c.advance(7 * 24 * time.Hour)
+	if _, err := sc.RunNow(); err != nil {
+		t.Fatalf("RunNow: %v", err)
+	}
+
+	latest := latestInstanceDueDate(instancesOf(t, s, def.ID), def.ID, time.Time{})
+	if want := start.AddDate(0, 0, 7).Add(time.Hour); !latest.Equal(want) {
+		t.Errorf("latest instance due %v, want %v", latest, want)
+	}
+}
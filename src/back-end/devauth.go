@@ -0,0 +1,535 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"html/template"
+	"log"
+	"math/big"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// In dev mode the backend is its own mock OpenID Connect provider. Its tokens
+// are signed with an asymmetric key, RS256 for an RSA key or ES256 for a
+// P-256 one, whose public half is published at /api/auth/dev/jwks, and they
+// are verified by the same go-oidc verifier that checks Entra ID tokens in
+// production. The key is generated on startup unless DEV_AUTH_KEY_FILE names
+// a PEM private key, which keeps tokens valid across restarts.
+//
+// Sign-in is the authorization code flow of a public client: the authorize
+// endpoint issues a single-use code bound to the client, redirect URI, PKCE
+// challenge (S256 only) and nonce, and the token endpoint exchanges it for
+// tokens whose iss and aud are checked like Entra ID's.
+
+const (
+	devIssuer       = "http://localhost:8080" // The mock provider's issuer, matching the authority in /api/auth/config
+	devClientID     = "dev-client-id"         // The only client the mock provider knows
+	devCodeLifetime = 5 * time.Minute         // How long an authorization code can be redeemed
+	devTokenExpiry  = 24 * time.Hour
+)
+
+// devSigningKey signs dev mode tokens
+type devSigningKey struct {
+	id      string // Published as the JWK "kid" and set in each token's header
+	method  jwt.SigningMethod
+	private crypto.Signer
+}
+
+// loadDevSigningKey reads the PEM private key at path, or generates an RSA
+// key if path is empty
+func loadDevSigningKey(path string) (*devSigningKey, error) {
+	if path == "" {
+		private, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate signing key: %w", err)
+		}
+		return newDevSigningKey(private)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not a PEM file", path)
+	}
+	var private any
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		private, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T", private)
+	}
+	return newDevSigningKey(signer)
+}
+
+// newDevSigningKey returns a signing key for an RSA or P-256 private key
+func newDevSigningKey(private crypto.Signer) (*devSigningKey, error) {
+	var method jwt.SigningMethod
+	switch key := private.(type) {
+	case *rsa.PrivateKey:
+		method = jwt.SigningMethodRS256
+	case *ecdsa.PrivateKey:
+		if key.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("unsupported curve %s: use P-256", key.Curve.Params().Name)
+		}
+		method = jwt.SigningMethodES256
+	default:
+		return nil, fmt.Errorf("unsupported key type %T: use RSA or P-256", private)
+	}
+
+	// Identify the key by a hash of its public half
+	der, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(der)
+	return &devSigningKey{
+		id:      base64.RawURLEncoding.EncodeToString(hash[:12]),
+		method:  method,
+		private: private,
+	}, nil
+}
+
+// sign returns a token with the given claims signed by the key
+func (k *devSigningKey) sign(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(k.method, claims)
+	token.Header["kid"] = k.id
+	return token.SignedString(k.private)
+}
+
+// verifier returns a verifier for tokens signed by the key
+func (k *devSigningKey) verifier() *oidc.IDTokenVerifier {
+	keySet := &oidc.StaticKeySet{PublicKeys: []crypto.PublicKey{k.private.Public()}}
+	return oidc.NewVerifier(devIssuer, keySet, &oidc.Config{
+		ClientID:             devClientID,
+		SupportedSigningAlgs: []string{k.method.Alg()},
+	})
+}
+
+// JSONWebKey is the public half of a signing key, as published in a JWKS
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`   // RSA modulus
+	E   string `json:"e,omitempty"`   // RSA exponent
+	Crv string `json:"crv,omitempty"` // EC curve
+	X   string `json:"x,omitempty"`   // EC point
+	Y   string `json:"y,omitempty"`
+}
+
+// jwk returns the key's public half as a JSON Web Key
+func (k *devSigningKey) jwk() (JSONWebKey, error) {
+	jwk := JSONWebKey{Use: "sig", Alg: k.method.Alg(), Kid: k.id}
+	switch public := k.private.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case *ecdsa.PublicKey:
+		point, err := public.ECDH()
+		if err != nil {
+			return JSONWebKey{}, err
+		}
+		// An uncompressed point is 0x04 followed by X and Y
+		xy := point.Bytes()[1:]
+		jwk.Kty = "EC"
+		jwk.Crv = "P-256"
+		jwk.X = base64.RawURLEncoding.EncodeToString(xy[:len(xy)/2])
+		jwk.Y = base64.RawURLEncoding.EncodeToString(xy[len(xy)/2:])
+	}
+	return jwk, nil
+}
+
+// devJWKS publishes the dev signing key as a JSON Web Key Set
+func devJWKS(w http.ResponseWriter, r *http.Request) {
+	jwk, err := authConfig.devKey.jwk()
+	if err != nil {
+		http.Error(w, "Failed to encode signing key", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]JSONWebKey{"keys": {jwk}})
+}
+
+// devAuthCode is what an authorization code was issued for
+type devAuthCode struct {
+	user          MockUser
+	clientID      string
+	redirectURI   string
+	codeChallenge string // BASE64URL(SHA256(code_verifier))
+	nonce         string
+	expires       time.Time
+}
+
+// devAuthCodes holds the authorization codes not yet redeemed
+var devAuthCodes = struct {
+	sync.Mutex
+	codes map[string]*devAuthCode
+}{codes: make(map[string]*devAuthCode)}
+
+// issueDevAuthCode returns a new authorization code for grant
+func issueDevAuthCode(grant *devAuthCode) string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	code := base64.RawURLEncoding.EncodeToString(b)
+
+	devAuthCodes.Lock()
+	defer devAuthCodes.Unlock()
+	for c, g := range devAuthCodes.codes {
+		if time.Now().After(g.expires) {
+			delete(devAuthCodes.codes, c)
+		}
+	}
+	devAuthCodes.codes[code] = grant
+	return code
+}
+
+// redeemDevAuthCode returns what code was issued for, if it has not expired,
+// and makes sure it cannot be used again
+func redeemDevAuthCode(code string) (*devAuthCode, bool) {
+	devAuthCodes.Lock()
+	defer devAuthCodes.Unlock()
+	grant, ok := devAuthCodes.codes[code]
+	delete(devAuthCodes.codes, code)
+	if !ok || time.Now().After(grant.expires) {
+		return nil, false
+	}
+	return grant, true
+}
+
+// findMockUser returns the mock user whose sub or email is hint
+func findMockUser(hint string) (MockUser, bool) {
+	for _, u := range devUsers() {
+		if u.Sub == hint || strings.EqualFold(u.Email, hint) {
+			return u, true
+		}
+	}
+	return MockUser{}, false
+}
+
+// Dev mode OAuth2 endpoints
+
+// devAuthorize shows a page for choosing who to sign in as (GET), then issues
+// an authorization code for the choice and redirects back to the client
+// (POST). The page lists the mock users and accepts any other email; an OIDC
+// login_hint (a mock user's sub or email) picks the user focused by default.
+func devAuthorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	// Problems with the client or redirect URI can't be sent to the client
+	if query.Get("client_id") != devClientID {
+		http.Error(w, "Unknown client_id", http.StatusBadRequest)
+		return
+	}
+	redirectURI, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || !redirectURI.IsAbs() || (redirectURI.Scheme != "http" && redirectURI.Scheme != "https") || redirectURI.Fragment != "" {
+		http.Error(w, "redirect_uri must be an absolute http(s) URL", http.StatusBadRequest)
+		return
+	}
+
+	redirect := func(params url.Values) {
+		params.Set("state", query.Get("state"))
+		target := *redirectURI
+		values := target.Query()
+		for key := range params {
+			values.Set(key, params.Get(key))
+		}
+		target.RawQuery = values.Encode()
+		http.Redirect(w, r, target.String(), http.StatusFound)
+	}
+	fail := func(code, description string) {
+		redirect(url.Values{"error": {code}, "error_description": {description}})
+	}
+
+	if query.Get("response_type") != "code" {
+		fail("unsupported_response_type", "response_type must be code")
+		return
+	}
+	if query.Get("code_challenge") == "" || query.Get("code_challenge_method") != "S256" {
+		fail("invalid_request", "PKCE with code_challenge_method S256 is required")
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		renderDevUserPicker(w, r.URL.RequestURI(), query.Get("login_hint"))
+		return
+	}
+
+	// The page posts the user it was given back to this URL
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	var user MockUser
+	switch {
+	case r.PostFormValue("cancel") != "":
+		fail("access_denied", "Sign-in was cancelled")
+		return
+	case r.PostFormValue("user") != "":
+		var ok bool
+		if user, ok = findMockUser(r.PostFormValue("user")); !ok {
+			http.Error(w, "Unknown user", http.StatusBadRequest)
+			return
+		}
+	default:
+		if user, err = customMockUser(r.PostFormValue("email")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	code := issueDevAuthCode(&devAuthCode{
+		user:          user,
+		clientID:      devClientID,
+		redirectURI:   redirectURI.String(),
+		codeChallenge: query.Get("code_challenge"),
+		nonce:         query.Get("nonce"),
+		expires:       time.Now().Add(devCodeLifetime),
+	})
+	redirect(url.Values{"code": {code}})
+}
+
+// customMockUser returns a user for an email that is not a mock user's, named
+// after its local part. A mock user's email gives that mock user.
+func customMockUser(email string) (MockUser, error) {
+	email = strings.TrimSpace(email)
+	if user, ok := findMockUser(email); ok {
+		return user, nil
+	}
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return MockUser{}, fmt.Errorf("%q is not an email address", email)
+	}
+	name, _, _ := strings.Cut(email, "@")
+	return MockUser{Email: email, Name: name, Sub: email}, nil
+}
+
+// devUserPicker is the page devAuthorize shows. Each form posts back to the
+// authorization request's URL, which carries its parameters.
+var devUserPicker = template.Must(template.New("picker").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Sign in - fuzzy-fishstick (development)</title>
+<style>
+  body { font-family: system-ui, sans-serif; background: #f5f5f5; display: flex; justify-content: center; padding-top: 10vh; }
+  main { background: white; border-radius: 8px; box-shadow: 0 2px 8px rgba(0, 0, 0, 0.1); padding: 2rem; width: 22rem; }
+  h1 { font-size: 1.4rem; margin-top: 0; }
+  form { display: flex; flex-direction: column; gap: 0.5rem; margin-bottom: 1.5rem; }
+  button { cursor: pointer; font: inherit; padding: 0.6rem; border: 1px solid #ccc; border-radius: 4px; background: white; text-align: left; }
+  button:hover, button:focus { border-color: #646cff; }
+  .user small { display: block; color: #666; }
+  input { font: inherit; padding: 0.6rem; border: 1px solid #ccc; border-radius: 4px; }
+  .cancel { text-align: center; color: #666; }
+</style>
+</head>
+<body>
+<main>
+  <h1>Sign in (development mode)</h1>
+  <form method="post" action="{{.Action}}">
+    {{range .Users}}<button class="user" type="submit" name="user" value="{{.Sub}}"{{if .Hinted}} autofocus{{end}}>{{.Name}}<small>{{.Email}}</small></button>
+    {{end}}
+  </form>
+  <form method="post" action="{{.Action}}">
+    <label for="email">Or sign in with another email</label>
+    <input id="email" type="email" name="email" placeholder="someone@example.com" value="{{.Email}}" required>
+    <button type="submit">Sign in</button>
+  </form>
+  <form method="post" action="{{.Action}}">
+    <button class="cancel" type="submit" name="cancel" value="1">Cancel</button>
+  </form>
+</main>
+</body>
+</html>
+`))
+
+// renderDevUserPicker writes the page for choosing who to sign in as, whose
+// forms post to action
+func renderDevUserPicker(w http.ResponseWriter, action, loginHint string) {
+	type pickerUser struct {
+		MockUser
+		Hinted bool
+	}
+	data := struct {
+		Action string
+		Users  []pickerUser
+		Email  string // Prefilled for a login_hint that isn't a mock user
+	}{Action: action}
+	hinted := false
+	for _, u := range devUsers() {
+		hint := loginHint != "" && (u.Sub == loginHint || strings.EqualFold(u.Email, loginHint))
+		hinted = hinted || hint
+		data.Users = append(data.Users, pickerUser{MockUser: u, Hinted: hint})
+	}
+	if !hinted && strings.Contains(loginHint, "@") {
+		data.Email = loginHint
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := devUserPicker.Execute(w, data); err != nil {
+		log.Printf("Failed to render the dev user picker: %v", err)
+	}
+}
+
+// writeOAuthError sends a token endpoint error response (RFC 6749 5.2)
+func writeOAuthError(w http.ResponseWriter, code, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]string{"error": code, "error_description": description})
+}
+
+// devToken exchanges an authorization code for an access token and an ID
+// token. The request must come from the client the code was issued to, with
+// the same redirect URI and the PKCE verifier for its challenge.
+func devToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, "invalid_request", "Invalid form data")
+		return
+	}
+
+	if r.PostFormValue("grant_type") != "authorization_code" {
+		writeOAuthError(w, "unsupported_grant_type", "grant_type must be authorization_code")
+		return
+	}
+
+	grant, ok := redeemDevAuthCode(r.PostFormValue("code"))
+	if !ok {
+		writeOAuthError(w, "invalid_grant", "The code is invalid, expired or already used")
+		return
+	}
+	if r.PostFormValue("client_id") != grant.clientID {
+		writeOAuthError(w, "invalid_grant", "The code was issued to another client")
+		return
+	}
+	if r.PostFormValue("redirect_uri") != grant.redirectURI {
+		writeOAuthError(w, "invalid_grant", "redirect_uri does not match the authorization request")
+		return
+	}
+	verifier := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	challenge := base64.RawURLEncoding.EncodeToString(verifier[:])
+	if subtle.ConstantTimeCompare([]byte(challenge), []byte(grant.codeChallenge)) != 1 {
+		writeOAuthError(w, "invalid_grant", "code_verifier does not match the code_challenge")
+		return
+	}
+
+	now := time.Now()
+	claims := grant.user.tokenClaims()
+	claims["iss"] = devIssuer
+	claims["aud"] = grant.clientID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(devTokenExpiry).Unix()
+	accessToken, err := authConfig.devKey.sign(claims)
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+	if grant.nonce != "" {
+		claims["nonce"] = grant.nonce
+	}
+	idToken, err := authConfig.devKey.sign(claims)
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(devTokenExpiry.Seconds()),
+		"id_token":     idToken,
+		"scope":        "openid profile email",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(response)
+}
+
+func devUserInfo(w http.ResponseWriter, r *http.Request) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		http.Error(w, "Missing authorization header", http.StatusUnauthorized)
+		return
+	}
+
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		http.Error(w, "Invalid authorization header", http.StatusUnauthorized)
+		return
+	}
+
+	// Signed-in users need not be mock users, so answer from the token
+	idToken, err := authConfig.oidcVerifier.Verify(r.Context(), parts[1])
+	if err != nil {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil || claims["email"] == nil {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+	for _, name := range []string{"iss", "aud", "iat", "exp", "nonce"} {
+		delete(claims, name)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(claims)
+}
+
+func devOpenIDConfig(w http.ResponseWriter, r *http.Request) {
+	baseURL := fmt.Sprintf("http://%s", r.Host)
+
+	config := map[string]interface{}{
+		"issuer":                                devIssuer,
+		"authorization_endpoint":                baseURL + "/api/auth/dev/authorize",
+		"token_endpoint":                        baseURL + "/api/auth/dev/token",
+		"userinfo_endpoint":                     baseURL + "/api/auth/dev/userinfo",
+		"jwks_uri":                              baseURL + "/api/auth/dev/jwks",
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{"authorization_code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{authConfig.devKey.method.Alg()},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"token_endpoint_auth_methods_supported": []string{"none"},
+		"scopes_supported":                      []string{"openid", "profile", "email"},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(config)
+}
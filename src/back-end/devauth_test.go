@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// useDevKey makes key the dev mode signing key until the test ends
+func useDevKey(t *testing.T, key *devSigningKey) {
+	t.Helper()
+	previous := authConfig
+	authConfig = &AuthConfig{Mode: "dev", devKey: key, oidcVerifier: key.verifier()}
+	t.Cleanup(func() { authConfig = previous })
+}
+
+func testClaims(email string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":   devIssuer,
+		"aud":   devClientID,
+		"sub":   "test",
+		"email": email,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func TestDevTokensVerifyWithOIDC(t *testing.T) {
+	key, err := loadDevSigningKey("")
+	if err != nil {
+		t.Fatalf("loadDevSigningKey: %v", err)
+	}
+	useDevKey(t, key)
+
+	token, err := key.sign(testClaims("alice@example.com"))
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if email, err := validateToken(context.Background(), token); err != nil || email != "alice@example.com" {
+		t.Errorf("validateToken = %q, %v, want alice@example.com", email, err)
+	}
+
+	// Tokens signed by another key, expired, or for another issuer or
+	// audience are rejected
+	other, err := loadDevSigningKey("")
+	if err != nil {
+		t.Fatalf("loadDevSigningKey: %v", err)
+	}
+	forged, _ := other.sign(testClaims("mallory@example.com"))
+	if _, err := validateToken(context.Background(), forged); err == nil {
+		t.Error("validateToken accepted a token signed by another key")
+	}
+	claims := testClaims("alice@example.com")
+	claims["exp"] = time.Now().Add(-time.Minute).Unix()
+	expired, _ := key.sign(claims)
+	if _, err := validateToken(context.Background(), expired); err == nil {
+		t.Error("validateToken accepted an expired token")
+	}
+	for claim, value := range map[string]string{"iss": "http://example.com", "aud": "other-client"} {
+		claims := testClaims("alice@example.com")
+		claims[claim] = value
+		token, _ := key.sign(claims)
+		if _, err := validateToken(context.Background(), token); err == nil {
+			t.Errorf("validateToken accepted a token with %s %s", claim, value)
+		}
+	}
+
+	// The JWKS publishes the key the token names
+	recorder := httptest.NewRecorder()
+	devJWKS(recorder, httptest.NewRequest("GET", "/api/auth/dev/jwks", nil))
+	var jwks struct {
+		Keys []JSONWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(recorder.Body).Decode(&jwks); err != nil {
+		t.Fatalf("decoding JWKS: %v", err)
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		t.Fatalf("ParseUnverified: %v", err)
+	}
+	if len(jwks.Keys) != 1 || jwks.Keys[0].Kid != parsed.Header["kid"] || jwks.Keys[0].Kty != "RSA" ||
+		jwks.Keys[0].Alg != "RS256" || jwks.Keys[0].E != "AQAB" {
+		t.Errorf("JWKS = %+v, want the RS256 key %v", jwks.Keys, parsed.Header["kid"])
+	}
+}
+
+func TestLoadDevSigningKeyFromFile(t *testing.T) {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalECPrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "dev-key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := loadDevSigningKey(path)
+	if err != nil {
+		t.Fatalf("loadDevSigningKey: %v", err)
+	}
+	if key.method.Alg() != "ES256" {
+		t.Errorf("alg = %s, want ES256", key.method.Alg())
+	}
+	jwk, err := key.jwk()
+	if err != nil || jwk.Kty != "EC" || jwk.Crv != "P-256" || len(jwk.X) != 43 || len(jwk.Y) != 43 {
+		t.Errorf("jwk = %+v, %v, want a P-256 key", jwk, err)
+	}
+
+	// Loading the same file again gives the same key, so tokens survive restarts
+	useDevKey(t, key)
+	token, err := key.sign(testClaims("bob@example.com"))
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	reloaded, err := loadDevSigningKey(path)
+	if err != nil {
+		t.Fatalf("loadDevSigningKey: %v", err)
+	}
+	useDevKey(t, reloaded)
+	if email, err := validateToken(context.Background(), token); err != nil || email != "bob@example.com" {
+		t.Errorf("validateToken after reloading = %q, %v", email, err)
+	}
+
+	if err := os.WriteFile(path, []byte("not a key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadDevSigningKey(path); err == nil || !strings.Contains(err.Error(), "PEM") {
+		t.Errorf("loadDevSigningKey of a non-PEM file = %v, want an error", err)
+	}
+}
+
+// authorize chooses a user on the authorization page and returns where it
+// redirects to
+func authorize(t *testing.T, params, choice url.Values) *url.URL {
+	t.Helper()
+	request := httptest.NewRequest("POST", "/api/auth/dev/authorize?"+params.Encode(), strings.NewReader(choice.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	devAuthorize(recorder, request)
+	if recorder.Code != 302 {
+		t.Fatalf("authorize status = %d, want 302: %s", recorder.Code, recorder.Body)
+	}
+	location, err := url.Parse(recorder.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return location
+}
+
+// redeem exchanges code for tokens and returns the status and response
+func redeem(form url.Values) (int, map[string]any) {
+	request := httptest.NewRequest("POST", "/api/auth/dev/token", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	devToken(recorder, request)
+	var response map[string]any
+	json.NewDecoder(recorder.Body).Decode(&response)
+	return recorder.Code, response
+}
+
+func TestDevAuthorizationCodeFlow(t *testing.T) {
+	key, err := loadDevSigningKey("")
+	if err != nil {
+		t.Fatalf("loadDevSigningKey: %v", err)
+	}
+	useDevKey(t, key)
+
+	const redirectURI = "http://localhost:5173/"
+	verifier := "a-code-verifier-that-is-long-enough-for-pkce-0123456789"
+	hash := sha256.Sum256([]byte(verifier))
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {devClientID},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {"openid profile email"},
+		"state":                 {"some-state"},
+		"nonce":                 {"some-nonce"},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(hash[:])},
+		"code_challenge_method": {"S256"},
+	}
+	bob := url.Values{"user": {"bob"}}
+	newCode := func() string {
+		t.Helper()
+		location := authorize(t, params, bob)
+		if location.Query().Get("state") != "some-state" || location.Query().Get("code") == "" {
+			t.Fatalf("authorize redirected to %s, want a code and the state", location)
+		}
+		return location.Query().Get("code")
+	}
+	tokenForm := func(code string) url.Values {
+		return url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {code},
+			"client_id":     {devClientID},
+			"redirect_uri":  {redirectURI},
+			"code_verifier": {verifier},
+		}
+	}
+
+	code := newCode()
+	status, response := redeem(tokenForm(code))
+	if status != 200 {
+		t.Fatalf("token status = %d: %v", status, response)
+	}
+	accessToken, _ := response["access_token"].(string)
+	if email, err := validateToken(context.Background(), accessToken); err != nil || email != "bob@example.com" {
+		t.Errorf("access token is for %q, %v, want bob@example.com", email, err)
+	}
+	idToken, err := key.verifier().Verify(context.Background(), response["id_token"].(string))
+	if err != nil {
+		t.Fatalf("verifying ID token: %v", err)
+	}
+	if idToken.Nonce != "some-nonce" {
+		t.Errorf("ID token nonce = %q, want some-nonce", idToken.Nonce)
+	}
+
+	// A code can only be redeemed once
+	if status, response := redeem(tokenForm(code)); status != 400 || response["error"] != "invalid_grant" {
+		t.Errorf("reusing a code = %d %v, want invalid_grant", status, response)
+	}
+
+	// ...and only with the verifier, redirect URI and client it was issued for
+	for name, change := range map[string]func(url.Values){
+		"wrong verifier":     func(f url.Values) { f.Set("code_verifier", "another-verifier") },
+		"wrong redirect_uri": func(f url.Values) { f.Set("redirect_uri", "http://localhost:5173/other") },
+		"wrong client_id":    func(f url.Values) { f.Set("client_id", "other-client") },
+	} {
+		form := tokenForm(newCode())
+		change(form)
+		if status, response := redeem(form); status != 400 || response["error"] != "invalid_grant" {
+			t.Errorf("%s = %d %v, want invalid_grant", name, status, response)
+		}
+	}
+
+	// ...before it expires
+	code = newCode()
+	devAuthCodes.Lock()
+	devAuthCodes.codes[code].expires = time.Now().Add(-time.Second)
+	devAuthCodes.Unlock()
+	if status, response := redeem(tokenForm(code)); status != 400 || response["error"] != "invalid_grant" {
+		t.Errorf("expired code = %d %v, want invalid_grant", status, response)
+	}
+
+	// Authorization requests without PKCE are refused through the redirect
+	plain := url.Values{}
+	for k, v := range params {
+		plain[k] = v
+	}
+	plain.Set("code_challenge_method", "plain")
+	if location := authorize(t, plain, bob); location.Query().Get("error") != "invalid_request" || location.Query().Get("code") != "" {
+		t.Errorf("authorize without S256 redirected to %s, want invalid_request", location)
+	}
+}
+
+func TestDevAuthorizeUserPicker(t *testing.T) {
+	key, err := loadDevSigningKey("")
+	if err != nil {
+		t.Fatalf("loadDevSigningKey: %v", err)
+	}
+	useDevKey(t, key)
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {devClientID},
+		"redirect_uri":          {"http://localhost:5173/"},
+		"state":                 {"some-state"},
+		"code_challenge":        {"challenge"},
+		"code_challenge_method": {"S256"},
+		"login_hint":            {"charlie@example.com"},
+	}
+
+	// The page lists the mock users, focusing the hinted one, and posts back
+	// to the authorization request
+	recorder := httptest.NewRecorder()
+	devAuthorize(recorder, httptest.NewRequest("GET", "/api/auth/dev/authorize?"+params.Encode(), nil))
+	page := recorder.Body.String()
+	if recorder.Code != 200 || !strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("GET authorize = %d %s, want the HTML page", recorder.Code, recorder.Header().Get("Content-Type"))
+	}
+	for _, want := range []string{
+		`value="alice"`, "Bob Jones", `value="charlie" autofocus`,
+		`action="/api/auth/dev/authorize?client_id=dev-client-id&amp;`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page does not contain %s:\n%s", want, page)
+		}
+	}
+
+	// Choosing a user or an email binds it to the code
+	for _, test := range []struct {
+		choice url.Values
+		want   MockUser
+	}{
+		{url.Values{"user": {"charlie"}}, defaultMockUsers[2]},
+		{url.Values{"email": {"dana@example.com"}}, MockUser{Email: "dana@example.com", Name: "dana", Sub: "dana@example.com"}},
+		{url.Values{"email": {"BOB@example.com"}}, defaultMockUsers[1]},
+	} {
+		code := authorize(t, params, test.choice).Query().Get("code")
+		devAuthCodes.Lock()
+		grant := devAuthCodes.codes[code]
+		devAuthCodes.Unlock()
+		if grant == nil || !reflect.DeepEqual(grant.user, test.want) {
+			t.Errorf("choosing %v issued a code for %+v, want %+v", test.choice, grant, test.want)
+		}
+	}
+
+	// Cancelling is reported to the client
+	location := authorize(t, params, url.Values{"cancel": {"1"}})
+	if location.Query().Get("error") != "access_denied" || location.Query().Get("state") != "some-state" {
+		t.Errorf("cancelling redirected to %s, want access_denied", location)
+	}
+
+	// An invalid choice is not
+	for _, choice := range []url.Values{{"user": {"mallory"}}, {"email": {"not an email"}}, {}} {
+		request := httptest.NewRequest("POST", "/api/auth/dev/authorize?"+params.Encode(), strings.NewReader(choice.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		recorder := httptest.NewRecorder()
+		devAuthorize(recorder, request)
+		if recorder.Code != 400 {
+			t.Errorf("choosing %v = %d, want 400", choice, recorder.Code)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Every store keeps a history record for each instance a recurring
+// definition has had, updated whenever the instance is saved. Deleting an
+// instance, or unlinking it from its definition, marks its record removed
+// instead of dropping it, so a definition's completion history survives its
+// instances. Streaks and the on-time rate are computed from the history.
+
+// InstanceRecord is the history of one instance of a recurring definition
+type InstanceRecord struct {
+	TodoID            int        `json:"todoId"`
+	RecurrenceID      int        `json:"recurrenceId"`
+	Title             string     `json:"title"`
+	AssignedTo        []string   `json:"assignedTo,omitempty"`
+	DueDate           *time.Time `json:"dueDate,omitempty"`
+	OriginalDueDate   *time.Time `json:"originalDueDate,omitempty"`
+	Completed         bool       `json:"completed"`
+	CompletedAt       *time.Time `json:"completedAt,omitempty"`
+	CompletedBy       string     `json:"completedBy,omitempty"`
+	Skipped           bool       `json:"skipped,omitempty"`
+	Missed            bool       `json:"missed,omitempty"`
+	MissedOccurrences int        `json:"missedOccurrences,omitempty"`
+	CreatedAt         time.Time  `json:"createdAt"`
+	RemovedAt         *time.Time `json:"removedAt,omitempty"` // When the instance was deleted or unlinked from the definition
+}
+
+// newInstanceRecord returns the history record for a recurring instance as
+// it is now
+func newInstanceRecord(todo *TodoItem) *InstanceRecord {
+	return &InstanceRecord{
+		TodoID:            todo.ID,
+		RecurrenceID:      *todo.RecurrenceID,
+		Title:             todo.Title,
+		AssignedTo:        cloneStrings(todo.AssignedTo),
+		DueDate:           todo.DueDate,
+		OriginalDueDate:   todo.OriginalDueDate,
+		Completed:         todo.Completed,
+		CompletedAt:       todo.CompletedAt,
+		CompletedBy:       todo.CompletedBy,
+		Skipped:           todo.Skipped,
+		Missed:            todo.Missed,
+		MissedOccurrences: todo.MissedOccurrences,
+		CreatedAt:         todo.CreatedAt,
+	}
+}
+
+// clone returns a copy of the record that shares no mutable state with it
+func (r *InstanceRecord) clone() *InstanceRecord {
+	c := *r
+	c.AssignedTo = cloneStrings(r.AssignedTo)
+	return &c
+}
+
+// scheduledDate returns when the record's occurrence was scheduled, before
+// any move
+func (r *InstanceRecord) scheduledDate() time.Time {
+	if r.OriginalDueDate != nil {
+		return *r.OriginalDueDate
+	}
+	if r.DueDate != nil {
+		return *r.DueDate
+	}
+	return r.CreatedAt
+}
+
+// done reports whether someone completed the instance, rather than it being
+// skipped or put aside as missed
+func (r *InstanceRecord) done() bool {
+	return r.Completed && !r.Skipped && r.CompletedAt != nil
+}
+
+// RecurringHistory is the response for GET /api/recurring/{id}/history
+type RecurringHistory struct {
+	RecurrenceID  int               `json:"recurrenceId"`
+	Instances     []*InstanceRecord `json:"instances"`             // Oldest occurrence first
+	Completed     int               `json:"completed"`             // Instances someone completed
+	Skipped       int               `json:"skipped"`               // Instances skipped
+	Missed        int               `json:"missed"`                // Instances missed, even if completed late
+	CurrentStreak int               `json:"currentStreak"`         // Occurrences completed in a row up to the latest one due
+	LongestStreak int               `json:"longestStreak"`         // Most occurrences ever completed in a row
+	OnTimeRate    *float64          `json:"onTimeRate,omitempty"`  // Share of completed or missed instances completed by their due day
+	CompletedBy   map[string]int    `json:"completedBy,omitempty"` // Completions per person
+}
+
+// summarizeHistory computes def's streaks and on-time rate from its instance
+// records.
+//
+// An occurrence extends a streak when it was completed without being missed
+// and breaks it when it was missed. Skipped, pending and removed-before-done
+// instances do not affect streaks. Due days are compared in def's time zone.
+func summarizeHistory(def *RecurringItemDefinition, records []*InstanceRecord) *RecurringHistory {
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].scheduledDate().Before(records[j].scheduledDate())
+	})
+
+	h := &RecurringHistory{RecurrenceID: def.ID, Instances: records}
+	loc := def.location()
+	streak, resolved, onTime := 0, 0, 0
+	for _, r := range records {
+		if r.Skipped {
+			h.Skipped++
+			continue
+		}
+		if r.done() {
+			h.Completed++
+			if r.CompletedBy != "" {
+				if h.CompletedBy == nil {
+					h.CompletedBy = make(map[string]int)
+				}
+				h.CompletedBy[r.CompletedBy]++
+			}
+		}
+		if r.Missed {
+			h.Missed++
+		}
+
+		if !r.done() && !r.Missed {
+			continue
+		}
+		resolved++
+		if r.Missed {
+			streak = 0
+			continue
+		}
+		streak++
+		h.LongestStreak = max(h.LongestStreak, streak)
+		if r.DueDate == nil || daysBetween(r.CompletedAt.In(loc), r.DueDate.In(loc)) >= 0 {
+			onTime++
+		}
+	}
+	h.CurrentStreak = streak
+	if resolved > 0 {
+		rate := float64(onTime) / float64(resolved)
+		h.OnTimeRate = &rate
+	}
+	return h
+}
+
+// getRecurringHistory returns a recurring definition's instance history with
+// its streaks and on-time rate
+func getRecurringHistory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	def, err := store.GetRecurringDef(id)
+	if err != nil {
+		writeStoreError(w, err, "Recurring definition not found")
+		return
+	}
+	records, err := store.ListInstanceHistory(id)
+	if err != nil {
+		writeStoreError(w, err, "Recurring definition not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summarizeHistory(def, records))
+}
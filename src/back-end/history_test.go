@@ -0,0 +1,103 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInstanceHistorySurvivesRemoval(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+			def, _ := createDailyDefinition(t, s, start.Add(time.Hour))
+			if _, err := materializeUpcoming(s, start, 3*24*time.Hour); err != nil {
+				t.Fatalf("materializeUpcoming: %v", err)
+			}
+			instances := instancesOf(t, s, def.ID)
+			if len(instances) < 3 {
+				t.Fatalf("got %d instances, want at least 3", len(instances))
+			}
+			done, deleted, unlinked := instances[0], instances[1], instances[2]
+
+			completeTodoAs(t, s, done, "alice@example.com")
+			if err := s.DeleteTodo(deleted.ID); err != nil {
+				t.Fatalf("DeleteTodo: %v", err)
+			}
+			unlinked.RecurrenceID = nil
+			unlinked.IsRecurring = false
+			if err := s.UpdateTodo(unlinked); err != nil {
+				t.Fatalf("UpdateTodo: %v", err)
+			}
+
+			records, err := s.ListInstanceHistory(def.ID)
+			if err != nil {
+				t.Fatalf("ListInstanceHistory: %v", err)
+			}
+			byTodo := make(map[int]*InstanceRecord)
+			for _, rec := range records {
+				byTodo[rec.TodoID] = rec
+			}
+
+			if rec := byTodo[done.ID]; rec == nil || !rec.Completed || rec.CompletedBy != "alice@example.com" || rec.RemovedAt != nil {
+				t.Errorf("completed instance record = %+v, want completed by alice", rec)
+			}
+			if rec := byTodo[deleted.ID]; rec == nil || rec.RemovedAt == nil || rec.DueDate == nil || !rec.DueDate.Equal(*deleted.DueDate) {
+				t.Errorf("deleted instance record = %+v, want it kept and marked removed", rec)
+			}
+			if rec := byTodo[unlinked.ID]; rec == nil || rec.RemovedAt == nil {
+				t.Errorf("unlinked instance record = %+v, want it kept and marked removed", rec)
+			}
+			if done.NextInstanceID == nil || byTodo[*done.NextInstanceID] == nil {
+				t.Errorf("no record for the instance spawned by completing %d", done.ID)
+			}
+		})
+	}
+}
+
+func TestSummarizeHistory(t *testing.T) {
+	def := &RecurringItemDefinition{
+		ID:        1,
+		Pattern:   RecurrencePattern{Frequency: "daily", Interval: 1},
+		StartDate: time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC),
+	}
+	day := func(d, hour int) *time.Time {
+		t := time.Date(2026, 3, d, hour, 0, 0, 0, time.UTC)
+		return &t
+	}
+	removed := day(9, 12)
+	records := []*InstanceRecord{
+		{TodoID: 6, DueDate: day(6, 10), Completed: true, CompletedAt: day(6, 9), CompletedBy: "alice"},
+		{TodoID: 1, DueDate: day(1, 10), Completed: true, CompletedAt: day(1, 11), CompletedBy: "alice"},
+		{TodoID: 2, DueDate: day(2, 10), Completed: true, CompletedAt: day(3, 8), CompletedBy: "bob"},
+		{TodoID: 3, DueDate: day(3, 10), Completed: true, CompletedAt: day(3, 7), Skipped: true},
+		{TodoID: 4, DueDate: day(7, 10), OriginalDueDate: day(4, 10), Completed: true, CompletedAt: day(5, 9), CompletedBy: "alice"},
+		{TodoID: 5, DueDate: day(5, 10), Missed: true},
+		{TodoID: 7, DueDate: day(7, 10)},
+		{TodoID: 8, DueDate: day(8, 10), RemovedAt: removed},
+	}
+
+	h := summarizeHistory(def, records)
+	for i, want := range []int{1, 2, 3, 4, 5, 6, 7, 8} {
+		if h.Instances[i].TodoID != want {
+			t.Fatalf("instance %d is todo %d, want %d: records are not in occurrence order", i, h.Instances[i].TodoID, want)
+		}
+	}
+	if h.Completed != 4 || h.Skipped != 1 || h.Missed != 1 {
+		t.Errorf("completed/skipped/missed = %d/%d/%d, want 4/1/1", h.Completed, h.Skipped, h.Missed)
+	}
+	if h.LongestStreak != 3 || h.CurrentStreak != 1 {
+		t.Errorf("longest/current streak = %d/%d, want 3/1", h.LongestStreak, h.CurrentStreak)
+	}
+	// 1, 4 (moved later, done before it was due) and 6 were on time; 2 was
+	// late and 5 missed
+	if h.OnTimeRate == nil || *h.OnTimeRate != 0.6 {
+		t.Errorf("OnTimeRate = %v, want 0.6", h.OnTimeRate)
+	}
+	if h.CompletedBy["alice"] != 3 || h.CompletedBy["bob"] != 1 {
+		t.Errorf("CompletedBy = %v, want alice 3 and bob 1", h.CompletedBy)
+	}
+
+	if empty := summarizeHistory(def, nil); empty.OnTimeRate != nil || empty.CurrentStreak != 0 {
+		t.Errorf("summary of no history = %+v, want no rate or streak", empty)
+	}
+}
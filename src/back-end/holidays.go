@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Holiday calendars list a region's public holidays. They are loaded at
+// startup from HOLIDAY_CALENDAR_DIR, where each region is an iCalendar file
+// (uk.ics) or a JSON list of dates (uk.json) named after it. A recurring
+// definition with a holidayRegion has its occurrences that fall on one of the
+// region's holidays moved to the next working day, or skipped if its
+// holidayRule is "skip". An occurrence moved onto a day that already has one
+// merges with it.
+
+// Holiday rules
+const (
+	holidayRuleShift = ""     // Occurrences on a holiday move to the next working day (the default)
+	holidayRuleSkip  = "skip" // Occurrences on a holiday are dropped
+)
+
+const (
+	// maxHolidayShiftDays bounds how far an occurrence is moved to reach a
+	// working day; one that cannot be moved this far is skipped
+	maxHolidayShiftDays = 31
+
+	// maxHolidaySearch bounds the occurrences examined for one that is not
+	// skipped
+	maxHolidaySearch = 1000
+)
+
+// holidayDateLayout is the format of holiday dates
+const holidayDateLayout = "2006-01-02"
+
+// Holiday is a day off in a holiday calendar
+type Holiday struct {
+	Date string `json:"date"` // YYYY-MM-DD
+	Name string `json:"name,omitempty"`
+}
+
+// UnmarshalJSON accepts a holiday as an object or as just its date
+func (h *Holiday) UnmarshalJSON(data []byte) error {
+	var date string
+	if err := json.Unmarshal(data, &date); err == nil {
+		*h = Holiday{Date: date}
+		return nil
+	}
+	type holiday Holiday
+	return json.Unmarshal(data, (*holiday)(h))
+}
+
+// HolidayCalendar is a region's holidays
+type HolidayCalendar struct {
+	Region   string    `json:"region"`
+	Holidays []Holiday `json:"holidays"` // In date order
+	days     map[string]bool
+}
+
+// newHolidayCalendar returns the calendar of a region's holidays, which must
+// have valid dates
+func newHolidayCalendar(region string, holidays []Holiday) (*HolidayCalendar, error) {
+	c := &HolidayCalendar{Region: region, Holidays: []Holiday{}, days: make(map[string]bool)}
+	for _, h := range holidays {
+		if _, err := time.Parse(holidayDateLayout, h.Date); err != nil {
+			return nil, fmt.Errorf("invalid holiday date %q: must be YYYY-MM-DD", h.Date)
+		}
+		if !c.days[h.Date] {
+			c.days[h.Date] = true
+			c.Holidays = append(c.Holidays, h)
+		}
+	}
+	sort.Slice(c.Holidays, func(i, j int) bool { return c.Holidays[i].Date < c.Holidays[j].Date })
+	return c, nil
+}
+
+// isHoliday reports whether t's day, in its location, is a holiday
+func (c *HolidayCalendar) isHoliday(t time.Time) bool {
+	return c.days[t.Format(holidayDateLayout)]
+}
+
+// isWorkingDay reports whether t's day is a weekday that is not a holiday
+func (c *HolidayCalendar) isWorkingDay(t time.Time) bool {
+	return t.Weekday() != time.Saturday && t.Weekday() != time.Sunday && !c.isHoliday(t)
+}
+
+// holidayCalendars are the loaded calendars by region
+var holidayCalendars = map[string]*HolidayCalendar{}
+
+// initHolidayCalendars loads the holiday calendars in the HOLIDAY_CALENDAR_DIR
+// value dir, which may be empty
+func initHolidayCalendars(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	calendars, err := loadHolidayCalendars(dir)
+	if err != nil {
+		return fmt.Errorf("invalid HOLIDAY_CALENDAR_DIR %q: %w", dir, err)
+	}
+	holidayCalendars = calendars
+	log.Printf("Loaded %d holiday calendars from %s", len(calendars), dir)
+	return nil
+}
+
+// loadHolidayCalendars reads the .ics and .json calendars in dir, each named
+// after its region. Other files are ignored.
+func loadHolidayCalendars(dir string) (map[string]*HolidayCalendar, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	calendars := make(map[string]*HolidayCalendar)
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".ics" && ext != ".json") {
+			continue
+		}
+		region := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		if _, ok := calendars[region]; ok {
+			return nil, fmt.Errorf("more than one calendar for region %q", region)
+		}
+
+		f, err := os.Open(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var holidays []Holiday
+		if ext == ".ics" {
+			holidays, err = parseICSHolidays(f)
+		} else {
+			err = json.NewDecoder(f).Decode(&holidays)
+		}
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+
+		if calendars[region], err = newHolidayCalendar(region, holidays); err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+	}
+	return calendars, nil
+}
+
+// icsTextEscapes undoes the escaping of iCalendar TEXT values
+var icsTextEscapes = strings.NewReplacer(`\\`, `\`, `\,`, `,`, `\;`, `;`, `\n`, " ", `\N`, " ")
+
+// parseICSHolidays returns the days covered by the events of an iCalendar
+// file. Each event covers the days from its DTSTART up to, but not including,
+// its all-day DTEND, and is named by its SUMMARY. Recurring events (RRULE) are
+// not expanded, so calendars must list each year's holidays, as published
+// holiday calendars do.
+func parseICSHolidays(r io.Reader) ([]Holiday, error) {
+	// Unfold continuation lines, which start with a space or tab
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var holidays []Holiday
+	var inEvent bool
+	var start, end time.Time
+	var summary string
+	for _, line := range lines {
+		property, value, _ := strings.Cut(line, ":")
+		name, _, _ := strings.Cut(property, ";")
+		switch strings.ToUpper(name) {
+		case "BEGIN":
+			if strings.EqualFold(value, "VEVENT") {
+				inEvent, start, end, summary = true, time.Time{}, time.Time{}, ""
+			}
+		case "DTSTART", "DTEND":
+			if !inEvent {
+				continue
+			}
+			// DATE values are YYYYMMDD; DATE-TIME values add a time, which
+			// does not change the day a holiday is on
+			if len(value) < 8 {
+				return nil, fmt.Errorf("invalid %s %q", name, value)
+			}
+			date, err := time.Parse("20060102", value[:8])
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q", name, value)
+			}
+			if strings.EqualFold(name, "DTSTART") {
+				start = date
+			} else if len(value) == 8 {
+				end = date
+			}
+		case "SUMMARY":
+			if inEvent {
+				summary = icsTextEscapes.Replace(value)
+			}
+		case "END":
+			if !inEvent || !strings.EqualFold(value, "VEVENT") {
+				continue
+			}
+			inEvent = false
+			if start.IsZero() {
+				return nil, fmt.Errorf("event %q has no DTSTART", summary)
+			}
+			day := start
+			for {
+				holidays = append(holidays, Holiday{Date: day.Format(holidayDateLayout), Name: summary})
+				day = day.AddDate(0, 0, 1)
+				if !day.Before(end) {
+					break
+				}
+			}
+		}
+	}
+	return holidays, nil
+}
+
+// validateHolidayRule validates a recurring definition's holiday region and
+// rule
+func validateHolidayRule(def *RecurringItemDefinition) error {
+	if def.HolidayRegion != "" && holidayCalendars[def.HolidayRegion] == nil {
+		return fmt.Errorf("holidayRegion %q has no holiday calendar", def.HolidayRegion)
+	}
+	switch def.HolidayRule {
+	case holidayRuleShift:
+		return nil
+	case holidayRuleSkip:
+		if def.HolidayRegion == "" {
+			return fmt.Errorf("holidayRule requires a holidayRegion")
+		}
+		return nil
+	default:
+		return fmt.Errorf("holidayRule must be empty or 'skip'")
+	}
+}
+
+// holidayCalendar returns the calendar of the definition's holiday region, or
+// nil if it has none
+func (d *RecurringItemDefinition) holidayCalendar() *HolidayCalendar {
+	if d.HolidayRegion == "" {
+		return nil
+	}
+	return holidayCalendars[d.HolidayRegion]
+}
+
+// observeHolidays returns when an occurrence scheduled at t falls due under
+// the definition's holiday rule, or false if the rule skips it
+func (d *RecurringItemDefinition) observeHolidays(t time.Time) (time.Time, bool) {
+	calendar := d.holidayCalendar()
+	t = t.In(d.location())
+	if calendar == nil || !calendar.isHoliday(t) {
+		return t, true
+	}
+	if d.HolidayRule == holidayRuleSkip {
+		return time.Time{}, false
+	}
+	for days := 1; days <= maxHolidayShiftDays; days++ {
+		if next := t.AddDate(0, 0, days); calendar.isWorkingDay(next) {
+			return next, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// nextScheduledDate returns the first occurrence of the definition's calendar
+// pattern after the given time, once its holiday rule has been applied. It
+// ignores EndDate and MaxOccurrences.
+func (d *RecurringItemDefinition) nextScheduledDate(after time.Time) (time.Time, bool) {
+	start := d.localStart()
+	if d.holidayCalendar() == nil {
+		return calculateNextDueDate(start, d.Pattern, after)
+	}
+
+	// An occurrence before after may have been moved past it, and one moved
+	// over a weekend can land after later ones, so take the earliest due
+	// date among the occurrences that could be moved past after
+	var best time.Time
+	from := after.AddDate(0, 0, -maxHolidayShiftDays)
+	for range maxHolidaySearch {
+		next, ok := calculateNextDueDate(start, d.Pattern, from)
+		if !ok || (!best.IsZero() && !next.Before(best)) {
+			break
+		}
+		if due, ok := d.observeHolidays(next); ok && due.After(after) && (best.IsZero() || due.Before(best)) {
+			best = due
+		}
+		from = next
+	}
+	return best, !best.IsZero()
+}
+
+// getHolidayCalendars returns the loaded holiday calendars in region order
+func getHolidayCalendars(w http.ResponseWriter, r *http.Request) {
+	calendars := make([]*HolidayCalendar, 0, len(holidayCalendars))
+	for _, c := range holidayCalendars {
+		calendars = append(calendars, c)
+	}
+	sort.Slice(calendars, func(i, j int) bool { return calendars[i].Region < calendars[j].Region })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(calendars)
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useHolidayCalendar makes the holidays the only calendar, for region "test",
+// until the test ends
+func useHolidayCalendar(t *testing.T, holidays ...string) {
+	t.Helper()
+	var days []Holiday
+	for _, h := range holidays {
+		days = append(days, Holiday{Date: h})
+	}
+	calendar, err := newHolidayCalendar("test", days)
+	if err != nil {
+		t.Fatalf("newHolidayCalendar: %v", err)
+	}
+	previous := holidayCalendars
+	holidayCalendars = map[string]*HolidayCalendar{"test": calendar}
+	t.Cleanup(func() { holidayCalendars = previous })
+}
+
+func formatDays(dates []time.Time) string {
+	days := make([]string, len(dates))
+	for i, d := range dates {
+		days[i] = d.Format("Mon 2")
+	}
+	return strings.Join(days, ", ")
+}
+
+func TestWeekdaysFrequency(t *testing.T) {
+	tests := []struct {
+		name     string
+		start    time.Time
+		interval int
+		want     string
+	}{
+		{"every weekday", date(2026, 1, 8), 1, "Thu 8, Fri 9, Mon 12, Tue 13, Wed 14, Thu 15, Fri 16, Mon 19"},
+		{"starting at the weekend", date(2026, 1, 10), 1, "Mon 12, Tue 13, Wed 14, Thu 15, Fri 16, Mon 19, Tue 20, Wed 21"},
+		{"every third weekday", date(2026, 1, 8), 3, "Thu 8, Tue 13, Fri 16, Wed 21, Mon 26, Thu 29, Tue 3, Fri 6"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pattern := RecurrencePattern{Frequency: "weekdays", Interval: tt.interval}
+			got := formatDays(occurrences(t, tt.start, pattern, tt.start.Add(-time.Second), 8))
+			if got != tt.want {
+				t.Errorf("occurrences = %s, want %s", got, tt.want)
+			}
+
+			// Starting the search part way through gives the same dates
+			later := formatDays(occurrences(t, tt.start, pattern, date(2026, 1, 14), 2))
+			if !strings.Contains(tt.want, later) {
+				t.Errorf("occurrences after Wed 14 = %s, not in %s", later, tt.want)
+			}
+		})
+	}
+
+	// One working day after completion skips the weekend
+	pattern := RecurrencePattern{Frequency: "weekdays", Interval: 1, Mode: recurrenceModeAfterCompletion}
+	for completed, want := range map[int]string{9: "Mon 12", 10: "Mon 12", 11: "Mon 12", 12: "Tue 13"} {
+		if got := calculateNextCompletionDueDate(pattern, date(2026, 1, completed)).Format("Mon 2"); got != want {
+			t.Errorf("completed on %d January: next due %s, want %s", completed, got, want)
+		}
+	}
+}
+
+func TestHolidayRules(t *testing.T) {
+	// Friday 2 January and Monday 5 January are holidays
+	useHolidayCalendar(t, "2026-01-02", "2026-01-05")
+	start := date(2025, 12, 29)
+
+	tests := []struct {
+		name    string
+		pattern RecurrencePattern
+		rule    string
+		want    string
+	}{
+		{"weekdays shift into the next one", RecurrencePattern{Frequency: "weekdays", Interval: 1}, holidayRuleShift, "Mon 29, Tue 30, Wed 31, Thu 1, Tue 6, Wed 7"},
+		{"daily moves over the weekend", RecurrencePattern{Frequency: "daily", Interval: 1}, holidayRuleShift, "Mon 29, Tue 30, Wed 31, Thu 1, Sat 3, Sun 4, Tue 6, Wed 7"},
+		{"weekly moves to the next working day", RecurrencePattern{Frequency: "weekly", Interval: 1, DaysOfWeek: []string{"Friday"}}, holidayRuleShift, "Tue 6, Fri 9, Fri 16"},
+		{"weekly skip", RecurrencePattern{Frequency: "weekly", Interval: 1, DaysOfWeek: []string{"Friday"}}, holidayRuleSkip, "Fri 9, Fri 16, Fri 23"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			def := &RecurringItemDefinition{
+				Pattern:       tt.pattern,
+				StartDate:     start,
+				CreatedAt:     start,
+				HolidayRegion: "test",
+				HolidayRule:   tt.rule,
+			}
+			want := strings.Split(tt.want, ", ")
+			got := formatDays(def.occurrencesBetween(start.Add(-time.Second), time.Time{}, len(want)))
+			if got != tt.want {
+				t.Errorf("occurrences = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// An occurrence moved past the search start is still found
+	def := &RecurringItemDefinition{
+		Pattern:       RecurrencePattern{Frequency: "weekly", Interval: 1, DaysOfWeek: []string{"Friday"}},
+		StartDate:     start,
+		CreatedAt:     start,
+		HolidayRegion: "test",
+	}
+	if next, ok := def.nextDueDate(date(2026, 1, 3)); !ok || !next.Equal(date(2026, 1, 6)) {
+		t.Errorf("next after Saturday 3 January = %v, %v, want the Friday moved to Tuesday 6", next, ok)
+	}
+
+	// Occurrences count towards MaxOccurrences once moved or skipped
+	def.HolidayRule = holidayRuleSkip
+	def.MaxOccurrences = 2
+	if got := formatDays(def.occurrencesBetween(start, time.Time{}, 5)); got != "Fri 9, Fri 16" {
+		t.Errorf("occurrences with maxOccurrences = %s, want Fri 9, Fri 16", got)
+	}
+}
+
+func TestLoadHolidayCalendars(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"uk.ics": strings.Join([]string{
+			"BEGIN:VCALENDAR",
+			"VERSION:2.0",
+			"BEGIN:VEVENT",
+			"DTSTART;VALUE=DATE:20261225",
+			"DTEND;VALUE=DATE:20261227",
+			"SUMMARY:Christmas Day and Boxing\\, in one",
+			"END:VEVENT",
+			"BEGIN:VEVENT",
+			"DTSTART;VALUE=DATE:20260831",
+			"SUMMARY:Summer bank",
+			"  holiday",
+			"END:VEVENT",
+			"END:VCALENDAR",
+		}, "\r\n"),
+		"us.json":   `["2026-07-03", {"date": "2026-11-26", "name": "Thanksgiving"}]`,
+		"notes.txt": "not a calendar",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	calendars, err := loadHolidayCalendars(dir)
+	if err != nil {
+		t.Fatalf("loadHolidayCalendars: %v", err)
+	}
+	if len(calendars) != 2 {
+		t.Fatalf("got %d calendars, want uk and us", len(calendars))
+	}
+
+	uk := calendars["uk"].Holidays
+	if len(uk) != 3 || uk[0] != (Holiday{"2026-08-31", "Summer bank holiday"}) ||
+		uk[1] != (Holiday{"2026-12-25", "Christmas Day and Boxing, in one"}) || uk[2].Date != "2026-12-26" {
+		t.Errorf("uk holidays = %+v", uk)
+	}
+	us := calendars["us"].Holidays
+	if len(us) != 2 || us[0] != (Holiday{Date: "2026-07-03"}) || us[1] != (Holiday{"2026-11-26", "Thanksgiving"}) {
+		t.Errorf("us holidays = %+v", us)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "bad.json"), []byte(`["26/12/2026"]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadHolidayCalendars(dir); err == nil {
+		t.Error("expected an error for a calendar with an invalid date")
+	}
+}
+
+func TestValidateHolidayRule(t *testing.T) {
+	useHolidayCalendar(t, "2026-12-25")
+
+	tests := []struct {
+		region, rule string
+		valid        bool
+	}{
+		{"", "", true},
+		{"test", "", true},
+		{"test", holidayRuleSkip, true},
+		{"mars", "", false},
+		{"", holidayRuleSkip, false},
+		{"test", "postpone", false},
+	}
+	for _, tt := range tests {
+		err := validateHolidayRule(&RecurringItemDefinition{HolidayRegion: tt.region, HolidayRule: tt.rule})
+		if (err == nil) != tt.valid {
+			t.Errorf("validateHolidayRule(%q, %q) = %v, want valid %v", tt.region, tt.rule, err, tt.valid)
+		}
+	}
+}
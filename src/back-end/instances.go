@@ -0,0 +1,230 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+// newRecurringInstance builds the to-do item for one occurrence of a
+// recurring definition
+func newRecurringInstance(def *RecurringItemDefinition, dueDate time.Time, position int) *TodoItem {
+	return &TodoItem{
+		Title:        def.Title,
+		Description:  def.Description,
+		AssignedTo:   cloneStrings(def.AssignedTo),
+		IsRecurring:  true,
+		RecurrenceID: &def.ID,
+		DueDate:      &dueDate,
+		AllDay:       def.AllDay,
+		Position:     position,
+		CreatedAt:    clock.Now(),
+	}
+}
+
+// spawnNextInstance creates the occurrence that follows a completed recurring
+// instance and links it via NextInstanceID. The caller is responsible for
+// saving todo.
+//
+// It is idempotent: an instance that has already spawned its successor is
+// left alone, so un-completing and re-completing does not create duplicates,
+// and an existing instance for the next occurrence is linked rather than
+// duplicated.
+func spawnNextInstance(tx Store, todo *TodoItem) error {
+	if todo.RecurrenceID == nil || todo.NextInstanceID != nil {
+		return nil
+	}
+
+	if err := tx.LockRecurringDef(*todo.RecurrenceID); err != nil {
+		return err
+	}
+	def, err := tx.GetRecurringDef(*todo.RecurrenceID)
+	if errors.Is(err, ErrNotFound) {
+		// The definition was deleted; nothing more to schedule
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if def.Paused {
+		// resumeDefinition gives the series a pending instance again
+		return nil
+	}
+
+	todos, err := tx.ListTodos()
+	if err != nil {
+		return err
+	}
+
+	// Schedule after this instance's due date, or after now if it was
+	// completed late, so the next instance is never already overdue, unless
+	// missed occurrences are to be caught up on. afterCompletion patterns
+	// count from when it was actually done.
+	now := clock.Now()
+	after := now
+	switch {
+	case def.Pattern.Mode == recurrenceModeAfterCompletion:
+		if todo.CompletedAt != nil {
+			after = *todo.CompletedAt
+		}
+	case todo.DueDate == nil:
+	case def.MissedPolicy == missedPolicyCatchUp:
+		after = scheduledDueDate(todo)
+	case todo.DueDate.After(after):
+		after = *todo.DueDate
+	default:
+		// Completed late: occurrences since it was due are skipped over
+		if missed := countUninstancedMisses(def, todos, scheduledDueDate(todo), now); missed > 0 {
+			def.MissedCount += missed
+			if err := tx.UpdateRecurringDef(def); err != nil {
+				return err
+			}
+		}
+	}
+	nextDueDate, ok := def.nextDueDate(after)
+	if !ok {
+		// The series has ended
+		return refreshFinished(tx, def, after)
+	}
+	for _, other := range todos {
+		if other.ID != todo.ID && other.RecurrenceID != nil && *other.RecurrenceID == def.ID &&
+			other.DueDate != nil && other.DueDate.Equal(nextDueDate) {
+			todo.NextInstanceID = &other.ID
+			return refreshFinished(tx, def, latestInstanceDueDate(todos, def.ID, nextDueDate))
+		}
+	}
+
+	// Save the completion first so a rotation no longer counts this
+	// instance as pending when choosing the next assignee
+	if def.Rotation != nil {
+		if err := tx.UpdateTodo(todo); err != nil {
+			return err
+		}
+	}
+	next, err := createRecurringInstance(tx, def, nextDueDate, len(todos))
+	if err != nil {
+		return err
+	}
+	todo.NextInstanceID = &next.ID
+	return refreshFinished(tx, def, latestInstanceDueDate(todos, def.ID, nextDueDate))
+}
+
+// latestInstanceDueDate returns the latest due date among the instances of
+// the given definition, or from if none is later
+func latestInstanceDueDate(todos []*TodoItem, defID int, from time.Time) time.Time {
+	for _, todo := range todos {
+		if todo.RecurrenceID != nil && *todo.RecurrenceID == defID && todo.DueDate != nil && todo.DueDate.After(from) {
+			from = *todo.DueDate
+		}
+	}
+	return from
+}
+
+// refreshFinished marks def finished once it has no occurrence after the
+// given time (normally its latest instance's due date), so every occurrence
+// has been scheduled, or clears the mark when an edit has extended it. def is
+// only saved when that changes.
+func refreshFinished(tx Store, def *RecurringItemDefinition, after time.Time) error {
+	_, more := def.nextDueDate(after)
+	if more != def.Finished {
+		return nil
+	}
+
+	def.Finished = !more
+	def.FinishedAt = nil
+	if def.Finished {
+		now := clock.Now()
+		def.FinishedAt = &now
+	}
+	return tx.UpdateRecurringDef(def)
+}
+
+// pendingInstanceOn returns def's uncompleted instance for the occurrence on
+// the given day, matching a moved instance by either its current or its
+// scheduled date, or nil if there is none
+func pendingInstanceOn(todos []*TodoItem, def *RecurringItemDefinition, date time.Time) *TodoItem {
+	loc := def.location()
+	sameDay := func(a, b time.Time) bool { return daysBetween(a.In(loc), b.In(loc)) == 0 }
+
+	for _, todo := range todos {
+		if todo.RecurrenceID == nil || *todo.RecurrenceID != def.ID || todo.Completed || todo.DueDate == nil {
+			continue
+		}
+		if sameDay(*todo.DueDate, date) || (todo.OriginalDueDate != nil && sameDay(*todo.OriginalDueDate, date)) {
+			return todo
+		}
+	}
+	return nil
+}
+
+// errNotAnOccurrence is returned when skipping or moving a date on which a
+// recurring definition has no occurrence
+var errNotAnOccurrence = errors.New("date is not an occurrence of this recurring item")
+
+// applyOccurrenceException skips the definition's occurrence on the given
+// day, or moves it to moveTo when that is set. The occurrence's date is added
+// to the pattern's exception dates so it is never generated again, and its
+// instance is kept as a record: a skipped instance is marked Skipped and
+// completed (spawning the next one), and a moved instance keeps its scheduled
+// date in OriginalDueDate. An occurrence that has no instance yet gets one
+// when moved. It returns the affected instance, if any.
+func applyOccurrenceException(tx Store, def *RecurringItemDefinition, date time.Time, moveTo *time.Time) (*TodoItem, error) {
+	if err := tx.LockRecurringDef(def.ID); err != nil {
+		return nil, err
+	}
+	todos, err := tx.ListTodos()
+	if err != nil {
+		return nil, err
+	}
+
+	loc := def.location()
+	instance := pendingInstanceOn(todos, def, date)
+
+	var occurrence time.Time
+	switch {
+	case instance != nil && instance.OriginalDueDate != nil:
+		occurrence = *instance.OriginalDueDate
+	case instance != nil:
+		occurrence = *instance.DueDate
+	case def.Pattern.Mode == recurrenceModeAfterCompletion:
+		// Only the pending instance of these is known in advance
+		return nil, errNotAnOccurrence
+	default:
+		next, ok := def.nextDueDate(startOfDay(date, loc).Add(-time.Nanosecond))
+		if !ok || daysBetween(next.In(loc), date.In(loc)) != 0 {
+			return nil, errNotAnOccurrence
+		}
+		occurrence = next
+	}
+
+	if def.Pattern.Mode != recurrenceModeAfterCompletion && !def.Pattern.isException(occurrence.In(loc)) {
+		def.Pattern.ExceptionDates = append(def.Pattern.ExceptionDates, occurrence)
+		if err := tx.UpdateRecurringDef(def); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case moveTo != nil && instance != nil:
+		if instance.OriginalDueDate == nil {
+			instance.OriginalDueDate = &occurrence
+		}
+		instance.DueDate = moveTo
+	case moveTo != nil:
+		instance, err = createRecurringInstance(tx, def, *moveTo, len(todos))
+		if err != nil {
+			return nil, err
+		}
+		instance.OriginalDueDate = &occurrence
+	case instance != nil:
+		now := clock.Now()
+		instance.Skipped = true
+		instance.Completed = true
+		instance.CompletedAt = &now
+		if err := spawnNextInstance(tx, instance); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, nil
+	}
+	return instance, tx.UpdateTodo(instance)
+}
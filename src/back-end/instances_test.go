@@ -0,0 +1,438 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// createDailyDefinition stores a daily definition and its first instance
+func createDailyDefinition(t *testing.T, s Store, due time.Time) (*RecurringItemDefinition, *TodoItem) {
+	t.Helper()
+
+	def := &RecurringItemDefinition{
+		Title:     "Feed the cat",
+		Pattern:   RecurrencePattern{Frequency: "daily", Interval: 1},
+		StartDate: due,
+		CreatedAt: time.Now(),
+	}
+	if err := s.CreateRecurringDef(def); err != nil {
+		t.Fatalf("CreateRecurringDef: %v", err)
+	}
+
+	todo := newRecurringInstance(def, due, 0)
+	if err := s.CreateTodo(todo); err != nil {
+		t.Fatalf("CreateTodo: %v", err)
+	}
+	return def, todo
+}
+
+// completeTodo marks todo complete the same way updateTodo does
+func completeTodo(t *testing.T, s Store, todo *TodoItem) *TodoItem {
+	t.Helper()
+
+	err := s.Update(func(tx Store) error {
+		current, err := tx.GetTodo(todo.ID)
+		if err != nil {
+			return err
+		}
+		current.Completed = true
+		if err := spawnNextInstance(tx, current); err != nil {
+			return err
+		}
+		*todo = *current
+		return tx.UpdateTodo(current)
+	})
+	if err != nil {
+		t.Fatalf("completing todo %d: %v", todo.ID, err)
+	}
+	return todo
+}
+
+func instancesOf(t *testing.T, s Store, defID int) []*TodoItem {
+	t.Helper()
+
+	todos, err := s.ListTodos()
+	if err != nil {
+		t.Fatalf("ListTodos: %v", err)
+	}
+	var instances []*TodoItem
+	for _, todo := range todos {
+		if todo.RecurrenceID != nil && *todo.RecurrenceID == defID {
+			instances = append(instances, todo)
+		}
+	}
+	return instances
+}
+
+func TestCompletingRecurringInstanceSpawnsNext(t *testing.T) {
+	s := newMemoryStore()
+	due := time.Now().Add(48 * time.Hour).Truncate(time.Second)
+	def, todo := createDailyDefinition(t, s, due)
+
+	completeTodo(t, s, todo)
+
+	instances := instancesOf(t, s, def.ID)
+	if len(instances) != 2 {
+		t.Fatalf("got %d instances, want 2", len(instances))
+	}
+	if todo.NextInstanceID == nil {
+		t.Fatal("completed instance was not linked to its successor")
+	}
+
+	next, err := s.GetTodo(*todo.NextInstanceID)
+	if err != nil {
+		t.Fatalf("GetTodo(next): %v", err)
+	}
+	if want := due.AddDate(0, 0, 1); next.DueDate == nil || !next.DueDate.Equal(want) {
+		t.Errorf("next instance due %v, want %v", next.DueDate, want)
+	}
+	if next.Completed || next.Title != def.Title {
+		t.Errorf("unexpected next instance: %+v", next)
+	}
+}
+
+func TestRecompletingDoesNotDuplicate(t *testing.T) {
+	s := newMemoryStore()
+	def, todo := createDailyDefinition(t, s, time.Now().Add(24*time.Hour).Truncate(time.Second))
+
+	completeTodo(t, s, todo)
+
+	// Un-complete, then complete again
+	todo.Completed = false
+	if err := s.UpdateTodo(todo); err != nil {
+		t.Fatalf("UpdateTodo: %v", err)
+	}
+	completeTodo(t, s, todo)
+
+	if instances := instancesOf(t, s, def.ID); len(instances) != 2 {
+		t.Errorf("got %d instances after re-completing, want 2", len(instances))
+	}
+}
+
+func TestSpawnLinksExistingNextInstance(t *testing.T) {
+	s := newMemoryStore()
+	due := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	def, todo := createDailyDefinition(t, s, due)
+
+	existing := newRecurringInstance(def, due.AddDate(0, 0, 1), 1)
+	if err := s.CreateTodo(existing); err != nil {
+		t.Fatalf("CreateTodo: %v", err)
+	}
+
+	completeTodo(t, s, todo)
+
+	if instances := instancesOf(t, s, def.ID); len(instances) != 2 {
+		t.Errorf("got %d instances, want 2", len(instances))
+	}
+	if todo.NextInstanceID == nil || *todo.NextInstanceID != existing.ID {
+		t.Errorf("NextInstanceID = %v, want %d", todo.NextInstanceID, existing.ID)
+	}
+}
+
+func TestSpawnIgnoresOneOffAndOrphanedItems(t *testing.T) {
+	s := newMemoryStore()
+
+	oneOff := &TodoItem{Title: "One-off", CreatedAt: time.Now()}
+	if err := s.CreateTodo(oneOff); err != nil {
+		t.Fatalf("CreateTodo: %v", err)
+	}
+	completeTodo(t, s, oneOff)
+
+	def, orphan := createDailyDefinition(t, s, time.Now().Add(24*time.Hour))
+	if err := s.DeleteRecurringDef(def.ID); err != nil {
+		t.Fatalf("DeleteRecurringDef: %v", err)
+	}
+	completeTodo(t, s, orphan)
+
+	todos, _ := s.ListTodos()
+	if len(todos) != 2 {
+		t.Errorf("got %d todos, want 2 (nothing spawned)", len(todos))
+	}
+}
+
+func TestCompletingLastOccurrenceFinishesDefinition(t *testing.T) {
+	s := newMemoryStore()
+	due := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	def, todo := createDailyDefinition(t, s, due)
+	def.MaxOccurrences = 3
+	if err := s.UpdateRecurringDef(def); err != nil {
+		t.Fatalf("UpdateRecurringDef: %v", err)
+	}
+
+	next := func(todo *TodoItem) *TodoItem {
+		t.Helper()
+		completeTodo(t, s, todo)
+		if todo.NextInstanceID == nil {
+			t.Fatalf("todo %d has no successor", todo.ID)
+		}
+		next, err := s.GetTodo(*todo.NextInstanceID)
+		if err != nil {
+			t.Fatalf("GetTodo(next): %v", err)
+		}
+		return next
+	}
+
+	second := next(todo)
+	if got, _ := s.GetRecurringDef(def.ID); got.Finished {
+		t.Fatal("definition finished with an occurrence still to schedule")
+	}
+
+	// The third occurrence is the last, so the series is fully scheduled
+	third := next(second)
+	got, err := s.GetRecurringDef(def.ID)
+	if err != nil {
+		t.Fatalf("GetRecurringDef: %v", err)
+	}
+	if !got.Finished || got.FinishedAt == nil {
+		t.Errorf("definition not finished after its last occurrence was scheduled: %+v", got)
+	}
+
+	completeTodo(t, s, third)
+	if instances := instancesOf(t, s, def.ID); len(instances) != 3 {
+		t.Errorf("got %d instances, want 3", len(instances))
+	}
+	if third.NextInstanceID != nil {
+		t.Errorf("last occurrence linked to a successor %d", *third.NextInstanceID)
+	}
+}
+
+func TestAfterCompletionCountsFromCompletion(t *testing.T) {
+	s := newMemoryStore()
+	due := time.Now().Add(-72 * time.Hour).Truncate(time.Second)
+	def, todo := createDailyDefinition(t, s, due)
+	def.Pattern = RecurrencePattern{Frequency: "daily", Interval: 3, Mode: recurrenceModeAfterCompletion}
+	if err := s.UpdateRecurringDef(def); err != nil {
+		t.Fatalf("UpdateRecurringDef: %v", err)
+	}
+
+	// Completed a day after it was due
+	completedAt := due.Add(24 * time.Hour)
+	todo.CompletedAt = &completedAt
+	if err := s.UpdateTodo(todo); err != nil {
+		t.Fatalf("UpdateTodo: %v", err)
+	}
+	completeTodo(t, s, todo)
+
+	if todo.NextInstanceID == nil {
+		t.Fatal("completed instance was not linked to its successor")
+	}
+	next, err := s.GetTodo(*todo.NextInstanceID)
+	if err != nil {
+		t.Fatalf("GetTodo(next): %v", err)
+	}
+	if want := completedAt.AddDate(0, 0, 3); next.DueDate == nil || !next.DueDate.Equal(want) {
+		t.Errorf("next instance due %v, want %v (three days after completion)", next.DueDate, want)
+	}
+}
+
+func TestSkipOccurrenceRecordsInstanceAndSpawnsNext(t *testing.T) {
+	s := newMemoryStore()
+	due := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	def, todo := createDailyDefinition(t, s, due)
+
+	var skipped *TodoItem
+	err := s.Update(func(tx Store) error {
+		var err error
+		skipped, err = applyOccurrenceException(tx, def, due, nil)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("applyOccurrenceException: %v", err)
+	}
+
+	if skipped == nil || skipped.ID != todo.ID || !skipped.Skipped || !skipped.Completed {
+		t.Fatalf("skipped instance = %+v, want instance %d marked skipped", skipped, todo.ID)
+	}
+	if skipped.NextInstanceID == nil {
+		t.Fatal("skipping did not create the next instance")
+	}
+	next, err := s.GetTodo(*skipped.NextInstanceID)
+	if err != nil {
+		t.Fatalf("GetTodo(next): %v", err)
+	}
+	if want := due.AddDate(0, 0, 1); !next.DueDate.Equal(want) {
+		t.Errorf("next instance due %v, want %v", next.DueDate, want)
+	}
+
+	got, err := s.GetRecurringDef(def.ID)
+	if err != nil {
+		t.Fatalf("GetRecurringDef: %v", err)
+	}
+	if len(got.Pattern.ExceptionDates) != 1 || !got.Pattern.ExceptionDates[0].Equal(due) {
+		t.Errorf("exception dates = %v, want [%v]", got.Pattern.ExceptionDates, due)
+	}
+}
+
+func TestMoveOccurrence(t *testing.T) {
+	s := newMemoryStore()
+	now := time.Now().Truncate(time.Second)
+	due := now.Add(24 * time.Hour)
+	def, todo := createDailyDefinition(t, s, due)
+	moveTo := due.Add(5 * time.Hour)
+
+	err := s.Update(func(tx Store) error {
+		_, err := applyOccurrenceException(tx, def, due, &moveTo)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("applyOccurrenceException: %v", err)
+	}
+
+	moved, err := s.GetTodo(todo.ID)
+	if err != nil {
+		t.Fatalf("GetTodo: %v", err)
+	}
+	if !moved.DueDate.Equal(moveTo) || moved.OriginalDueDate == nil || !moved.OriginalDueDate.Equal(due) || moved.Completed {
+		t.Errorf("moved instance = %+v, want due %v originally %v", moved, moveTo, due)
+	}
+
+	// The original date is not generated again
+	if _, err := materializeUpcoming(s, now, 3*24*time.Hour); err != nil {
+		t.Fatalf("materializeUpcoming: %v", err)
+	}
+	for _, instance := range instancesOf(t, s, def.ID) {
+		if instance.DueDate.Equal(due) {
+			t.Errorf("instance %d recreated on the moved date", instance.ID)
+		}
+	}
+}
+
+func TestMovedOccurrenceCountsTowardsMaxOccurrences(t *testing.T) {
+	s := newMemoryStore()
+	now := time.Now().Truncate(time.Second)
+	due := now.Add(time.Hour)
+	def, _ := createDailyDefinition(t, s, due)
+	def.MaxOccurrences = 3
+	if err := s.UpdateRecurringDef(def); err != nil {
+		t.Fatalf("UpdateRecurringDef: %v", err)
+	}
+
+	// Moving the second occurrence doesn't make room for a fourth
+	second := due.AddDate(0, 0, 1)
+	moveTo := second.Add(5 * time.Hour)
+	err := s.Update(func(tx Store) error {
+		_, err := applyOccurrenceException(tx, def, second, &moveTo)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("applyOccurrenceException: %v", err)
+	}
+	if _, err := materializeUpcoming(s, now, 10*24*time.Hour); err != nil {
+		t.Fatalf("materializeUpcoming: %v", err)
+	}
+	if got := len(instancesOf(t, s, def.ID)); got != 3 {
+		t.Errorf("got %d instances of a 3 occurrence series, want 3", got)
+	}
+}
+
+func TestMoveOccurrenceWithoutInstance(t *testing.T) {
+	s := newMemoryStore()
+	due := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	def, _ := createDailyDefinition(t, s, due)
+
+	// The occurrence a week later has no instance yet
+	later := due.AddDate(0, 0, 7)
+	moveTo := later.AddDate(0, 0, 1).Add(2 * time.Hour)
+	var moved *TodoItem
+	err := s.Update(func(tx Store) error {
+		var err error
+		moved, err = applyOccurrenceException(tx, def, later, &moveTo)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("applyOccurrenceException: %v", err)
+	}
+	if moved == nil || moved.ID == 0 || !moved.DueDate.Equal(moveTo) || !moved.OriginalDueDate.Equal(later) {
+		t.Errorf("moved = %+v, want a new instance due %v originally %v", moved, moveTo, later)
+	}
+	if got := len(instancesOf(t, s, def.ID)); got != 2 {
+		t.Errorf("got %d instances, want 2", got)
+	}
+}
+
+func TestOccurrenceExceptionRejectsOtherDates(t *testing.T) {
+	s := newMemoryStore()
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	def := &RecurringItemDefinition{
+		Title:     "Bins",
+		Pattern:   RecurrencePattern{Frequency: "weekly", Interval: 1, DaysOfWeek: []string{"Monday"}},
+		StartDate: start,
+		CreatedAt: start,
+	}
+	if err := s.CreateRecurringDef(def); err != nil {
+		t.Fatalf("CreateRecurringDef: %v", err)
+	}
+
+	err := s.Update(func(tx Store) error {
+		_, err := applyOccurrenceException(tx, def, start.AddDate(0, 0, 8), nil)
+		return err
+	})
+	if !errors.Is(err, errNotAnOccurrence) {
+		t.Errorf("skipping a Tuesday: err = %v, want errNotAnOccurrence", err)
+	}
+
+	// A future occurrence without an instance is recorded as an exception
+	err = s.Update(func(tx Store) error {
+		_, err := applyOccurrenceException(tx, def, start.AddDate(0, 0, 14), nil)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("skipping a Monday: %v", err)
+	}
+	if next, _ := def.nextDueDate(start.AddDate(0, 0, 7)); !next.Equal(start.AddDate(0, 0, 21)) {
+		t.Errorf("next occurrence after the skip = %v, want %v", next, start.AddDate(0, 0, 21))
+	}
+}
+
+func TestUpdateTodoRecordsEachCompletion(t *testing.T) {
+	s := newMemoryStore()
+	useStore(t, s)
+	c := &devClock{}
+	clock = c
+	t.Cleanup(func() { clock = systemClock{} })
+	first := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	c.freeze(first)
+
+	todo := &TodoItem{Title: "Post the letter", CreatedAt: first}
+	if err := s.CreateTodo(todo); err != nil {
+		t.Fatalf("CreateTodo: %v", err)
+	}
+	update := func(completed bool, user string) *TodoItem {
+		t.Helper()
+		body := `{"title": "Post the letter", "completed": ` + strconv.FormatBool(completed) + `}`
+		request := httptest.NewRequest("PUT", "/api/todos/"+strconv.Itoa(todo.ID), strings.NewReader(body))
+		request = mux.SetURLVars(request, map[string]string{"id": strconv.Itoa(todo.ID)})
+		request = request.WithContext(context.WithValue(request.Context(), "userEmail", user))
+		recorder := httptest.NewRecorder()
+		updateTodo(recorder, request)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("updateTodo: status %d: %s", recorder.Code, recorder.Body)
+		}
+		got, err := s.GetTodo(todo.ID)
+		if err != nil {
+			t.Fatalf("GetTodo: %v", err)
+		}
+		return got
+	}
+
+	if got := update(true, "alice@example.com"); got.CompletedAt == nil || !got.CompletedAt.Equal(first) || got.CompletedBy != "alice@example.com" {
+		t.Errorf("completed = %+v, want completed by alice at %v", got, first)
+	}
+	if got := update(false, "alice@example.com"); got.Completed || got.CompletedAt != nil || got.CompletedBy != "" {
+		t.Errorf("reopened = %+v, want no completion", got)
+	}
+
+	second := first.Add(3 * time.Hour)
+	c.freeze(second)
+	if got := update(true, "bob@example.com"); got.CompletedAt == nil || !got.CompletedAt.Equal(second) || got.CompletedBy != "bob@example.com" {
+		t.Errorf("completed again = %+v, want completed by bob at %v", got, second)
+	}
+}
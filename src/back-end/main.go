@@ -2,98 +2,97 @@ package main
 
 import (
 	"context"
-	"crypto/rand"
-	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
-	"sort"
+	"os/signal"
+	"slices"
 	"strconv"
 	"strings"
-	"sync"
+	"syscall"
 	"time"
 
 	"github.com/coreos/go-oidc/v3/oidc"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
 )
 
 // AuthConfig holds authentication configuration
 type AuthConfig struct {
-	Mode             string   // "dev" or "prod"
-	AllowedUsers     []string // List of allowed user emails
-	TenantID         string   // Entra ID tenant ID (for prod)
-	ClientID         string   // Entra ID client ID (for prod)
-	DevSecret        string   // Secret for dev mode JWT signing
-	oidcVerifier     *oidc.IDTokenVerifier
+	Mode         string         // "dev" or "prod"
+	AllowedUsers []string       // List of allowed user emails
+	TenantID     string         // Entra ID tenant ID (for prod)
+	ClientID     string         // Entra ID client ID (for prod)
+	devKey       *devSigningKey // Signs dev mode tokens
+	oidcVerifier *oidc.IDTokenVerifier
 }
 
 var authConfig *AuthConfig
 
-// MockUser represents a test user for development mode
-type MockUser struct {
-	Email string `json:"email"`
-	Name  string `json:"name"`
-	Sub   string `json:"sub"`
-}
-
-var mockUsers = []MockUser{
-	{Email: "alice@example.com", Name: "Alice Smith", Sub: "alice"},
-	{Email: "bob@example.com", Name: "Bob Jones", Sub: "bob"},
-	{Email: "charlie@example.com", Name: "Charlie Brown", Sub: "charlie"},
-}
-
 // RecurrencePattern defines how a to-do item recurs
 type RecurrencePattern struct {
-	Frequency  string   `json:"frequency"`  // "daily", "weekly", "monthly"
-	Interval   int      `json:"interval"`   // Every N days/weeks/months
-	DaysOfWeek []string `json:"daysOfWeek"` // For weekly: ["Monday", "Wednesday", etc.]
+	Frequency      string      `json:"frequency"`                // "daily", "weekdays" (Monday to Friday), "weekly", "monthly", "yearly"
+	Interval       int         `json:"interval"`                 // Every N days/weekdays/weeks/months/years
+	DaysOfWeek     []string    `json:"daysOfWeek"`               // For weekly: ["Monday", "Wednesday", etc.]
+	DayOfMonth     int         `json:"dayOfMonth,omitempty"`     // For monthly: 1-31 (clamped to shorter months) or -1 for the last day; defaults to the start date's day
+	WeekOfMonth    int         `json:"weekOfMonth,omitempty"`    // For monthly: the nth (1-5) or last (-1) DayOfWeek, e.g. 2 and "Tuesday"; months without a fifth are skipped
+	DayOfWeek      string      `json:"dayOfWeek,omitempty"`      // For monthly: the weekday used with WeekOfMonth
+	ActiveMonths   []int       `json:"activeMonths,omitempty"`   // Months (1-12) occurrences may fall in, e.g. [4,5,6,7,8,9,10] for April-October; empty means all year
+	RRule          string      `json:"rrule,omitempty"`          // RFC 5545 RRULE, e.g. "FREQ=MONTHLY;BYDAY=-1FR"; overrides the fields above
+	ExceptionDates []time.Time `json:"exceptionDates,omitempty"` // Days (in StartDate's location) whose occurrences are skipped or were moved, like RRULE EXDATE
+	Mode           string      `json:"mode,omitempty"`           // "" to follow the calendar from StartDate, or "afterCompletion" to fall Interval days/weeks/months/years after the previous instance was completed
 }
 
 // TodoItem represents a to-do item
 type TodoItem struct {
-	ID              int                `json:"id"`
-	Title           string             `json:"title"`
-	Description     string             `json:"description"`
-	AssignedTo      []string           `json:"assignedTo"`
-	Completed       bool               `json:"completed"`
-	Position        int                `json:"position"`
-	IsRecurring     bool               `json:"isRecurring"`
-	RecurrenceID    *int               `json:"recurrenceId,omitempty"`
-	DueDate         *time.Time         `json:"dueDate,omitempty"`
-	CompletedAt     *time.Time         `json:"completedAt,omitempty"`
-	CreatedAt       time.Time          `json:"createdAt"`
+	ID                int        `json:"id"`
+	Title             string     `json:"title"`
+	Description       string     `json:"description"`
+	AssignedTo        []string   `json:"assignedTo"`
+	Completed         bool       `json:"completed"`
+	Position          int        `json:"position"`
+	IsRecurring       bool       `json:"isRecurring"`
+	RecurrenceID      *int       `json:"recurrenceId,omitempty"`
+	DueDate           *time.Time `json:"dueDate,omitempty"`
+	CompletedAt       *time.Time `json:"completedAt,omitempty"`
+	CreatedAt         time.Time  `json:"createdAt"`
+	NextInstanceID    *int       `json:"nextInstanceId,omitempty"`    // Instance created when this recurring item was completed
+	Skipped           bool       `json:"skipped,omitempty"`           // This recurring occurrence was skipped rather than done
+	OriginalDueDate   *time.Time `json:"originalDueDate,omitempty"`   // Scheduled due date of a recurring occurrence that was moved
+	CompletedBy       string     `json:"completedBy,omitempty"`       // Email of the user who completed the item
+	Missed            bool       `json:"missed,omitempty"`            // The next recurring occurrence fell due before this one was done
+	MissedOccurrences int        `json:"missedOccurrences,omitempty"` // Overdue occurrences folded into this one by the collapse missed policy
+	AllDay            bool       `json:"allDay,omitempty"`            // DueDate is the start of the day the item is due, not a time
 }
 
 // RecurringItemDefinition represents a recurring to-do item definition
 type RecurringItemDefinition struct {
-	ID          int                `json:"id"`
-	Title       string             `json:"title"`
-	Description string             `json:"description"`
-	AssignedTo  []string           `json:"assignedTo"`
-	Pattern     RecurrencePattern  `json:"pattern"`
-	StartDate   time.Time          `json:"startDate"`
-	CreatedAt   time.Time          `json:"createdAt"`
+	ID             int               `json:"id"`
+	Title          string            `json:"title"`
+	Description    string            `json:"description"`
+	AssignedTo     []string          `json:"assignedTo"`
+	Pattern        RecurrencePattern `json:"pattern"`
+	StartDate      time.Time         `json:"startDate"`
+	CreatedAt      time.Time         `json:"createdAt"`
+	EndDate        *time.Time        `json:"endDate,omitempty"`        // No occurrences after this time
+	MaxOccurrences int               `json:"maxOccurrences,omitempty"` // Number of occurrences from when the definition was created (or StartDate if later); 0 means unlimited
+	Finished       bool              `json:"finished"`                 // Set once every occurrence allowed by the end conditions has an instance
+	FinishedAt     *time.Time        `json:"finishedAt,omitempty"`
+	Paused         bool              `json:"paused"` // No instances are generated while paused
+	PausedAt       *time.Time        `json:"pausedAt,omitempty"`
+	Rotation       *AssigneeRotation `json:"rotation,omitempty"`      // Assign each instance to one of AssignedTo in turn
+	MissedPolicy   string            `json:"missedPolicy,omitempty"`  // What happens to missed occurrences: "" to just mark them, "collapse" into one overdue instance, "catchUp" or "skip"
+	MissedCount    int               `json:"missedCount"`             // Occurrences whose next occurrence fell due before they were done
+	TimeZone       string            `json:"timeZone,omitempty"`      // IANA time zone the recurrence is evaluated in; empty for DEFAULT_TIME_ZONE
+	AllDay         bool              `json:"allDay,omitempty"`        // Instances are due on a day (at midnight in TimeZone) rather than at a time
+	HolidayRegion  string            `json:"holidayRegion,omitempty"` // Holiday calendar whose holidays occurrences avoid; empty for none
+	HolidayRule    string            `json:"holidayRule,omitempty"`   // What happens to occurrences on a holiday: "" to move them to the next working day, or "skip"
 }
 
-// Store holds all data
-type Store struct {
-	mu                 sync.RWMutex
-	todos              map[int]*TodoItem
-	recurringDefs      map[int]*RecurringItemDefinition
-	nextTodoID         int
-	nextRecurringID    int
-}
-
-var store = &Store{
-	todos:           make(map[int]*TodoItem),
-	recurringDefs:   make(map[int]*RecurringItemDefinition),
-	nextTodoID:      1,
-	nextRecurringID: 1,
-}
+var store Store
 
 func main() {
 	// Load .env file if it exists (optional, no error if file doesn't exist)
@@ -103,11 +102,37 @@ func main() {
 		log.Println("Loaded environment variables from .env file")
 	}
 
+	// "migrate" applies pending schema migrations and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrations(getEnv("STORE", "memory")); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
 	// Initialize authentication configuration
 	if err := initAuthConfig(); err != nil {
 		log.Fatalf("Failed to initialize auth config: %v", err)
 	}
 
+	// Evaluate recurring definitions without a time zone in DEFAULT_TIME_ZONE
+	if err := initDefaultLocation(getEnv("DEFAULT_TIME_ZONE", "")); err != nil {
+		log.Fatalf("Failed to initialize time zone: %v", err)
+	}
+
+	// Load the holiday calendars recurring definitions can avoid
+	if err := initHolidayCalendars(getEnv("HOLIDAY_CALENDAR_DIR", "")); err != nil {
+		log.Fatalf("Failed to load holiday calendars: %v", err)
+	}
+
+	// Initialize storage (in-memory unless STORE is set)
+	var err error
+	autoMigrate := getEnv("STORE_AUTO_MIGRATE", "true") == "true"
+	store, err = newStore(getEnv("STORE", "memory"), autoMigrate)
+	if err != nil {
+		log.Fatalf("Failed to initialize store: %v", err)
+	}
+
 	r := mux.NewRouter()
 
 	// Enable CORS
@@ -116,13 +141,21 @@ func main() {
 	// Auth endpoints (public)
 	r.HandleFunc("/api/auth/config", getAuthConfig).Methods("GET")
 	r.HandleFunc("/api/auth/me", authMiddleware(getCurrentUser)).Methods("GET")
-	
+
 	// Dev mode OAuth2 endpoints
 	if authConfig.Mode == "dev" {
-		r.HandleFunc("/api/auth/dev/authorize", devAuthorize).Methods("GET")
+		r.HandleFunc("/api/auth/dev/authorize", devAuthorize).Methods("GET", "POST")
 		r.HandleFunc("/api/auth/dev/token", devToken).Methods("POST")
 		r.HandleFunc("/api/auth/dev/userinfo", devUserInfo).Methods("GET")
+		r.HandleFunc("/api/auth/dev/jwks", devJWKS).Methods("GET")
 		r.HandleFunc("/.well-known/openid-configuration", devOpenIDConfig).Methods("GET")
+
+		// Time travel for end-to-end tests
+		devTime := &devClock{}
+		clock = devTime
+		getClock, setClock := devClockHandlers(devTime)
+		r.HandleFunc("/api/dev/clock", authMiddleware(getClock)).Methods("GET")
+		r.HandleFunc("/api/dev/clock", authMiddleware(setClock)).Methods("POST")
 	}
 
 	// Protected Todo routes
@@ -134,17 +167,61 @@ func main() {
 	r.HandleFunc("/api/todos/{id}/convert-recurring", authMiddleware(convertTodoRecurring)).Methods("POST")
 
 	// Protected Recurring item routes
+	r.HandleFunc("/api/holidays", authMiddleware(getHolidayCalendars)).Methods("GET")
 	r.HandleFunc("/api/recurring", authMiddleware(getRecurringDefs)).Methods("GET")
 	r.HandleFunc("/api/recurring", authMiddleware(createRecurringDef)).Methods("POST")
+	r.HandleFunc("/api/recurring/scheduler", authMiddleware(getSchedulerStatus)).Methods("GET")
+	r.HandleFunc("/api/recurring/pause", authMiddleware(pauseRecurringDefsForAssignee)).Methods("POST")
+	r.HandleFunc("/api/recurring/resume", authMiddleware(resumeRecurringDefsForAssignee)).Methods("POST")
+	r.HandleFunc("/api/recurring/preview", authMiddleware(previewRecurringDef)).Methods("POST")
 	r.HandleFunc("/api/recurring/{id}", authMiddleware(updateRecurringDef)).Methods("PUT")
 	r.HandleFunc("/api/recurring/{id}", authMiddleware(deleteRecurringDef)).Methods("DELETE")
+	r.HandleFunc("/api/recurring/{id}/occurrences", authMiddleware(getRecurringOccurrences)).Methods("GET")
+	r.HandleFunc("/api/recurring/{id}/history", authMiddleware(getRecurringHistory)).Methods("GET")
+	r.HandleFunc("/api/recurring/{id}/exceptions", authMiddleware(createRecurringException)).Methods("POST")
+	r.HandleFunc("/api/recurring/{id}/pause", authMiddleware(pauseRecurringDef)).Methods("POST")
+	r.HandleFunc("/api/recurring/{id}/resume", authMiddleware(resumeRecurringDef)).Methods("POST")
 
 	port := 8080
-	log.Printf("Starting server on port %d with auth mode: %s", port, authConfig.Mode)
+	log.Printf("Starting server on port %d with auth mode: %s, store: %s", port, authConfig.Mode, redactStoreSpec(getEnv("STORE", "memory")))
 	if authConfig.Mode == "dev" {
-		log.Printf("Dev mode users: %v", mockUsers)
+		log.Printf("Dev mode users: %v", devUsers())
+	}
+
+	// Materialize upcoming recurring instances in the background
+	interval, horizon := schedulerConfigFromEnv()
+	scheduler = newRecurrenceScheduler(store, interval, horizon)
+	scheduler.Start()
+
+	srv := &http.Server{Addr: fmt.Sprintf(":%d", port), Handler: r}
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- srv.ListenAndServe()
+	}()
+
+	// Shut down cleanly on SIGINT/SIGTERM so the scheduler finishes its run
+	// and the store is flushed and closed
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	select {
+	case err := <-serverErr:
+		scheduler.Stop()
+		store.Close()
+		log.Fatal(err)
+	case <-ctx.Done():
+	}
+
+	log.Println("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP server shutdown: %v", err)
+	}
+	scheduler.Stop()
+	if err := store.Close(); err != nil {
+		log.Printf("Failed to close store: %v", err)
 	}
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", port), r))
 }
 
 func corsMiddleware(next http.Handler) http.Handler {
@@ -165,14 +242,13 @@ func corsMiddleware(next http.Handler) http.Handler {
 // initAuthConfig initializes authentication configuration from environment variables
 func initAuthConfig() error {
 	authConfig = &AuthConfig{
-		Mode:      getEnv("AUTH_MODE", "dev"),
-		TenantID:  getEnv("ENTRA_TENANT_ID", ""),
-		ClientID:  getEnv("ENTRA_CLIENT_ID", ""),
-		DevSecret: getEnv("DEV_AUTH_SECRET", generateSecret()),
+		Mode:     getEnv("AUTH_MODE", "dev"),
+		TenantID: getEnv("ENTRA_TENANT_ID", ""),
+		ClientID: getEnv("ENTRA_CLIENT_ID", ""),
 	}
 
 	// Parse allowed users from environment
-	allowedUsersStr := getEnv("ALLOWED_USERS", "alice@example.com,bob@example.com")
+	allowedUsersStr := getEnv("ALLOWED_USERS", "alice@example.com,bob@example.com,charlie@example.com")
 	authConfig.AllowedUsers = strings.Split(allowedUsersStr, ",")
 	for i := range authConfig.AllowedUsers {
 		authConfig.AllowedUsers[i] = strings.TrimSpace(authConfig.AllowedUsers[i])
@@ -193,6 +269,18 @@ func initAuthConfig() error {
 		authConfig.oidcVerifier = provider.Verifier(&oidc.Config{
 			ClientID: authConfig.ClientID,
 		})
+	} else {
+		// Dev tokens are verified the same way, against the mock provider's key
+		key, err := loadDevSigningKey(getEnv("DEV_AUTH_KEY_FILE", ""))
+		if err != nil {
+			return fmt.Errorf("failed to load DEV_AUTH_KEY_FILE: %w", err)
+		}
+		authConfig.devKey = key
+		authConfig.oidcVerifier = key.verifier()
+
+		if err := initMockUsers(getEnv("DEV_USERS_FILE", "")); err != nil {
+			return err
+		}
 	}
 
 	log.Printf("Auth configuration: mode=%s, allowed_users=%v", authConfig.Mode, authConfig.AllowedUsers)
@@ -206,14 +294,6 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
-func generateSecret() string {
-	b := make([]byte, 32)
-	if _, err := rand.Read(b); err != nil {
-		log.Fatalf("Failed to generate random secret: %v", err)
-	}
-	return base64.URLEncoding.EncodeToString(b)
-}
-
 // authMiddleware validates JWT tokens and checks user authorization
 func authMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -229,16 +309,7 @@ func authMiddleware(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		tokenString := parts[1]
-		var email string
-		var err error
-
-		if authConfig.Mode == "dev" {
-			email, err = validateDevToken(tokenString)
-		} else {
-			email, err = validateProdToken(r.Context(), tokenString)
-		}
-
+		email, err := validateToken(r.Context(), parts[1])
 		if err != nil {
 			log.Printf("Token validation failed: %v", err)
 			http.Error(w, fmt.Sprintf("Invalid token: %v", err), http.StatusUnauthorized)
@@ -258,29 +329,10 @@ func authMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
-func validateDevToken(tokenString string) (string, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(authConfig.DevSecret), nil
-	})
-
-	if err != nil {
-		return "", err
-	}
-
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		if email, ok := claims["email"].(string); ok {
-			return email, nil
-		}
-		return "", fmt.Errorf("email claim not found")
-	}
-
-	return "", fmt.Errorf("invalid token")
-}
-
-func validateProdToken(ctx context.Context, tokenString string) (string, error) {
+// validateToken verifies a token with the OIDC verifier, which checks Entra
+// ID tokens in prod mode and the mock provider's in dev mode, and returns
+// its email claim
+func validateToken(ctx context.Context, tokenString string) (string, error) {
 	idToken, err := authConfig.oidcVerifier.Verify(ctx, tokenString)
 	if err != nil {
 		return "", fmt.Errorf("failed to verify token: %w", err)
@@ -301,8 +353,24 @@ func validateProdToken(ctx context.Context, tokenString string) (string, error)
 	return claims.Email, nil
 }
 
+// allowedUsers returns the emails of the users allowed to use the app: those
+// in ALLOWED_USERS and, in dev mode, those listed in DEV_USERS_FILE, so the
+// file alone is enough to sign in as its users
+func allowedUsers() []string {
+	users := slices.Clip(authConfig.AllowedUsers)
+	if authConfig.Mode == "prod" {
+		return users
+	}
+	for _, u := range fileMockUsers() {
+		if !slices.ContainsFunc(users, func(email string) bool { return strings.EqualFold(email, u.Email) }) {
+			users = append(users, u.Email)
+		}
+	}
+	return users
+}
+
 func isUserAllowed(email string) bool {
-	for _, allowedEmail := range authConfig.AllowedUsers {
+	for _, allowedEmail := range allowedUsers() {
 		if strings.EqualFold(email, allowedEmail) {
 			return true
 		}
@@ -315,16 +383,16 @@ func isUserAllowed(email string) bool {
 func getAuthConfig(w http.ResponseWriter, r *http.Request) {
 	config := map[string]interface{}{
 		"mode":         authConfig.Mode,
-		"allowedUsers": authConfig.AllowedUsers,
+		"allowedUsers": allowedUsers(),
 	}
 
 	if authConfig.Mode == "prod" {
 		config["tenantId"] = authConfig.TenantID
 		config["clientId"] = authConfig.ClientID
 	} else {
-		config["authority"] = fmt.Sprintf("http://localhost:8080")
-		config["clientId"] = "dev-client-id"
-		config["users"] = mockUsers
+		config["authority"] = devIssuer
+		config["clientId"] = devClientID
+		config["users"] = devUsers()
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -333,7 +401,7 @@ func getAuthConfig(w http.ResponseWriter, r *http.Request) {
 
 func getCurrentUser(w http.ResponseWriter, r *http.Request) {
 	email := r.Context().Value("userEmail").(string)
-	
+
 	response := map[string]string{
 		"email": email,
 	}
@@ -342,145 +410,25 @@ func getCurrentUser(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-// Dev mode OAuth2 endpoints
-
-func devAuthorize(w http.ResponseWriter, r *http.Request) {
-	// In a real OAuth2 flow, this would present a login form
-	// For dev mode, we'll auto-approve with a mock user
-	redirectURI := r.URL.Query().Get("redirect_uri")
-	state := r.URL.Query().Get("state")
-
-	if redirectURI == "" {
-		http.Error(w, "redirect_uri is required", http.StatusBadRequest)
-		return
-	}
-
-	// Generate a mock authorization code
-	code := base64.URLEncoding.EncodeToString([]byte(fmt.Sprintf("code-%d", time.Now().Unix())))
-
-	// Redirect back with code
-	redirectURL := fmt.Sprintf("%s?code=%s&state=%s", redirectURI, code, state)
-	http.Redirect(w, r, redirectURL, http.StatusFound)
-}
-
-func devToken(w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseForm(); err != nil {
-		http.Error(w, "Invalid form data", http.StatusBadRequest)
-		return
-	}
-
-	grantType := r.FormValue("grant_type")
-	if grantType != "authorization_code" {
-		http.Error(w, "Unsupported grant_type", http.StatusBadRequest)
-		return
-	}
-
-	// Use first mock user by default
-	user := mockUsers[0]
-	
-	// Check if a specific user was requested (via username parameter)
-	if username := r.FormValue("username"); username != "" {
-		for _, u := range mockUsers {
-			if u.Sub == username {
-				user = u
-				break
-			}
-		}
-	}
-
-	// Generate JWT access token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub":   user.Sub,
-		"email": user.Email,
-		"name":  user.Name,
-		"iat":   time.Now().Unix(),
-		"exp":   time.Now().Add(24 * time.Hour).Unix(),
-	})
-
-	tokenString, err := token.SignedString([]byte(authConfig.DevSecret))
-	if err != nil {
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
-		return
-	}
-
-	response := map[string]interface{}{
-		"access_token": tokenString,
-		"token_type":   "Bearer",
-		"expires_in":   86400,
-		"id_token":     tokenString, // Same as access token for dev mode
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
-}
-
-func devUserInfo(w http.ResponseWriter, r *http.Request) {
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		http.Error(w, "Missing authorization header", http.StatusUnauthorized)
+// writeStoreError reports a store failure, mapping ErrNotFound to a 404 with
+// the given message
+func writeStoreError(w http.ResponseWriter, err error, notFoundMessage string) {
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, notFoundMessage, http.StatusNotFound)
 		return
 	}
-
-	parts := strings.Split(authHeader, " ")
-	if len(parts) != 2 || parts[0] != "Bearer" {
-		http.Error(w, "Invalid authorization header", http.StatusUnauthorized)
-		return
-	}
-
-	email, err := validateDevToken(parts[1])
-	if err != nil {
-		http.Error(w, "Invalid token", http.StatusUnauthorized)
-		return
-	}
-
-	// Find the user
-	var user *MockUser
-	for _, u := range mockUsers {
-		if u.Email == email {
-			user = &u
-			break
-		}
-	}
-
-	if user == nil {
-		http.Error(w, "User not found", http.StatusNotFound)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(user)
-}
-
-func devOpenIDConfig(w http.ResponseWriter, r *http.Request) {
-	baseURL := fmt.Sprintf("http://%s", r.Host)
-	
-	config := map[string]interface{}{
-		"issuer":                 baseURL,
-		"authorization_endpoint": baseURL + "/api/auth/dev/authorize",
-		"token_endpoint":         baseURL + "/api/auth/dev/token",
-		"userinfo_endpoint":      baseURL + "/api/auth/dev/userinfo",
-		"jwks_uri":               baseURL + "/api/auth/dev/jwks",
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(config)
+	log.Printf("Store error: %v", err)
+	http.Error(w, "Internal server error", http.StatusInternalServerError)
 }
 
 // getTodos returns all to-do items sorted by position
 func getTodos(w http.ResponseWriter, r *http.Request) {
-	store.mu.RLock()
-	defer store.mu.RUnlock()
-
-	todos := make([]*TodoItem, 0, len(store.todos))
-	for _, todo := range store.todos {
-		todos = append(todos, todo)
+	todos, err := store.ListTodos()
+	if err != nil {
+		writeStoreError(w, err, "Todo not found")
+		return
 	}
 
-	// Sort by position
-	sort.Slice(todos, func(i, j int) bool {
-		return todos[i].Position < todos[j].Position
-	})
-
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(todos)
 }
@@ -499,20 +447,25 @@ func createTodo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	store.mu.Lock()
-	defer store.mu.Unlock()
+	err := store.Update(func(tx Store) error {
+		todo.CreatedAt = clock.Now()
 
-	todo.ID = store.nextTodoID
-	store.nextTodoID++
-	todo.CreatedAt = time.Now()
+		// Set position to end if not specified
+		if todo.Position == 0 {
+			todos, err := tx.ListTodos()
+			if err != nil {
+				return err
+			}
+			todo.Position = len(todos)
+		}
 
-	// Set position to end if not specified
-	if todo.Position == 0 {
-		todo.Position = len(store.todos)
+		return tx.CreateTodo(&todo)
+	})
+	if err != nil {
+		writeStoreError(w, err, "Todo not found")
+		return
 	}
 
-	store.todos[todo.ID] = &todo
-
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(todo)
@@ -539,26 +492,50 @@ func updateTodo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	store.mu.Lock()
-	defer store.mu.Unlock()
+	var todo *TodoItem
+	err = store.Update(func(tx Store) error {
+		var err error
+		todo, err = tx.GetTodo(id)
+		if err != nil {
+			return err
+		}
 
-	todo, exists := store.todos[id]
-	if !exists {
-		http.Error(w, "Todo not found", http.StatusNotFound)
-		return
-	}
+		// Update fields
+		todo.Title = updates.Title
+		todo.Description = updates.Description
+		todo.AssignedTo = updates.AssignedTo
+		todo.Completed = updates.Completed
+		if !updates.Completed {
+			// Reopening clears the completion, so completing it again
+			// records who did it and when afresh
+			todo.CompletedAt = nil
+			todo.CompletedBy = ""
+		}
+		if updates.Completed && todo.CompletedAt == nil {
+			now := clock.Now()
+			todo.CompletedAt = &now
+			todo.CompletedBy, _ = r.Context().Value("userEmail").(string)
+			if err := creditRotationCompletion(tx, todo); err != nil {
+				return err
+			}
+		}
+		if updates.DueDate != nil {
+			todo.DueDate = updates.DueDate
+			todo.AllDay = updates.AllDay
+		}
 
-	// Update fields
-	todo.Title = updates.Title
-	todo.Description = updates.Description
-	todo.AssignedTo = updates.AssignedTo
-	todo.Completed = updates.Completed
-	if updates.Completed && todo.CompletedAt == nil {
-		now := time.Now()
-		todo.CompletedAt = &now
-	}
-	if updates.DueDate != nil {
-		todo.DueDate = updates.DueDate
+		// Completing a recurring instance creates the next one
+		if todo.Completed {
+			if err := spawnNextInstance(tx, todo); err != nil {
+				return err
+			}
+		}
+
+		return tx.UpdateTodo(todo)
+	})
+	if err != nil {
+		writeStoreError(w, err, "Todo not found")
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -574,37 +551,25 @@ func deleteTodo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	store.mu.Lock()
-	defer store.mu.Unlock()
-
-	if _, exists := store.todos[id]; !exists {
-		http.Error(w, "Todo not found", http.StatusNotFound)
+	if err := store.DeleteTodo(id); err != nil {
+		writeStoreError(w, err, "Todo not found")
 		return
 	}
 
-	delete(store.todos, id)
 	w.WriteHeader(http.StatusNoContent)
 }
 
 // reorderTodos updates the position of multiple to-do items
 func reorderTodos(w http.ResponseWriter, r *http.Request) {
-	var order []struct {
-		ID       int `json:"id"`
-		Position int `json:"position"`
-	}
-
+	var order []ReorderItem
 	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	store.mu.Lock()
-	defer store.mu.Unlock()
-
-	for _, item := range order {
-		if todo, exists := store.todos[item.ID]; exists {
-			todo.Position = item.Position
-		}
+	if err := store.ReorderTodos(order); err != nil {
+		writeStoreError(w, err, "Todo not found")
+		return
 	}
 
 	w.WriteHeader(http.StatusOK)
@@ -622,6 +587,7 @@ func convertTodoRecurring(w http.ResponseWriter, r *http.Request) {
 	var request struct {
 		ToRecurring bool              `json:"toRecurring"`
 		Pattern     RecurrencePattern `json:"pattern"`
+		TimeZone    string            `json:"timeZone,omitempty"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
@@ -635,47 +601,65 @@ func convertTodoRecurring(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		if err := validateTimeZone(&RecurringItemDefinition{TimeZone: request.TimeZone}); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 	}
 
-	store.mu.Lock()
-	defer store.mu.Unlock()
+	var todo *TodoItem
+	err = store.Update(func(tx Store) error {
+		var err error
+		todo, err = tx.GetTodo(id)
+		if err != nil {
+			return err
+		}
+
+		if request.ToRecurring {
+			// Convert to recurring item
+			// Create a recurring definition
+			def := &RecurringItemDefinition{
+				Title:       todo.Title,
+				Description: todo.Description,
+				AssignedTo:  todo.AssignedTo,
+				Pattern:     request.Pattern,
+				StartDate:   clock.Now(),
+				CreatedAt:   clock.Now(),
+				TimeZone:    request.TimeZone,
+				AllDay:      todo.AllDay,
+			}
+			if err := tx.CreateRecurringDef(def); err != nil {
+				return err
+			}
 
-	todo, exists := store.todos[id]
-	if !exists {
-		http.Error(w, "Todo not found", http.StatusNotFound)
+			// Update the todo to be recurring
+			todo.IsRecurring = true
+			todo.RecurrenceID = &def.ID
+			todo.DueDate = nil
+			if nextDueDate, ok := def.nextDueDate(clock.Now()); ok {
+				todo.DueDate = &nextDueDate
+			}
+		} else {
+			// Convert from recurring to one-off
+			todo.IsRecurring = false
+			if todo.RecurrenceID != nil {
+				// Optionally delete the recurring definition if this was the only instance
+				// For now, just unlink it
+				todo.RecurrenceID = nil
+			}
+			// Keep the current due date or clear it
+			todo.DueDate = nil
+		}
+
+		return tx.UpdateTodo(todo)
+	})
+	if err != nil {
+		writeStoreError(w, err, "Todo not found")
 		return
 	}
 
 	if request.ToRecurring {
-		// Convert to recurring item
-		// Create a recurring definition
-		def := &RecurringItemDefinition{
-			ID:          store.nextRecurringID,
-			Title:       todo.Title,
-			Description: todo.Description,
-			AssignedTo:  todo.AssignedTo,
-			Pattern:     request.Pattern,
-			StartDate:   time.Now(),
-			CreatedAt:   time.Now(),
-		}
-		store.nextRecurringID++
-		store.recurringDefs[def.ID] = def
-
-		// Update the todo to be recurring
-		todo.IsRecurring = true
-		todo.RecurrenceID = &def.ID
-		nextDueDate := calculateNextDueDate(def.StartDate, def.Pattern)
-		todo.DueDate = &nextDueDate
-	} else {
-		// Convert from recurring to one-off
-		todo.IsRecurring = false
-		if todo.RecurrenceID != nil {
-			// Optionally delete the recurring definition if this was the only instance
-			// For now, just unlink it
-			todo.RecurrenceID = nil
-		}
-		// Keep the current due date or clear it
-		todo.DueDate = nil
+		scheduler.Trigger()
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -684,12 +668,10 @@ func convertTodoRecurring(w http.ResponseWriter, r *http.Request) {
 
 // getRecurringDefs returns all recurring item definitions
 func getRecurringDefs(w http.ResponseWriter, r *http.Request) {
-	store.mu.RLock()
-	defer store.mu.RUnlock()
-
-	defs := make([]*RecurringItemDefinition, 0, len(store.recurringDefs))
-	for _, def := range store.recurringDefs {
-		defs = append(defs, def)
+	defs, err := store.ListRecurringDefs()
+	if err != nil {
+		writeStoreError(w, err, "Recurring definition not found")
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -710,37 +692,50 @@ func createRecurringDef(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	store.mu.Lock()
-	defer store.mu.Unlock()
-
-	def.ID = store.nextRecurringID
-	store.nextRecurringID++
-	def.CreatedAt = time.Now()
+	err := store.Update(func(tx Store) error {
+		now := clock.Now()
+		def.CreatedAt = now
+		def.Finished = false
+		def.FinishedAt = nil
+		def.Paused = false
+		def.PausedAt = nil
+		if err := tx.CreateRecurringDef(&def); err != nil {
+			return err
+		}
 
-	store.recurringDefs[def.ID] = &def
+		todos, err := tx.ListTodos()
+		if err != nil {
+			return err
+		}
 
-	// Create the first instance of this recurring item
-	nextDueDate := calculateNextDueDate(def.StartDate, def.Pattern)
-	todo := &TodoItem{
-		ID:           store.nextTodoID,
-		Title:        def.Title,
-		Description:  def.Description,
-		AssignedTo:   def.AssignedTo,
-		IsRecurring:  true,
-		RecurrenceID: &def.ID,
-		DueDate:      &nextDueDate,
-		Position:     len(store.todos),
-		CreatedAt:    time.Now(),
+		// Create the first instance of this recurring item, unless the
+		// pattern has already ended
+		after := now
+		if nextDueDate, ok := def.nextDueDate(after); ok {
+			if _, err := createRecurringInstance(tx, &def, nextDueDate, len(todos)); err != nil {
+				return err
+			}
+			after = nextDueDate
+		}
+		return refreshFinished(tx, &def, after)
+	})
+	if err != nil {
+		writeStoreError(w, err, "Recurring definition not found")
+		return
 	}
-	store.nextTodoID++
-	store.todos[todo.ID] = todo
+
+	scheduler.Trigger()
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(def)
 }
 
-// updateRecurringDef updates a recurring item definition
+// updateRecurringDef updates a recurring item definition. The optional
+// "scope" and "date" body fields choose what the edit applies to: the whole
+// series (the default, returning the definition), only the occurrence on date
+// (returning its instance), or that occurrence and every later one (returning
+// the new definition the series was split into).
 func updateRecurringDef(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
@@ -749,47 +744,73 @@ func updateRecurringDef(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var updates RecurringItemDefinition
-	if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
+	var request struct {
+		RecurringItemDefinition
+		Scope string     `json:"scope,omitempty"` // "all", "this" or "following"
+		Date  *time.Time `json:"date,omitempty"`  // Day of the occurrence for "this" and "following"
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	updates := request.RecurringItemDefinition
 
 	// Validate recurring definition
 	if err := validateRecurringDefinition(&updates); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-
-	store.mu.Lock()
-	defer store.mu.Unlock()
-
-	def, exists := store.recurringDefs[id]
-	if !exists {
-		http.Error(w, "Recurring definition not found", http.StatusNotFound)
+	switch request.Scope {
+	case "", editScopeAll:
+	case editScopeThis, editScopeFollowing:
+		if request.Date == nil {
+			http.Error(w, "date is required to edit part of a series", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "scope must be 'all', 'this' or 'following'", http.StatusBadRequest)
 		return
 	}
 
-	def.Title = updates.Title
-	def.Description = updates.Description
-	def.AssignedTo = updates.AssignedTo
-	def.Pattern = updates.Pattern
+	var response any
+	err = store.Update(func(tx Store) error {
+		def, err := tx.GetRecurringDef(id)
+		if err != nil {
+			return err
+		}
 
-	// Update all related todo items that haven't been completed
-	for _, todo := range store.todos {
-		if todo.RecurrenceID != nil && *todo.RecurrenceID == id && !todo.Completed {
-			todo.Title = def.Title
-			todo.Description = def.Description
-			todo.AssignedTo = def.AssignedTo
+		now := clock.Now()
+		switch request.Scope {
+		case editScopeThis:
+			response, err = editOccurrence(tx, def, &updates, *request.Date, now)
+			return err
+		case editScopeFollowing:
+			response, err = splitRecurringDef(tx, def, &updates, *request.Date, now)
+			return err
 		}
+
+		applyDefinitionUpdates(def, &updates)
+		response = def
+		return updateRecurringDefInstances(tx, def, now)
+	})
+	if errors.Is(err, errNotAnOccurrence) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
+	if err != nil {
+		writeStoreError(w, err, "Recurring definition not found")
+		return
+	}
+
+	scheduler.Trigger()
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(def)
+	json.NewEncoder(w).Encode(response)
 }
 
-// deleteRecurringDef deletes a recurring item definition
-func deleteRecurringDef(w http.ResponseWriter, r *http.Request) {
+// createRecurringException skips or moves a single occurrence of a recurring
+// item definition
+func createRecurringException(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
@@ -797,22 +818,96 @@ func deleteRecurringDef(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	store.mu.Lock()
-	defer store.mu.Unlock()
+	var request struct {
+		Date   time.Time  `json:"date"`             // Day of the occurrence
+		Action string     `json:"action"`           // "skip" or "move"
+		MoveTo *time.Time `json:"moveTo,omitempty"` // New due date when moving
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	if _, exists := store.recurringDefs[id]; !exists {
-		http.Error(w, "Recurring definition not found", http.StatusNotFound)
+	switch request.Action {
+	case "skip":
+		request.MoveTo = nil
+	case "move":
+		if request.MoveTo == nil {
+			http.Error(w, "moveTo is required to move an occurrence", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "action must be 'skip' or 'move'", http.StatusBadRequest)
 		return
 	}
 
-	delete(store.recurringDefs, id)
+	var response struct {
+		Definition *RecurringItemDefinition `json:"definition"`
+		Instance   *TodoItem                `json:"instance,omitempty"` // The skipped or moved instance
+	}
+	err = store.Update(func(tx Store) error {
+		var err error
+		response.Definition, err = tx.GetRecurringDef(id)
+		if err != nil {
+			return err
+		}
+		response.Instance, err = applyOccurrenceException(tx, response.Definition, request.Date, request.MoveTo)
+		if err != nil {
+			return err
+		}
 
-	// Remove recurrence link from related todos
-	for _, todo := range store.todos {
-		if todo.RecurrenceID != nil && *todo.RecurrenceID == id {
-			todo.RecurrenceID = nil
-			todo.IsRecurring = false
+		// Completing a skipped instance can finish the definition
+		response.Definition, err = tx.GetRecurringDef(id)
+		return err
+	})
+	if errors.Is(err, errNotAnOccurrence) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		writeStoreError(w, err, "Recurring definition not found")
+		return
+	}
+
+	scheduler.Trigger()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// deleteRecurringDef deletes a recurring item definition
+func deleteRecurringDef(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	err = store.Update(func(tx Store) error {
+		if err := tx.DeleteRecurringDef(id); err != nil {
+			return err
+		}
+
+		// Remove recurrence link from related todos
+		todos, err := tx.ListTodos()
+		if err != nil {
+			return err
+		}
+		for _, todo := range todos {
+			if todo.RecurrenceID != nil && *todo.RecurrenceID == id {
+				todo.RecurrenceID = nil
+				todo.IsRecurring = false
+				if err := tx.UpdateTodo(todo); err != nil {
+					return err
+				}
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		writeStoreError(w, err, "Recurring definition not found")
+		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
@@ -822,14 +917,37 @@ func deleteRecurringDef(w http.ResponseWriter, r *http.Request) {
 
 // validateRecurrencePattern validates a recurrence pattern
 func validateRecurrencePattern(pattern RecurrencePattern) error {
+	// Validate mode
+	switch pattern.Mode {
+	case recurrenceModeSchedule:
+	case recurrenceModeAfterCompletion:
+		// Only the frequency and interval say when the next instance is due
+		if pattern.RRule != "" || len(pattern.DaysOfWeek) > 0 || pattern.DayOfMonth != 0 ||
+			pattern.WeekOfMonth != 0 || len(pattern.ActiveMonths) > 0 || len(pattern.ExceptionDates) > 0 {
+			return fmt.Errorf("afterCompletion patterns only use frequency and interval")
+		}
+	default:
+		return fmt.Errorf("invalid mode: must be empty or 'afterCompletion'")
+	}
+
+	// An RRULE replaces the simple fields
+	if pattern.RRule != "" {
+		if len(pattern.ActiveMonths) > 0 {
+			return fmt.Errorf("activeMonths cannot be combined with rrule; use BYMONTH instead")
+		}
+		return validateRRule(pattern.RRule)
+	}
+
 	// Validate frequency
 	validFrequencies := map[string]bool{
-		"daily":   true,
-		"weekly":  true,
-		"monthly": true,
+		"daily":    true,
+		"weekdays": true,
+		"weekly":   true,
+		"monthly":  true,
+		"yearly":   true,
 	}
 	if !validFrequencies[pattern.Frequency] {
-		return fmt.Errorf("invalid frequency: must be 'daily', 'weekly', or 'monthly'")
+		return fmt.Errorf("invalid frequency: must be 'daily', 'weekdays', 'weekly', 'monthly', or 'yearly'")
 	}
 
 	// Validate interval
@@ -839,17 +957,45 @@ func validateRecurrencePattern(pattern RecurrencePattern) error {
 
 	// Validate days of week for weekly frequency
 	if pattern.Frequency == "weekly" && len(pattern.DaysOfWeek) > 0 {
-		validDays := map[string]bool{
-			"Sunday": true, "Monday": true, "Tuesday": true, "Wednesday": true,
-			"Thursday": true, "Friday": true, "Saturday": true,
-		}
 		for _, day := range pattern.DaysOfWeek {
-			if !validDays[day] {
+			if _, ok := weekdaysByName[day]; !ok {
 				return fmt.Errorf("invalid day of week: %s", day)
 			}
 		}
 	}
 
+	// Validate day of month and nth weekday rules
+	if pattern.DayOfMonth != 0 || pattern.WeekOfMonth != 0 || pattern.DayOfWeek != "" {
+		if pattern.Frequency != "monthly" {
+			return fmt.Errorf("dayOfMonth, weekOfMonth and dayOfWeek only apply to monthly patterns")
+		}
+		if pattern.DayOfMonth != 0 && pattern.WeekOfMonth != 0 {
+			return fmt.Errorf("dayOfMonth and weekOfMonth cannot be combined")
+		}
+	}
+	if pattern.DayOfMonth < -1 || pattern.DayOfMonth > 31 {
+		return fmt.Errorf("dayOfMonth must be between 1 and 31, or -1 for the last day of the month")
+	}
+	if pattern.WeekOfMonth < -1 || pattern.WeekOfMonth > 5 {
+		return fmt.Errorf("weekOfMonth must be between 1 and 5, or -1 for the last week of the month")
+	}
+	if (pattern.WeekOfMonth != 0) != (pattern.DayOfWeek != "") {
+		return fmt.Errorf("weekOfMonth and dayOfWeek must be given together")
+	}
+	if _, ok := weekdaysByName[pattern.DayOfWeek]; pattern.DayOfWeek != "" && !ok {
+		return fmt.Errorf("invalid day of week: %s", pattern.DayOfWeek)
+	}
+
+	// Validate the seasonal window
+	if len(pattern.ActiveMonths) > 0 && pattern.Frequency == "yearly" {
+		return fmt.Errorf("activeMonths do not apply to yearly patterns")
+	}
+	for _, month := range pattern.ActiveMonths {
+		if month < 1 || month > 12 {
+			return fmt.Errorf("activeMonths must be between 1 and 12")
+		}
+	}
+
 	return nil
 }
 
@@ -870,75 +1016,43 @@ func validateRecurringDefinition(def *RecurringItemDefinition) error {
 		return fmt.Errorf("title is required")
 	}
 
-	// Validate pattern
-	if err := validateRecurrencePattern(def.Pattern); err != nil {
-		return fmt.Errorf("invalid pattern: %w", err)
+	if err := validateRotation(def); err != nil {
+		return fmt.Errorf("invalid rotation: %w", err)
+	}
+	if err := validateMissedPolicy(def); err != nil {
+		return err
 	}
 
-	return nil
+	return validateRecurrenceSchedule(def)
 }
 
-// calculateNextDueDate calculates the next due date based on a pattern
-func calculateNextDueDate(startDate time.Time, pattern RecurrencePattern) time.Time {
-	now := time.Now()
-	nextDate := startDate
-
-	// For weekly recurrence with specific days of week
-	if pattern.Frequency == "weekly" && len(pattern.DaysOfWeek) > 0 {
-		return calculateNextWeeklyDate(now, pattern.DaysOfWeek, pattern.Interval)
+// validateRecurrenceSchedule validates a recurring item definition's pattern
+// and end conditions
+func validateRecurrenceSchedule(def *RecurringItemDefinition) error {
+	// Validate pattern
+	if err := validateRecurrencePattern(def.Pattern); err != nil {
+		return fmt.Errorf("invalid pattern: %w", err)
 	}
-
-	for nextDate.Before(now) {
-		switch pattern.Frequency {
-		case "daily":
-			nextDate = nextDate.AddDate(0, 0, pattern.Interval)
-		case "weekly":
-			nextDate = nextDate.AddDate(0, 0, 7*pattern.Interval)
-		case "monthly":
-			nextDate = nextDate.AddDate(0, pattern.Interval, 0)
-		}
+	if err := validateTimeZone(def); err != nil {
+		return err
 	}
-
-	return nextDate
-}
-
-// calculateNextWeeklyDate finds the next occurrence based on specific days of week
-func calculateNextWeeklyDate(from time.Time, daysOfWeek []string, interval int) time.Time {
-	// Map day names to time.Weekday
-	dayMap := map[string]time.Weekday{
-		"Sunday":    time.Sunday,
-		"Monday":    time.Monday,
-		"Tuesday":   time.Tuesday,
-		"Wednesday": time.Wednesday,
-		"Thursday":  time.Thursday,
-		"Friday":    time.Friday,
-		"Saturday":  time.Saturday,
+	if err := validateHolidayRule(def); err != nil {
+		return err
 	}
 
-	// Convert string days to weekday numbers
-	targetDays := make(map[time.Weekday]bool)
-	for _, day := range daysOfWeek {
-		if wd, ok := dayMap[day]; ok {
-			targetDays[wd] = true
-		}
+	// Validate end conditions
+	if def.MaxOccurrences < 0 {
+		return fmt.Errorf("maxOccurrences must not be negative")
 	}
-
-	if len(targetDays) == 0 {
-		// Fallback to regular weekly if no valid days
-		return from.AddDate(0, 0, 7*interval)
+	if def.MaxOccurrences > maxOccurrencesLimit {
+		return fmt.Errorf("maxOccurrences must be at most %d", maxOccurrencesLimit)
 	}
-
-	// For simplicity with interval=1, just find the next matching day
-	// For interval>1, we'd need more complex logic
-	nextDate := from.AddDate(0, 0, 1)
-	
-	for i := 0; i < 14; i++ { // Check up to 2 weeks ahead
-		if targetDays[nextDate.Weekday()] {
-			return nextDate
-		}
-		nextDate = nextDate.AddDate(0, 0, 1)
+	if def.MaxOccurrences > 0 && def.Pattern.Mode == recurrenceModeAfterCompletion {
+		return fmt.Errorf("maxOccurrences cannot be used with afterCompletion patterns; use endDate instead")
+	}
+	if def.EndDate != nil && def.EndDate.Before(def.StartDate) {
+		return fmt.Errorf("endDate must not be before startDate")
 	}
 
-	// Fallback
-	return from.AddDate(0, 0, 7*interval)
+	return nil
 }
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema migrations live in migrations/<dialect>/NNNN_description.sql. They
+// are forward-only: each file is applied once, in version order, inside its
+// own transaction, and recorded in the schema_migrations table. Never edit a
+// migration that has been released; add a new one instead.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// postgresMigrationLockID is the advisory lock key that stops several
+// replicas from migrating the same database at once
+const postgresMigrationLockID = 7269746
+
+// migration is a single versioned schema change
+type migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// loadMigrations returns the migrations for a dialect sorted by version
+func loadMigrations(dialect sqlDialect) ([]migration, error) {
+	dir := path.Join("migrations", string(dialect))
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations for %s: %w", dialect, err)
+	}
+
+	var migrations []migration
+	seen := make(map[int]string)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+
+		name := strings.TrimSuffix(entry.Name(), ".sql")
+		versionStr, _, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(versionStr)
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("invalid migration file name %q: must start with a positive version number", entry.Name())
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("duplicate migration version %d: %s and %s", version, other, name)
+		}
+		seen[version] = name
+
+		content, err := fs.ReadFile(migrationFiles, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", name, err)
+		}
+
+		migrations = append(migrations, migration{Version: version, Name: name, SQL: string(content)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// migrateDatabase applies any pending migrations and returns those applied
+func migrateDatabase(db *sql.DB, dialect sqlDialect) ([]migration, error) {
+	ctx := context.Background()
+
+	migrations, err := loadMigrations(dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pin a single connection so the advisory lock and the migrations share
+	// a session
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection for migrations: %w", err)
+	}
+	defer conn.Close()
+
+	if dialect == dialectPostgres {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, postgresMigrationLockID); err != nil {
+			return nil, fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		defer conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, postgresMigrationLockID)
+	}
+
+	current, err := schemaVersion(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	if latest := latestVersion(migrations); current > latest {
+		return nil, fmt.Errorf("database schema version %d is newer than this build supports (%d)", current, latest)
+	}
+
+	var applied []migration
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return applied, fmt.Errorf("failed to begin migration %s: %w", m.Name, err)
+		}
+		if _, err := tx.Exec(m.SQL); err != nil {
+			tx.Rollback()
+			return applied, fmt.Errorf("failed to apply migration %s: %w", m.Name, err)
+		}
+		if _, err := tx.Exec(dialect.rebind(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`),
+			m.Version, m.Name, time.Now().UTC()); err != nil {
+			tx.Rollback()
+			return applied, fmt.Errorf("failed to record migration %s: %w", m.Name, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return applied, fmt.Errorf("failed to commit migration %s: %w", m.Name, err)
+		}
+
+		applied = append(applied, m)
+	}
+
+	return applied, nil
+}
+
+// pendingMigrations returns the migrations that have not yet been applied
+func pendingMigrations(db *sql.DB, dialect sqlDialect) ([]migration, error) {
+	ctx := context.Background()
+
+	migrations, err := loadMigrations(dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	current, err := schemaVersion(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []migration
+	for _, m := range migrations {
+		if m.Version > current {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// schemaVersion creates the schema_migrations table if needed and returns the
+// highest applied version (0 for a new database)
+func schemaVersion(ctx context.Context, conn *sql.Conn) (int, error) {
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	var version sql.NullInt64
+	if err := conn.QueryRowContext(ctx, `SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+func latestVersion(migrations []migration) int {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+// runMigrations implements the migrate subcommand
+func runMigrations(spec string) error {
+	s, err := openStore(spec)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	sqlS, ok := s.(*sqlStore)
+	if !ok {
+		log.Printf("STORE=%s has no schema to migrate", spec)
+		return nil
+	}
+
+	applied, err := migrateDatabase(sqlS.db, sqlS.dialect)
+	for _, m := range applied {
+		log.Printf("Applied migration %s", m.Name)
+	}
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		log.Println("Database schema is up to date")
+	}
+	return nil
+}
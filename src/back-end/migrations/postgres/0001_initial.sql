@@ -0,0 +1,25 @@
+CREATE TABLE todos (
+	id            BIGSERIAL PRIMARY KEY,
+	title         TEXT NOT NULL,
+	description   TEXT NOT NULL DEFAULT '',
+	assigned_to   TEXT NOT NULL DEFAULT 'null',
+	completed     BOOLEAN NOT NULL DEFAULT FALSE,
+	position      INTEGER NOT NULL DEFAULT 0,
+	is_recurring  BOOLEAN NOT NULL DEFAULT FALSE,
+	recurrence_id BIGINT,
+	due_date      TIMESTAMPTZ,
+	completed_at  TIMESTAMPTZ,
+	created_at    TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX todos_recurrence_id_idx ON todos (recurrence_id);
+
+CREATE TABLE recurring_defs (
+	id          BIGSERIAL PRIMARY KEY,
+	title       TEXT NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	assigned_to TEXT NOT NULL DEFAULT 'null',
+	pattern     TEXT NOT NULL,
+	start_date  TIMESTAMPTZ NOT NULL,
+	created_at  TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,4 @@
+-- Optional end conditions for recurring definitions, and when they ran out
+ALTER TABLE recurring_defs ADD COLUMN end_date TIMESTAMPTZ;
+ALTER TABLE recurring_defs ADD COLUMN max_occurrences INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE recurring_defs ADD COLUMN finished_at TIMESTAMPTZ;
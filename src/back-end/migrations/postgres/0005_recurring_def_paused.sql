@@ -0,0 +1,2 @@
+-- When a recurring definition was paused; NULL while it is active
+ALTER TABLE recurring_defs ADD COLUMN paused_at TIMESTAMPTZ;
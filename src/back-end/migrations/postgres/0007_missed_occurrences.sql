@@ -0,0 +1,6 @@
+-- Missed-occurrence policy and count for recurring definitions, and which
+-- instances were missed
+ALTER TABLE recurring_defs ADD COLUMN missed_policy TEXT NOT NULL DEFAULT '';
+ALTER TABLE recurring_defs ADD COLUMN missed_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE todos ADD COLUMN missed BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE todos ADD COLUMN missed_occurrences INTEGER NOT NULL DEFAULT 0;
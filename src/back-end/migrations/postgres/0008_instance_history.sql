@@ -0,0 +1,26 @@
+-- History of every instance a recurring definition has had, kept after the
+-- instance is deleted or unlinked. Existing instances are backfilled.
+CREATE TABLE instance_history (
+	todo_id            BIGINT PRIMARY KEY,
+	recurrence_id      BIGINT NOT NULL,
+	title              TEXT NOT NULL,
+	assigned_to        TEXT NOT NULL DEFAULT 'null',
+	due_date           TIMESTAMPTZ,
+	original_due_date  TIMESTAMPTZ,
+	completed          BOOLEAN NOT NULL DEFAULT FALSE,
+	completed_at       TIMESTAMPTZ,
+	completed_by       TEXT NOT NULL DEFAULT '',
+	skipped            BOOLEAN NOT NULL DEFAULT FALSE,
+	missed             BOOLEAN NOT NULL DEFAULT FALSE,
+	missed_occurrences INTEGER NOT NULL DEFAULT 0,
+	created_at         TIMESTAMPTZ NOT NULL,
+	removed_at         TIMESTAMPTZ
+);
+
+CREATE INDEX instance_history_recurrence_id_idx ON instance_history (recurrence_id);
+
+INSERT INTO instance_history (todo_id, recurrence_id, title, assigned_to, due_date, original_due_date,
+	completed, completed_at, completed_by, skipped, missed, missed_occurrences, created_at)
+SELECT id, recurrence_id, title, assigned_to, due_date, original_due_date,
+	completed, completed_at, completed_by, skipped, missed, missed_occurrences, created_at
+FROM todos WHERE recurrence_id IS NOT NULL;
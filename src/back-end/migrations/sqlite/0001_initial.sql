@@ -0,0 +1,27 @@
+-- Initial schema. IF NOT EXISTS keeps this compatible with databases created
+-- before migrations were introduced.
+CREATE TABLE IF NOT EXISTS todos (
+	id            INTEGER PRIMARY KEY AUTOINCREMENT,
+	title         TEXT NOT NULL,
+	description   TEXT NOT NULL DEFAULT '',
+	assigned_to   TEXT NOT NULL DEFAULT 'null',
+	completed     BOOLEAN NOT NULL DEFAULT 0,
+	position      INTEGER NOT NULL DEFAULT 0,
+	is_recurring  BOOLEAN NOT NULL DEFAULT 0,
+	recurrence_id INTEGER,
+	due_date      TIMESTAMP,
+	completed_at  TIMESTAMP,
+	created_at    TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS todos_recurrence_id_idx ON todos (recurrence_id);
+
+CREATE TABLE IF NOT EXISTS recurring_defs (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	title       TEXT NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	assigned_to TEXT NOT NULL DEFAULT 'null',
+	pattern     TEXT NOT NULL,
+	start_date  TIMESTAMP NOT NULL,
+	created_at  TIMESTAMP NOT NULL
+);
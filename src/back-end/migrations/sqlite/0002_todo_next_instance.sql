@@ -0,0 +1,2 @@
+-- Links a completed recurring instance to the instance spawned after it
+ALTER TABLE todos ADD COLUMN next_instance_id INTEGER;
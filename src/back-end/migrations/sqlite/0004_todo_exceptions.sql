@@ -0,0 +1,3 @@
+-- Records recurring instances that were skipped or moved off their scheduled date
+ALTER TABLE todos ADD COLUMN skipped BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE todos ADD COLUMN original_due_date TIMESTAMP;
@@ -0,0 +1,3 @@
+-- Assignee rotation state for recurring definitions, and who completed each item
+ALTER TABLE recurring_defs ADD COLUMN rotation TEXT NOT NULL DEFAULT 'null';
+ALTER TABLE todos ADD COLUMN completed_by TEXT NOT NULL DEFAULT '';
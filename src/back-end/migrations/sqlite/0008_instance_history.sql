@@ -0,0 +1,26 @@
+-- History of every instance a recurring definition has had, kept after the
+-- instance is deleted or unlinked. Existing instances are backfilled.
+CREATE TABLE instance_history (
+	todo_id            INTEGER PRIMARY KEY,
+	recurrence_id      INTEGER NOT NULL,
+	title              TEXT NOT NULL,
+	assigned_to        TEXT NOT NULL DEFAULT 'null',
+	due_date           TIMESTAMP,
+	original_due_date  TIMESTAMP,
+	completed          BOOLEAN NOT NULL DEFAULT 0,
+	completed_at       TIMESTAMP,
+	completed_by       TEXT NOT NULL DEFAULT '',
+	skipped            BOOLEAN NOT NULL DEFAULT 0,
+	missed             BOOLEAN NOT NULL DEFAULT 0,
+	missed_occurrences INTEGER NOT NULL DEFAULT 0,
+	created_at         TIMESTAMP NOT NULL,
+	removed_at         TIMESTAMP
+);
+
+CREATE INDEX instance_history_recurrence_id_idx ON instance_history (recurrence_id);
+
+INSERT INTO instance_history (todo_id, recurrence_id, title, assigned_to, due_date, original_due_date,
+	completed, completed_at, completed_by, skipped, missed, missed_occurrences, created_at)
+SELECT id, recurrence_id, title, assigned_to, due_date, original_due_date,
+	completed, completed_at, completed_by, skipped, missed, missed_occurrences, created_at
+FROM todos WHERE recurrence_id IS NOT NULL;
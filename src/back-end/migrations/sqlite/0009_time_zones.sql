@@ -0,0 +1,4 @@
+-- Time zone recurring definitions are evaluated in, and all-day due dates
+ALTER TABLE recurring_defs ADD COLUMN time_zone TEXT NOT NULL DEFAULT '';
+ALTER TABLE recurring_defs ADD COLUMN all_day BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE todos ADD COLUMN all_day BOOLEAN NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+-- Holiday calendar recurring definitions avoid, and what happens to their
+-- occurrences on its holidays
+ALTER TABLE recurring_defs ADD COLUMN holiday_region TEXT NOT NULL DEFAULT '';
+ALTER TABLE recurring_defs ADD COLUMN holiday_rule TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// An occurrence is missed when the next one falls due before it was done.
+// Each definition's missed policy decides what happens to it: by default its
+// instances are left as they are, or its overdue instances collapse into one,
+// every missed occurrence keeps an instance to catch up on, or missed
+// instances are recorded and put aside. Either way the definition counts its
+// misses in MissedCount and the instances are marked Missed. No policy
+// deletes an instance, as users may have renamed, reassigned or moved it. The scheduler applies the policy on each run; completing an
+// instance late applies it to occurrences that never had an instance.
+
+// Missed-occurrence policies
+const (
+	missedPolicyRecord   = ""         // Missed instances are marked and left pending (the default)
+	missedPolicyCollapse = "collapse" // Overdue instances are skipped in favour of the most recent one
+	missedPolicyCatchUp  = "catchUp"  // Every missed occurrence keeps its own instance
+	missedPolicySkip     = "skip"     // Missed instances are marked done as missed and the series moves on
+)
+
+// validateMissedPolicy validates a recurring definition's missed policy
+func validateMissedPolicy(def *RecurringItemDefinition) error {
+	switch def.MissedPolicy {
+	case missedPolicyRecord:
+		return nil
+	case missedPolicyCollapse, missedPolicyCatchUp, missedPolicySkip:
+		if def.Pattern.Mode == recurrenceModeAfterCompletion {
+			return fmt.Errorf("missedPolicy does not apply to afterCompletion patterns, which are never missed")
+		}
+		return nil
+	default:
+		return fmt.Errorf("missedPolicy must be empty, 'collapse', 'catchUp' or 'skip'")
+	}
+}
+
+// scheduledDueDate returns when todo's occurrence was scheduled, before any
+// move
+func scheduledDueDate(todo *TodoItem) time.Time {
+	if todo.OriginalDueDate != nil {
+		return *todo.OriginalDueDate
+	}
+	return *todo.DueDate
+}
+
+// isMissed reports whether the occurrence scheduled at due was missed by now,
+// i.e. the next one has fallen due. end is the definition's occurrence limit.
+func (d *RecurringItemDefinition) isMissed(due, now time.Time, end occurrenceLimit) bool {
+	next, ok := d.nextDueDateWithin(due, end)
+	return ok && !next.After(now)
+}
+
+// applyMissedPolicy marks def's pending instances whose occurrence was missed
+// by now, counting them in def.MissedCount, and then applies def's policy to
+// them
+func applyMissedPolicy(tx Store, def *RecurringItemDefinition, now time.Time) error {
+	if def.Paused || def.Pattern.Mode == recurrenceModeAfterCompletion {
+		return nil
+	}
+
+	todos, err := tx.ListTodos()
+	if err != nil {
+		return err
+	}
+
+	// Pending instances that have fallen due, oldest first
+	var overdue []*TodoItem
+	for _, todo := range todos {
+		if todo.RecurrenceID != nil && *todo.RecurrenceID == def.ID && !todo.Completed &&
+			todo.DueDate != nil && !todo.DueDate.After(now) {
+			overdue = append(overdue, todo)
+		}
+	}
+	sort.Slice(overdue, func(i, j int) bool { return overdue[i].DueDate.Before(*overdue[j].DueDate) })
+
+	end := def.findOccurrenceLimit()
+	missed := 0
+	for _, todo := range overdue {
+		if todo.Missed || !def.isMissed(*todo.DueDate, now, end) {
+			continue
+		}
+		todo.Missed = true
+		missed++
+		if def.MissedPolicy == missedPolicySkip {
+			todo.Completed = true
+		}
+		if err := tx.UpdateTodo(todo); err != nil {
+			return err
+		}
+	}
+
+	// Fold every other overdue instance into the most recent one, skipping
+	// them so they are kept as a record rather than deleted
+	if def.MissedPolicy == missedPolicyCollapse && len(overdue) > 1 {
+		kept := overdue[len(overdue)-1]
+		for _, todo := range overdue[:len(overdue)-1] {
+			todo.Skipped = true
+			todo.Completed = true
+			todo.CompletedAt = &now
+			if err := tx.UpdateTodo(todo); err != nil {
+				return err
+			}
+			kept.MissedOccurrences += 1 + todo.MissedOccurrences
+		}
+		if err := tx.UpdateTodo(kept); err != nil {
+			return err
+		}
+	}
+
+	if missed == 0 {
+		return nil
+	}
+	def.MissedCount += missed
+	return tx.UpdateRecurringDef(def)
+}
+
+// countUninstancedMisses counts the occurrences of def after due that were
+// missed by now but never had an instance, e.g. because the scheduler is
+// disabled and todo was completed late. Its policy then skips over them.
+func countUninstancedMisses(def *RecurringItemDefinition, todos []*TodoItem, due, now time.Time) int {
+	end := def.findOccurrenceLimit()
+	missed := 0
+	for _, occurrence := range def.occurrencesBetween(due, now, maxInstancesPerDefinition) {
+		if !def.isMissed(occurrence, now, end) || hasInstanceFor(todos, def.ID, occurrence) {
+			continue
+		}
+		missed++
+	}
+	return missed
+}
+
+// hasInstanceFor reports whether one of todos is the instance of the given
+// definition scheduled at occurrence
+func hasInstanceFor(todos []*TodoItem, defID int, occurrence time.Time) bool {
+	for _, todo := range todos {
+		if todo.RecurrenceID != nil && *todo.RecurrenceID == defID && todo.DueDate != nil &&
+			scheduledDueDate(todo).Equal(occurrence) {
+			return true
+		}
+	}
+	return false
+}
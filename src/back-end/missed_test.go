@@ -0,0 +1,142 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// createOverdueDefinition stores a daily definition due at 10:00 on 2 March
+// 2026 with the given missed policy, and materializes its instances up to 5
+// March as the scheduler would have done before anyone fell behind
+func createOverdueDefinition(t *testing.T, s Store, policy string) *RecurringItemDefinition {
+	t.Helper()
+
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	def, _ := createDailyDefinition(t, s, start.Add(time.Hour))
+	def.MissedPolicy = policy
+	if err := s.UpdateRecurringDef(def); err != nil {
+		t.Fatalf("UpdateRecurringDef: %v", err)
+	}
+	if _, err := materializeUpcoming(s, start, 4*24*time.Hour); err != nil {
+		t.Fatalf("materializeUpcoming: %v", err)
+	}
+	return def
+}
+
+func TestMissedPolicies(t *testing.T) {
+	// By noon on 5 March the first three occurrences have been missed and
+	// the fourth is due
+	now := time.Date(2026, 3, 5, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		policy        string
+		wantPending   int
+		wantCompleted int
+	}{
+		{missedPolicyRecord, 4, 0},
+		{missedPolicyCollapse, 1, 3},
+		{missedPolicyCatchUp, 4, 0},
+		{missedPolicySkip, 1, 3},
+	}
+	for _, tt := range tests {
+		name := tt.policy
+		if name == missedPolicyRecord {
+			name = "record"
+		}
+		t.Run(name, func(t *testing.T) {
+			s := newMemoryStore()
+			def := createOverdueDefinition(t, s, tt.policy)
+
+			if _, err := materializeUpcoming(s, now, time.Hour); err != nil {
+				t.Fatalf("materializeUpcoming: %v", err)
+			}
+			// A second run must not count the same misses again
+			if _, err := materializeUpcoming(s, now, time.Hour); err != nil {
+				t.Fatalf("materializeUpcoming: %v", err)
+			}
+
+			saved, err := s.GetRecurringDef(def.ID)
+			if err != nil {
+				t.Fatalf("GetRecurringDef: %v", err)
+			}
+			if saved.MissedCount != 3 {
+				t.Errorf("MissedCount = %d, want 3", saved.MissedCount)
+			}
+
+			pending, completed := 0, 0
+			var latest *TodoItem
+			for _, todo := range instancesOf(t, s, def.ID) {
+				if todo.Completed {
+					completed++
+					if !todo.Missed {
+						t.Errorf("instance due %v completed without being missed", todo.DueDate)
+					}
+					if tt.policy == missedPolicyCollapse && !todo.Skipped {
+						t.Errorf("collapsed instance due %v was not marked skipped", todo.DueDate)
+					}
+					continue
+				}
+				pending++
+				if latest == nil || todo.DueDate.After(*latest.DueDate) {
+					latest = todo
+				}
+			}
+			if pending != tt.wantPending || completed != tt.wantCompleted {
+				t.Errorf("got %d pending and %d completed instances, want %d and %d", pending, completed, tt.wantPending, tt.wantCompleted)
+			}
+			// Today's instance plus the three missed, none of them deleted
+			if pending+completed != 4 {
+				t.Errorf("got %d instances, want 4", pending+completed)
+			}
+			if latest == nil || !latest.DueDate.Equal(time.Date(2026, 3, 5, 10, 0, 0, 0, time.UTC)) || latest.Missed {
+				t.Errorf("latest pending instance = %+v, want today's, not missed", latest)
+			}
+			if tt.policy == missedPolicyCollapse && latest != nil && latest.MissedOccurrences != 3 {
+				t.Errorf("collapsed instance stands for %d missed occurrences, want 3", latest.MissedOccurrences)
+			}
+		})
+	}
+}
+
+func TestLateCompletionCountsMisses(t *testing.T) {
+	s := newMemoryStore()
+	due := time.Now().Add(-73 * time.Hour).Truncate(time.Second)
+	def, todo := createDailyDefinition(t, s, due)
+
+	// The two occurrences in between were missed; the third is still current
+	completeTodo(t, s, todo)
+
+	saved, err := s.GetRecurringDef(def.ID)
+	if err != nil {
+		t.Fatalf("GetRecurringDef: %v", err)
+	}
+	if saved.MissedCount != 2 {
+		t.Errorf("MissedCount = %d, want 2", saved.MissedCount)
+	}
+	next, err := s.GetTodo(*todo.NextInstanceID)
+	if err != nil {
+		t.Fatalf("GetTodo: %v", err)
+	}
+	if want := due.AddDate(0, 0, 4); !next.DueDate.Equal(want) {
+		t.Errorf("next instance due %v, want %v", next.DueDate, want)
+	}
+}
+
+func TestLateCompletionCatchesUp(t *testing.T) {
+	s := newMemoryStore()
+	due := time.Now().Add(-73 * time.Hour).Truncate(time.Second)
+	def, todo := createDailyDefinition(t, s, due)
+	def.MissedPolicy = missedPolicyCatchUp
+	if err := s.UpdateRecurringDef(def); err != nil {
+		t.Fatalf("UpdateRecurringDef: %v", err)
+	}
+
+	completeTodo(t, s, todo)
+
+	next, err := s.GetTodo(*todo.NextInstanceID)
+	if err != nil {
+		t.Fatalf("GetTodo: %v", err)
+	}
+	if want := due.AddDate(0, 0, 1); !next.DueDate.Equal(want) {
+		t.Errorf("next instance due %v, want the next missed occurrence %v", next.DueDate, want)
+	}
+}
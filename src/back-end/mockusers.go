@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/mail"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// The dev mode users are Alice, Bob and Charlie unless DEV_USERS_FILE names a
+// JSON file listing them, so each test suite can define its own. The file is
+// checked whenever the users are needed and reloaded if it has changed; a
+// change that doesn't load is logged and the previous users kept.
+
+// MockUser represents a test user for development mode
+type MockUser struct {
+	Email  string         `json:"email"`
+	Name   string         `json:"name"`
+	Sub    string         `json:"sub"`
+	Roles  []string       `json:"roles,omitempty"`  // Issued as the "roles" claim, as Entra ID app roles are
+	Groups []string       `json:"groups,omitempty"` // Issued as the "groups" claim
+	Claims map[string]any `json:"claims,omitempty"` // Extra claims for the user's tokens
+}
+
+var defaultMockUsers = []MockUser{
+	{Email: "alice@example.com", Name: "Alice Smith", Sub: "alice"},
+	{Email: "bob@example.com", Name: "Bob Jones", Sub: "bob"},
+	{Email: "charlie@example.com", Name: "Charlie Brown", Sub: "charlie"},
+}
+
+// reservedClaims are the claims the mock provider sets itself, which a
+// user's extra claims cannot replace
+var reservedClaims = map[string]bool{
+	"iss": true, "aud": true, "sub": true, "email": true, "name": true, "roles": true, "groups": true,
+	"iat": true, "exp": true, "nbf": true, "nonce": true,
+}
+
+// tokenClaims returns the claims that describe the user in its tokens
+func (u MockUser) tokenClaims() jwt.MapClaims {
+	claims := jwt.MapClaims{}
+	for name, value := range u.Claims {
+		claims[name] = value
+	}
+	claims["sub"] = u.Sub
+	claims["email"] = u.Email
+	claims["name"] = u.Name
+	if len(u.Roles) > 0 {
+		claims["roles"] = u.Roles
+	}
+	if len(u.Groups) > 0 {
+		claims["groups"] = u.Groups
+	}
+	return claims
+}
+
+// mockUserSource holds the dev mode users and the file they came from
+var mockUserSource = struct {
+	sync.Mutex
+	users   []MockUser
+	path    string // DEV_USERS_FILE, or empty for the default users
+	modTime time.Time
+	size    int64
+	lastErr string // The last reload error logged, so it is logged once
+}{users: defaultMockUsers}
+
+// initMockUsers loads the dev mode users from the DEV_USERS_FILE value path,
+// or uses the default users if it is empty
+func initMockUsers(path string) error {
+	mockUserSource.Lock()
+	defer mockUserSource.Unlock()
+	if path == "" {
+		mockUserSource.users, mockUserSource.path = defaultMockUsers, ""
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("invalid DEV_USERS_FILE %q: %w", path, err)
+	}
+	users, err := loadMockUsers(path)
+	if err != nil {
+		return fmt.Errorf("invalid DEV_USERS_FILE %q: %w", path, err)
+	}
+	mockUserSource.users, mockUserSource.path = users, path
+	mockUserSource.modTime, mockUserSource.size = info.ModTime(), info.Size()
+	log.Printf("Loaded %d dev users from %s", len(users), path)
+	return nil
+}
+
+// devUsers returns the dev mode users, first reloading DEV_USERS_FILE if it
+// has changed since it was last loaded
+func devUsers() []MockUser {
+	s := &mockUserSource
+	s.Lock()
+	defer s.Unlock()
+	if s.path == "" {
+		return s.users
+	}
+
+	logOnce := func(err error) {
+		if err.Error() != s.lastErr {
+			log.Printf("Keeping the previous dev users: %v", err)
+			s.lastErr = err.Error()
+		}
+	}
+	info, err := os.Stat(s.path)
+	if err != nil {
+		logOnce(err)
+		return s.users
+	}
+	if info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return s.users
+	}
+	s.modTime, s.size = info.ModTime(), info.Size()
+	users, err := loadMockUsers(s.path)
+	if err != nil {
+		logOnce(fmt.Errorf("%s: %w", s.path, err))
+		return s.users
+	}
+	s.users, s.lastErr = users, ""
+	log.Printf("Reloaded %d dev users from %s", len(users), s.path)
+	return s.users
+}
+
+// fileMockUsers returns the users listed in DEV_USERS_FILE, reloading it if
+// it has changed, or nil if it is not set
+func fileMockUsers() []MockUser {
+	mockUserSource.Lock()
+	path := mockUserSource.path
+	mockUserSource.Unlock()
+	if path == "" {
+		return nil
+	}
+	return devUsers()
+}
+
+// loadMockUsers reads a JSON list of users from a file
+func loadMockUsers(path string) ([]MockUser, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var users []MockUser
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&users); err != nil {
+		return nil, err
+	}
+	if err := validateMockUsers(users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// validateMockUsers checks a list of users and fills in their defaults: a
+// user's sub defaults to its email and its name to its email's local part
+func validateMockUsers(users []MockUser) error {
+	if len(users) == 0 {
+		return fmt.Errorf("no users")
+	}
+	subs, emails := make(map[string]bool), make(map[string]bool)
+	for i := range users {
+		u := &users[i]
+		if address, err := mail.ParseAddress(u.Email); err != nil || address.Address != u.Email {
+			return fmt.Errorf("user %d: %q is not an email address", i+1, u.Email)
+		}
+		if u.Sub == "" {
+			u.Sub = u.Email
+		}
+		if u.Name == "" {
+			u.Name, _, _ = strings.Cut(u.Email, "@")
+		}
+		if subs[u.Sub] {
+			return fmt.Errorf("user %d: more than one user has sub %q", i+1, u.Sub)
+		}
+		if emails[strings.ToLower(u.Email)] {
+			return fmt.Errorf("user %d: more than one user has email %q", i+1, u.Email)
+		}
+		subs[u.Sub], emails[strings.ToLower(u.Email)] = true, true
+		for name := range u.Claims {
+			if reservedClaims[name] {
+				return fmt.Errorf("user %d: claim %q cannot be set in claims", i+1, name)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useMockUsersFile makes the dev users come from a file with the given
+// contents until the test ends, and returns its path
+func useMockUsersFile(t *testing.T, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := initMockUsers(path); err != nil {
+		t.Fatalf("initMockUsers: %v", err)
+	}
+	t.Cleanup(func() { initMockUsers("") })
+	return path
+}
+
+const testUsersJSON = `[
+	{
+		"email": "dana@example.com",
+		"name": "Dana O'Neil",
+		"sub": "dana",
+		"roles": ["Admin", "Reports.Read"],
+		"groups": ["finance", "staff"],
+		"claims": {"department": "Finance", "level": 3, "manager": true}
+	},
+	{"email": "eve@example.com"}
+]`
+
+func TestLoadMockUsers(t *testing.T) {
+	useMockUsersFile(t, "users.json", testUsersJSON)
+
+	want := []MockUser{
+		{
+			Email:  "dana@example.com",
+			Name:   "Dana O'Neil",
+			Sub:    "dana",
+			Roles:  []string{"Admin", "Reports.Read"},
+			Groups: []string{"finance", "staff"},
+			Claims: map[string]any{"department": "Finance", "level": float64(3), "manager": true},
+		},
+		{Email: "eve@example.com", Name: "eve", Sub: "eve@example.com"},
+	}
+	if got := devUsers(); !reflect.DeepEqual(got, want) {
+		t.Errorf("devUsers() = %+v, want %+v", got, want)
+	}
+
+	for name, contents := range map[string]string{
+		"empty.json":      "",
+		"empty-list.json": "[]",
+		"no-email.json":   `[{"name": "Nobody"}]`,
+		"bad-email.json":  `[{"email": "not an email"}]`,
+		"dup-sub.json":    `[{"email": "a@example.com", "sub": "a"}, {"email": "b@example.com", "sub": "a"}]`,
+		"dup-email.json":  `[{"email": "a@example.com"}, {"email": "A@example.com"}]`,
+		"reserved.json":   `[{"email": "a@example.com", "claims": {"aud": "other"}}]`,
+		"unknown.json":    `[{"email": "a@example.com", "role": "Admin"}]`,
+		"users.yaml":      "- email: a@example.com\n",
+		"not-a-list.json": `{"email": "a@example.com"}`,
+	} {
+		path := filepath.Join(t.TempDir(), name)
+		if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if users, err := loadMockUsers(path); err == nil {
+			t.Errorf("loadMockUsers(%s) = %+v, want an error", name, users)
+		}
+	}
+}
+
+func TestMockUsersReloadOnChange(t *testing.T) {
+	path := useMockUsersFile(t, "users.json", `[{"email": "dana@example.com", "sub": "dana"}]`)
+	change := func(contents string, modTime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		// Don't depend on the file system's timestamp resolution
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	subs := func() string {
+		var subs []string
+		for _, u := range devUsers() {
+			subs = append(subs, u.Sub)
+		}
+		return strings.Join(subs, ",")
+	}
+
+	change(`[{"email": "dana@example.com", "sub": "dana"}, {"email": "eve@example.com", "sub": "eve"}]`, time.Now().Add(time.Minute))
+	if got := subs(); got != "dana,eve" {
+		t.Errorf("users after adding eve = %s, want dana,eve", got)
+	}
+
+	// A change that doesn't load keeps the previous users
+	change(`[{"email": `, time.Now().Add(2*time.Minute))
+	if got := subs(); got != "dana,eve" {
+		t.Errorf("users after a broken change = %s, want dana,eve", got)
+	}
+	change(`[{"email": "frank@example.com", "sub": "frank"}]`, time.Now().Add(3*time.Minute))
+	if got := subs(); got != "frank" {
+		t.Errorf("users after fixing the file = %s, want frank", got)
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := subs(); got != "frank" {
+		t.Errorf("users after removing the file = %s, want frank", got)
+	}
+}
+
+func TestMockUsersFileUsersAreAllowed(t *testing.T) {
+	key, err := loadDevSigningKey("")
+	if err != nil {
+		t.Fatalf("loadDevSigningKey: %v", err)
+	}
+	useDevKey(t, key)
+	authConfig.AllowedUsers = []string{"alice@example.com"}
+	path := useMockUsersFile(t, "users.json", testUsersJSON)
+
+	status := func(email string) int {
+		t.Helper()
+		token, err := key.sign(testClaims(email))
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/api/auth/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		authMiddleware(func(w http.ResponseWriter, r *http.Request) {})(rec, req)
+		return rec.Code
+	}
+	for email, want := range map[string]int{
+		"alice@example.com":   http.StatusOK,
+		"Dana@example.com":    http.StatusOK,
+		"eve@example.com":     http.StatusOK,
+		"mallory@example.com": http.StatusForbidden,
+	} {
+		if got := status(email); got != want {
+			t.Errorf("%s: status %d, want %d", email, got, want)
+		}
+	}
+
+	// Users added to the file are allowed once it is reloaded, and removed
+	// ones no longer are
+	if err := os.WriteFile(path, []byte(`[{"email": "frank@example.com"}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	if got := status("frank@example.com"); got != http.StatusOK {
+		t.Errorf("frank after reloading: status %d, want %d", got, http.StatusOK)
+	}
+	if got := status("dana@example.com"); got != http.StatusForbidden {
+		t.Errorf("dana after reloading: status %d, want %d", got, http.StatusForbidden)
+	}
+
+	// In prod mode only ALLOWED_USERS counts
+	authConfig.Mode = "prod"
+	if got := allowedUsers(); !reflect.DeepEqual(got, []string{"alice@example.com"}) {
+		t.Errorf("allowedUsers() in prod mode = %v, want just alice", got)
+	}
+}
+
+func TestMockUserClaimsInTokens(t *testing.T) {
+	key, err := loadDevSigningKey("")
+	if err != nil {
+		t.Fatalf("loadDevSigningKey: %v", err)
+	}
+	useDevKey(t, key)
+	useMockUsersFile(t, "users.json", testUsersJSON)
+
+	dana, ok := findMockUser("dana")
+	if !ok {
+		t.Fatal("dana is not a dev user")
+	}
+	claims := dana.tokenClaims()
+	claims["iss"], claims["aud"] = devIssuer, devClientID
+	claims["exp"] = time.Now().Add(time.Hour).Unix()
+	token, err := key.sign(claims)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	idToken, err := authConfig.oidcVerifier.Verify(context.Background(), token)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	var got struct {
+		Email      string   `json:"email"`
+		Roles      []string `json:"roles"`
+		Groups     []string `json:"groups"`
+		Department string   `json:"department"`
+		Level      int      `json:"level"`
+	}
+	if err := idToken.Claims(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Email != "dana@example.com" || !reflect.DeepEqual(got.Roles, dana.Roles) || !reflect.DeepEqual(got.Groups, dana.Groups) ||
+		got.Department != "Finance" || got.Level != 3 {
+		t.Errorf("token claims = %+v, want dana's roles, groups and claims", got)
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Pausing a recurring definition stops instances being generated for it,
+// whether by the scheduler or by completing an instance, without touching the
+// instances it already has. Resuming can shift the schedule forward by the
+// length of the pause so the series carries on where it left off rather than
+// at its next calendar date.
+
+// pauseDefinition pauses def if it is not already paused
+func pauseDefinition(tx Store, def *RecurringItemDefinition, now time.Time) error {
+	if def.Paused {
+		return nil
+	}
+	def.Paused = true
+	def.PausedAt = &now
+	return tx.UpdateRecurringDef(def)
+}
+
+// resumeDefinition resumes a paused def. With shift, its start date and any
+// pending instances due since it was paused move forward by the length of the
+// pause, rounded up to whole days (whole weeks for weekly patterns, so they
+// keep their weekdays). Patterns pinned to calendar dates, such as a day of
+// the month or an RRULE, are not shifted, as that would move them off those
+// dates for good. It then makes sure the series has a pending instance, since
+// completions while paused created none.
+func resumeDefinition(tx Store, def *RecurringItemDefinition, now time.Time, shift bool) error {
+	if !def.Paused {
+		return nil
+	}
+
+	if err := tx.LockRecurringDef(def.ID); err != nil {
+		return err
+	}
+	todos, err := tx.ListTodos()
+	if err != nil {
+		return err
+	}
+
+	if shift && !def.Pattern.pinnedToCalendar() {
+		days := pauseShiftDays(def, *def.PausedAt, now)
+		for _, todo := range todos {
+			if todo.RecurrenceID == nil || *todo.RecurrenceID != def.ID || todo.Completed ||
+				todo.DueDate == nil || todo.DueDate.Before(*def.PausedAt) {
+				continue
+			}
+			dueDate := todo.DueDate.In(def.location()).AddDate(0, 0, days)
+			todo.DueDate = &dueDate
+			if err := tx.UpdateTodo(todo); err != nil {
+				return err
+			}
+		}
+		def.StartDate = def.StartDate.In(def.location()).AddDate(0, 0, days)
+	}
+
+	def.Paused = false
+	def.PausedAt = nil
+	if err := tx.UpdateRecurringDef(def); err != nil {
+		return err
+	}
+	return ensurePendingInstance(tx, def, todos, now)
+}
+
+// pauseShiftDays returns how many days to shift def's schedule after a pause
+// from pausedAt to now
+func pauseShiftDays(def *RecurringItemDefinition, pausedAt, now time.Time) int {
+	days := daysBetween(pausedAt, now)
+	if now.Sub(pausedAt) > time.Duration(days)*24*time.Hour {
+		days++
+	}
+	if def.Pattern.Frequency == "weekly" && def.Pattern.RRule == "" && def.Pattern.Mode == recurrenceModeSchedule {
+		days = (days + 6) / 7 * 7
+	}
+	return days
+}
+
+// pinnedToCalendar reports whether a pattern's occurrences fall on calendar
+// dates, such as a day of the month, which shifting its start would change
+func (p RecurrencePattern) pinnedToCalendar() bool {
+	return p.Mode == recurrenceModeSchedule && (p.RRule != "" || p.Frequency == "monthly" || p.Frequency == "yearly")
+}
+
+// ensurePendingInstance creates def's next instance if none of its instances
+// is still to be done. For afterCompletion patterns the next instance is due
+// an interval after the last completion, or now if that has passed.
+func ensurePendingInstance(tx Store, def *RecurringItemDefinition, todos []*TodoItem, now time.Time) error {
+	var lastCompleted *time.Time
+	for _, todo := range todos {
+		if todo.RecurrenceID == nil || *todo.RecurrenceID != def.ID {
+			continue
+		}
+		if !todo.Completed {
+			return nil
+		}
+		if todo.CompletedAt != nil && (lastCompleted == nil || todo.CompletedAt.After(*lastCompleted)) {
+			lastCompleted = todo.CompletedAt
+		}
+	}
+
+	after := latestInstanceDueDate(todos, def.ID, now)
+	if def.Pattern.Mode == recurrenceModeAfterCompletion {
+		after = now
+		if lastCompleted != nil {
+			after = *lastCompleted
+		}
+	}
+	dueDate, ok := def.nextDueDate(after)
+	if !ok {
+		return refreshFinished(tx, def, after)
+	}
+	if dueDate.Before(now) {
+		dueDate = now
+	}
+	_, err := createRecurringInstance(tx, def, dueDate, len(todos))
+	return err
+}
+
+// pauseRecurringDef pauses a recurring item definition
+func pauseRecurringDef(w http.ResponseWriter, r *http.Request) {
+	setRecurringDefPaused(w, r, true)
+}
+
+// resumeRecurringDef resumes a paused recurring item definition. The optional
+// body {"shiftSchedule": true} shifts its schedule by the length of the pause.
+func resumeRecurringDef(w http.ResponseWriter, r *http.Request) {
+	setRecurringDefPaused(w, r, false)
+}
+
+func setRecurringDefPaused(w http.ResponseWriter, r *http.Request, pause bool) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	request, ok := decodePauseRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var def *RecurringItemDefinition
+	err = store.Update(func(tx Store) error {
+		var err error
+		def, err = tx.GetRecurringDef(id)
+		if err != nil {
+			return err
+		}
+		if pause {
+			return pauseDefinition(tx, def, clock.Now())
+		}
+		return resumeDefinition(tx, def, clock.Now(), request.ShiftSchedule)
+	})
+	if err != nil {
+		writeStoreError(w, err, "Recurring definition not found")
+		return
+	}
+
+	if !pause {
+		scheduler.Trigger()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(def)
+}
+
+// pauseRecurringDefsForAssignee pauses every recurring definition assigned to
+// the user given as {"assignee": "..."}
+func pauseRecurringDefsForAssignee(w http.ResponseWriter, r *http.Request) {
+	setAssigneeRecurringDefsPaused(w, r, true)
+}
+
+// resumeRecurringDefsForAssignee resumes every paused recurring definition
+// assigned to the user given as {"assignee": "...", "shiftSchedule": true}
+func resumeRecurringDefsForAssignee(w http.ResponseWriter, r *http.Request) {
+	setAssigneeRecurringDefsPaused(w, r, false)
+}
+
+func setAssigneeRecurringDefsPaused(w http.ResponseWriter, r *http.Request, pause bool) {
+	request, ok := decodePauseRequest(w, r)
+	if !ok {
+		return
+	}
+	if strings.TrimSpace(request.Assignee) == "" {
+		http.Error(w, "assignee is required", http.StatusBadRequest)
+		return
+	}
+
+	updated := make([]*RecurringItemDefinition, 0)
+	err := store.Update(func(tx Store) error {
+		defs, err := tx.ListRecurringDefs()
+		if err != nil {
+			return err
+		}
+		now := clock.Now()
+		for _, def := range defs {
+			if !isAssignedTo(def.AssignedTo, request.Assignee) || def.Paused == pause {
+				continue
+			}
+			if pause {
+				err = pauseDefinition(tx, def, now)
+			} else {
+				err = resumeDefinition(tx, def, now, request.ShiftSchedule)
+			}
+			if err != nil {
+				return err
+			}
+			updated = append(updated, def)
+		}
+		return nil
+	})
+	if err != nil {
+		writeStoreError(w, err, "Recurring definition not found")
+		return
+	}
+
+	if !pause {
+		scheduler.Trigger()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// pauseRequest is the optional body of the pause and resume endpoints
+type pauseRequest struct {
+	Assignee      string `json:"assignee,omitempty"`      // Bulk endpoints only
+	ShiftSchedule bool   `json:"shiftSchedule,omitempty"` // Resume only
+}
+
+// decodePauseRequest reads the optional request body, writing an error
+// response and returning false if it is malformed
+func decodePauseRequest(w http.ResponseWriter, r *http.Request) (pauseRequest, bool) {
+	var request pauseRequest
+	if r.ContentLength == 0 {
+		return request, true
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return request, false
+	}
+	return request, true
+}
+
+// isAssignedTo reports whether assignee is one of assignedTo, ignoring case
+func isAssignedTo(assignedTo []string, assignee string) bool {
+	for _, a := range assignedTo {
+		if strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(assignee)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPausedDefinitionGeneratesNothing(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+			def, todo := createDailyDefinition(t, s, now.Add(time.Hour))
+
+			if err := s.Update(func(tx Store) error { return pauseDefinition(tx, def, now) }); err != nil {
+				t.Fatalf("pauseDefinition: %v", err)
+			}
+
+			completeTodo(t, s, todo)
+			if todo.NextInstanceID != nil {
+				t.Error("completing an instance of a paused definition spawned the next one")
+			}
+			if created, err := materializeUpcoming(s, now, 7*24*time.Hour); err != nil || created != 0 {
+				t.Errorf("materializeUpcoming = %d, %v, want nothing created", created, err)
+			}
+			if instances := instancesOf(t, s, def.ID); len(instances) != 1 {
+				t.Errorf("got %d instances, want 1", len(instances))
+			}
+		})
+	}
+}
+
+func TestResumeCreatesPendingInstance(t *testing.T) {
+	s := newMemoryStore()
+	now := time.Now().Truncate(time.Second)
+	def, todo := createDailyDefinition(t, s, now.Add(time.Hour))
+
+	err := s.Update(func(tx Store) error { return pauseDefinition(tx, def, now) })
+	if err != nil {
+		t.Fatalf("pauseDefinition: %v", err)
+	}
+	completeTodo(t, s, todo)
+
+	err = s.Update(func(tx Store) error { return resumeDefinition(tx, def, now.Add(2*time.Hour), false) })
+	if err != nil {
+		t.Fatalf("resumeDefinition: %v", err)
+	}
+	if def.Paused || def.PausedAt != nil {
+		t.Errorf("definition still paused: %+v", def)
+	}
+
+	instances := instancesOf(t, s, def.ID)
+	if len(instances) != 2 {
+		t.Fatalf("got %d instances, want 2", len(instances))
+	}
+	if next := instances[1]; next.Completed || !next.DueDate.Equal(now.Add(time.Hour).AddDate(0, 0, 1)) {
+		t.Errorf("next instance = %+v, want one due the following day", next)
+	}
+}
+
+func TestResumeShiftsSchedule(t *testing.T) {
+	s := newMemoryStore()
+	pausedAt := time.Now().Truncate(time.Second)
+	def, todo := createDailyDefinition(t, s, pausedAt.Add(time.Hour))
+	start := def.StartDate
+
+	err := s.Update(func(tx Store) error { return pauseDefinition(tx, def, pausedAt) })
+	if err != nil {
+		t.Fatalf("pauseDefinition: %v", err)
+	}
+
+	// Two and a half days paused shifts by three days
+	resumedAt := pausedAt.Add(60 * time.Hour)
+	err = s.Update(func(tx Store) error { return resumeDefinition(tx, def, resumedAt, true) })
+	if err != nil {
+		t.Fatalf("resumeDefinition: %v", err)
+	}
+
+	if want := start.AddDate(0, 0, 3); !def.StartDate.Equal(want) {
+		t.Errorf("StartDate = %v, want %v", def.StartDate, want)
+	}
+	got, err := s.GetTodo(todo.ID)
+	if err != nil {
+		t.Fatalf("GetTodo: %v", err)
+	}
+	if want := todo.DueDate.AddDate(0, 0, 3); !got.DueDate.Equal(want) {
+		t.Errorf("pending instance due %v, want %v", got.DueDate, want)
+	}
+	if instances := instancesOf(t, s, def.ID); len(instances) != 1 {
+		t.Errorf("got %d instances, want the shifted one only", len(instances))
+	}
+}
+
+func TestResumeDoesNotShiftCalendarPatterns(t *testing.T) {
+	for name, pattern := range map[string]RecurrencePattern{
+		"monthly": {Frequency: "monthly", Interval: 1},
+		"yearly":  {Frequency: "yearly", Interval: 1},
+		"rrule":   {RRule: "FREQ=MONTHLY;BYMONTHDAY=1"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := newMemoryStore()
+			// Rent due on the 1st, paused for ten days over it
+			start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+			def := &RecurringItemDefinition{Title: "Rent", Pattern: pattern, StartDate: start, CreatedAt: start.AddDate(0, 0, -7)}
+			if err := s.CreateRecurringDef(def); err != nil {
+				t.Fatalf("CreateRecurringDef: %v", err)
+			}
+			todo := newRecurringInstance(def, start, 0)
+			if err := s.CreateTodo(todo); err != nil {
+				t.Fatalf("CreateTodo: %v", err)
+			}
+			pausedAt := start.AddDate(0, 0, -5)
+			if err := s.Update(func(tx Store) error { return pauseDefinition(tx, def, pausedAt) }); err != nil {
+				t.Fatalf("pauseDefinition: %v", err)
+			}
+			if err := s.Update(func(tx Store) error { return resumeDefinition(tx, def, pausedAt.AddDate(0, 0, 10), true) }); err != nil {
+				t.Fatalf("resumeDefinition: %v", err)
+			}
+
+			if !def.StartDate.Equal(start) {
+				t.Errorf("StartDate = %v, want %v", def.StartDate, start)
+			}
+			if got, err := s.GetTodo(todo.ID); err != nil || !got.DueDate.Equal(start) {
+				t.Errorf("pending instance = %+v, %v, want it still due %v", got, err, start)
+			}
+			if next, ok := def.nextDueDate(start); !ok || next.Day() != 1 {
+				t.Errorf("next occurrence = %v, %v, want one on the 1st", next, ok)
+			}
+		})
+	}
+}
+
+func TestPauseShiftDays(t *testing.T) {
+	pausedAt := date(2026, 3, 2)
+	tests := []struct {
+		name    string
+		pattern RecurrencePattern
+		resumed time.Time
+		want    int
+	}{
+		{"whole days", RecurrencePattern{Frequency: "daily", Interval: 1}, date(2026, 3, 5), 3},
+		{"part days round up", RecurrencePattern{Frequency: "daily", Interval: 1}, date(2026, 3, 5).Add(time.Hour), 4},
+		{"weekly rounds to weeks", RecurrencePattern{Frequency: "weekly", Interval: 1, DaysOfWeek: []string{"Monday"}}, date(2026, 3, 12), 14},
+		{"weekly whole weeks", RecurrencePattern{Frequency: "weekly", Interval: 1}, date(2026, 3, 9), 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			def := &RecurringItemDefinition{Pattern: tt.pattern}
+			if got := pauseShiftDays(def, pausedAt, tt.resumed); got != tt.want {
+				t.Errorf("pauseShiftDays = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsAssignedTo(t *testing.T) {
+	assignedTo := []string{"Alice", " bob@example.com "}
+	if !isAssignedTo(assignedTo, "alice") || !isAssignedTo(assignedTo, "BOB@example.com") {
+		t.Error("expected case-insensitive match on assignee")
+	}
+	if isAssignedTo(assignedTo, "carol") || isAssignedTo(nil, "alice") {
+		t.Error("unexpected match")
+	}
+}
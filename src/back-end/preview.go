@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// The preview endpoints list the dates a recurrence produces, using the same
+// logic as instance generation, so the front-end can show a pattern's next
+// few occurrences before it is saved.
+
+const (
+	defaultPreviewCount = 5
+	maxPreviewCount     = 366
+
+	// defaultOccurrencesWindow is how far ahead occurrences are listed when
+	// no end of the range is given
+	defaultOccurrencesWindow = 31 * 24 * time.Hour
+)
+
+// OccurrencesResponse lists the dates a recurring definition falls due
+type OccurrencesResponse struct {
+	Occurrences []time.Time `json:"occurrences"`
+}
+
+// previewRecurringDef returns the next occurrences of an unsaved recurring
+// definition. The body is a definition (only its pattern, startDate, endDate
+// and maxOccurrences are used) plus an optional "count" of dates to return.
+func previewRecurringDef(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		RecurringItemDefinition
+		Count int `json:"count,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	def := request.RecurringItemDefinition
+	if err := validateRecurrenceSchedule(&def); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if request.Count < 0 || request.Count > maxPreviewCount {
+		http.Error(w, fmt.Sprintf("count must be between 0 (default %d) and %d", defaultPreviewCount, maxPreviewCount), http.StatusBadRequest)
+		return
+	}
+	if request.Count == 0 {
+		request.Count = defaultPreviewCount
+	}
+
+	// Count occurrences as a definition created now would, unless the
+	// preview is of an edit to an existing one
+	now := clock.Now()
+	if def.StartDate.IsZero() {
+		def.StartDate = now
+	}
+	if def.CreatedAt.IsZero() {
+		def.CreatedAt = now
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(OccurrencesResponse{
+		Occurrences: def.occurrencesBetween(now, time.Time{}, request.Count),
+	})
+}
+
+// getRecurringOccurrences returns the occurrences of a recurring definition
+// between the "from" and "to" query parameters (RFC 3339 times or
+// YYYY-MM-DD dates in its time zone, both inclusive), defaulting to the next
+// month
+func getRecurringOccurrences(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	def, err := store.GetRecurringDef(id)
+	if err != nil {
+		writeStoreError(w, err, "Recurring definition not found")
+		return
+	}
+
+	// Dates are days in the definition's time zone
+	loc := def.location()
+	from := clock.Now()
+	if value := r.URL.Query().Get("from"); value != "" {
+		if from, err = parseOccurrenceTime(value, false, loc); err != nil {
+			http.Error(w, "Invalid from: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	to := from.Add(defaultOccurrencesWindow)
+	if value := r.URL.Query().Get("to"); value != "" {
+		if to, err = parseOccurrenceTime(value, true, loc); err != nil {
+			http.Error(w, "Invalid to: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if to.Before(from) {
+		http.Error(w, "to must not be before from", http.StatusBadRequest)
+		return
+	}
+
+	after := from.Add(-time.Nanosecond)
+	occurrences := []time.Time{}
+	if def.Pattern.Mode == recurrenceModeAfterCompletion {
+		// Later occurrences depend on when the pending instance is done,
+		// so project them from its due date
+		todos, err := store.ListTodos()
+		if err != nil {
+			writeStoreError(w, err, "Recurring definition not found")
+			return
+		}
+		for _, todo := range todos {
+			if todo.RecurrenceID == nil || *todo.RecurrenceID != def.ID || todo.Completed || todo.DueDate == nil {
+				continue
+			}
+			if !todo.DueDate.Before(from) && !todo.DueDate.After(to) {
+				occurrences = append(occurrences, *todo.DueDate)
+			}
+			if todo.DueDate.After(after) {
+				after = *todo.DueDate
+			}
+			break
+		}
+	}
+	occurrences = append(occurrences, def.occurrencesBetween(after, to, maxPreviewCount-len(occurrences))...)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(OccurrencesResponse{Occurrences: occurrences})
+}
+
+// parseOccurrenceTime parses an RFC 3339 time or a YYYY-MM-DD date, which is
+// the start of that day in loc or, with endOfDay, its last instant
+func parseOccurrenceTime(value string, endOfDay bool, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, value, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected an RFC 3339 time or YYYY-MM-DD date")
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return t, nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestOccurrencesBetween(t *testing.T) {
+	// Monday 2 March 2026
+	start := date(2026, 3, 2)
+	def := &RecurringItemDefinition{
+		Pattern:   RecurrencePattern{Frequency: "weekly", Interval: 1, DaysOfWeek: []string{"Monday", "Thursday"}},
+		StartDate: start,
+		CreatedAt: start.Add(-time.Hour),
+	}
+
+	t.Run("limit", func(t *testing.T) {
+		got := def.occurrencesBetween(start.Add(-time.Nanosecond), time.Time{}, 3)
+		assertDates(t, got, []time.Time{date(2026, 3, 2), date(2026, 3, 5), date(2026, 3, 9)})
+	})
+
+	t.Run("until is inclusive", func(t *testing.T) {
+		got := def.occurrencesBetween(date(2026, 3, 3), date(2026, 3, 12), 10)
+		assertDates(t, got, []time.Time{date(2026, 3, 5), date(2026, 3, 9), date(2026, 3, 12)})
+	})
+
+	t.Run("end conditions", func(t *testing.T) {
+		limited := *def
+		limited.MaxOccurrences = 2
+		got := limited.occurrencesBetween(start.Add(-time.Nanosecond), time.Time{}, 10)
+		assertDates(t, got, []time.Time{date(2026, 3, 2), date(2026, 3, 5)})
+	})
+
+	t.Run("after completion", func(t *testing.T) {
+		every := &RecurringItemDefinition{
+			Pattern:   RecurrencePattern{Frequency: "daily", Interval: 3, Mode: recurrenceModeAfterCompletion},
+			StartDate: start,
+		}
+		got := every.occurrencesBetween(start, time.Time{}, 2)
+		assertDates(t, got, []time.Time{date(2026, 3, 5), date(2026, 3, 8)})
+	})
+}
+
+func TestParseOccurrenceTime(t *testing.T) {
+	sydney, err := time.LoadLocation("Australia/Sydney")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		value    string
+		endOfDay bool
+		loc      *time.Location
+		want     time.Time
+	}{
+		{"2026-03-02", false, time.UTC, time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)},
+		{"2026-03-02", true, time.UTC, time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC).Add(-time.Nanosecond)},
+		{"2026-03-02", false, sydney, time.Date(2026, 3, 2, 0, 0, 0, 0, sydney)},
+		{"2026-03-02", true, sydney, time.Date(2026, 3, 3, 0, 0, 0, 0, sydney).Add(-time.Nanosecond)},
+		{"2026-03-02T09:30:00+01:00", true, sydney, time.Date(2026, 3, 2, 8, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := parseOccurrenceTime(tt.value, tt.endOfDay, tt.loc)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("parseOccurrenceTime(%q, %v, %v) = %v, %v, want %v", tt.value, tt.endOfDay, tt.loc, got, err, tt.want)
+		}
+	}
+
+	if _, err := parseOccurrenceTime("next week", false, time.UTC); err == nil {
+		t.Error("expected an error for an invalid time")
+	}
+}
+
+func TestGetRecurringOccurrencesUsesTimeZone(t *testing.T) {
+	s := newMemoryStore()
+	useStore(t, s)
+	sydney, err := time.LoadLocation("Australia/Sydney")
+	if err != nil {
+		t.Fatal(err)
+	}
+	def := &RecurringItemDefinition{
+		Title:     "Water the plants",
+		Pattern:   RecurrencePattern{Frequency: "daily", Interval: 1},
+		StartDate: time.Date(2026, 3, 1, 9, 0, 0, 0, sydney),
+		CreatedAt: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+		TimeZone:  "Australia/Sydney",
+	}
+	if err := s.CreateRecurringDef(def); err != nil {
+		t.Fatalf("CreateRecurringDef: %v", err)
+	}
+
+	// 2 March in Sydney starts on 1 March in UTC
+	request := httptest.NewRequest("GET", "/api/recurring/1/occurrences?from=2026-03-02&to=2026-03-02", nil)
+	request = mux.SetURLVars(request, map[string]string{"id": strconv.Itoa(def.ID)})
+	recorder := httptest.NewRecorder()
+	getRecurringOccurrences(recorder, request)
+
+	var response OccurrencesResponse
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+		t.Fatalf("status %d: %v", recorder.Code, err)
+	}
+	assertDates(t, response.Occurrences, []time.Time{time.Date(2026, 3, 2, 9, 0, 0, 0, sydney)})
+}
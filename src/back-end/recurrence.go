@@ -0,0 +1,486 @@
+package main
+
+import (
+	"log"
+	"slices"
+	"sort"
+	"time"
+)
+
+// Recurrence modes
+const (
+	recurrenceModeSchedule        = ""                // Occurrences follow the calendar from StartDate
+	recurrenceModeAfterCompletion = "afterCompletion" // Each occurrence is due an interval after the previous one was completed
+)
+
+// weekdaysByName maps DaysOfWeek names to time.Weekday
+var weekdaysByName = map[string]time.Weekday{
+	"Sunday":    time.Sunday,
+	"Monday":    time.Monday,
+	"Tuesday":   time.Tuesday,
+	"Wednesday": time.Wednesday,
+	"Thursday":  time.Thursday,
+	"Friday":    time.Friday,
+	"Saturday":  time.Saturday,
+}
+
+// calculateNextDueDate calculates the first due date of a pattern, counting
+// from startDate, that falls after the given time. It returns false when the
+// pattern has no further occurrences (an RRULE's COUNT or UNTIL was reached).
+//
+// The result depends only on the definition and the after time, never on
+// when it is evaluated, so the scheduler and completion handler always agree.
+func calculateNextDueDate(startDate time.Time, pattern RecurrencePattern, after time.Time) (time.Time, bool) {
+	// Each exception date can rule out at most one occurrence
+	for range len(pattern.ExceptionDates) + 1 {
+		next, ok := calculateNextActiveDate(startDate, pattern, after)
+		if !ok || !pattern.isException(next.In(startDate.Location())) {
+			return next, ok
+		}
+		after = next
+	}
+	return time.Time{}, false
+}
+
+// isException reports whether date falls on one of the pattern's exception
+// dates. Dates are compared by calendar day in date's location.
+func (p RecurrencePattern) isException(date time.Time) bool {
+	for _, exception := range p.ExceptionDates {
+		exception = exception.In(date.Location())
+		if exception.Year() == date.Year() && exception.YearDay() == date.YearDay() {
+			return true
+		}
+	}
+	return false
+}
+
+// calculateNextActiveDate is calculateNextDueDate without exception dates
+func calculateNextActiveDate(startDate time.Time, pattern RecurrencePattern, after time.Time) (time.Time, bool) {
+	if len(pattern.ActiveMonths) == 0 {
+		return calculateNextPatternDate(startDate, pattern, after)
+	}
+
+	// Suppress occurrences outside the seasonal window, skipping ahead to the
+	// start of the next active month rather than stepping through each one
+	loc := startDate.Location()
+	for range maxMonthsSearched {
+		next, ok := calculateNextPatternDate(startDate, pattern, after)
+		if !ok || slices.Contains(pattern.ActiveMonths, int(next.In(loc).Month())) {
+			return next, ok
+		}
+		month := time.Date(next.In(loc).Year(), next.In(loc).Month(), 1, 0, 0, 0, 0, loc)
+		for range 12 {
+			month = month.AddDate(0, 1, 0)
+			if slices.Contains(pattern.ActiveMonths, int(month.Month())) {
+				break
+			}
+		}
+		after = month.Add(-time.Nanosecond)
+	}
+
+	return time.Time{}, false
+}
+
+// nextDueDate returns the definition's first occurrence after the given
+// time, honoring its holiday rule, EndDate and MaxOccurrences. It returns
+// false once the definition has no further occurrences.
+//
+// For afterCompletion patterns, after is when the previous instance was
+// completed and the result is one interval later.
+func (d *RecurringItemDefinition) nextDueDate(after time.Time) (time.Time, bool) {
+	return d.nextDueDateWithin(after, d.findOccurrenceLimit())
+}
+
+// nextDueDateWithin is nextDueDate for callers that step through several
+// occurrences, which find the definition's occurrence limit once and pass it
+// to each call
+func (d *RecurringItemDefinition) nextDueDateWithin(after time.Time, end occurrenceLimit) (time.Time, bool) {
+	var next time.Time
+	var ok bool
+	if d.Pattern.Mode == recurrenceModeAfterCompletion {
+		next, ok = d.nextCompletionDueDate(after)
+	} else {
+		next, ok = d.nextScheduledDate(after)
+	}
+	if !ok || (d.EndDate != nil && next.After(*d.EndDate)) {
+		return time.Time{}, false
+	}
+	if end.limited && next.After(end.last) {
+		return time.Time{}, false
+	}
+	return next, true
+}
+
+// occurrencesBetween returns up to limit of the definition's occurrences
+// after the given time and no later than until, which may be zero for no
+// bound. For afterCompletion patterns each occurrence is assumed to be
+// completed when it falls due.
+func (d *RecurringItemDefinition) occurrencesBetween(after, until time.Time, limit int) []time.Time {
+	end := d.findOccurrenceLimit()
+	occurrences := []time.Time{}
+	for len(occurrences) < limit {
+		next, ok := d.nextDueDateWithin(after, end)
+		if !ok || (!until.IsZero() && next.After(until)) || !next.After(after) {
+			break
+		}
+		occurrences = append(occurrences, next)
+		after = next
+	}
+	return occurrences
+}
+
+// maxOccurrencesLimit bounds MaxOccurrences, as finding the last occurrence
+// means stepping through every one before it
+const maxOccurrencesLimit = 1000
+
+// occurrenceLimit is the last occurrence a definition's MaxOccurrences
+// allows
+type occurrenceLimit struct {
+	last    time.Time
+	limited bool // False when MaxOccurrences is unlimited or the pattern ends before reaching it
+}
+
+// findOccurrenceLimit finds the definition's MaxOccurrences-th occurrence.
+// Occurrences are counted from the later of StartDate and CreatedAt, so they
+// match the instances created for a definition whose start date is in the
+// past.
+func (d *RecurringItemDefinition) findOccurrenceLimit() occurrenceLimit {
+	if d.MaxOccurrences == 0 {
+		return occurrenceLimit{}
+	}
+	counted, last := d.countedOccurrences()
+	for range d.MaxOccurrences {
+		next, ok := counted.nextScheduledDate(last)
+		if !ok {
+			return occurrenceLimit{}
+		}
+		last = next
+	}
+	return occurrenceLimit{last: last, limited: true}
+}
+
+// countedOccurrences returns the definition with the pattern whose
+// occurrences count towards MaxOccurrences, and the time they are counted
+// after. Occurrences on exception dates are counted too, as RRULE counts
+// EXDATEs towards COUNT, so skipping or moving one doesn't lengthen the
+// series.
+func (d *RecurringItemDefinition) countedOccurrences() (*RecurringItemDefinition, time.Time) {
+	counted := *d
+	counted.Pattern.ExceptionDates = nil
+
+	after := d.localStart().Add(-time.Nanosecond)
+	if d.CreatedAt.After(after) {
+		after = d.CreatedAt
+	}
+	return &counted, after
+}
+
+// countOccurrencesBefore returns how many of the definition's occurrences
+// that count towards MaxOccurrences fall before the given time and by its
+// EndDate, stopping at limit
+func (d *RecurringItemDefinition) countOccurrencesBefore(before time.Time, limit int) int {
+	counted, last := d.countedOccurrences()
+	n := 0
+	for n < limit {
+		next, ok := counted.nextScheduledDate(last)
+		if !ok || !next.Before(before) || (d.EndDate != nil && next.After(*d.EndDate)) {
+			break
+		}
+		n++
+		last = next
+	}
+	return n
+}
+
+// nextCompletionDueDate returns the due date of an afterCompletion
+// definition's next instance when the previous one was completed at
+// completedAt. An occurrence the holiday rule skips is replaced by the one an
+// interval after it.
+func (d *RecurringItemDefinition) nextCompletionDueDate(completedAt time.Time) (time.Time, bool) {
+	loc := d.location()
+	next := completedAt.In(loc)
+	for range maxHolidaySearch {
+		next = calculateNextCompletionDueDate(d.Pattern, next)
+		if d.AllDay {
+			next = startOfDay(next, loc)
+		}
+		if due, ok := d.observeHolidays(next); ok {
+			return due, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// calculateNextCompletionDueDate returns the due date one interval after
+// completedAt for an afterCompletion pattern. Months and years are clamped to
+// the end of shorter months, as for calendar patterns.
+func calculateNextCompletionDueDate(pattern RecurrencePattern, completedAt time.Time) time.Time {
+	interval := max(pattern.Interval, 1)
+	switch pattern.Frequency {
+	case "weekly":
+		return completedAt.AddDate(0, 0, 7*interval)
+	case "weekdays":
+		// Counting from Friday when completed at the weekend
+		index := weekdayIndex(completedAt)
+		if completedAt.Weekday() == time.Saturday || completedAt.Weekday() == time.Sunday {
+			index--
+		}
+		return weekdayAt(index+interval, completedAt)
+	case "monthly":
+		return addMonthsClamped(completedAt, interval)
+	case "yearly":
+		return addMonthsClamped(completedAt, 12*interval)
+	default:
+		return completedAt.AddDate(0, 0, interval)
+	}
+}
+
+// addMonthsClamped adds months to t, moving to the last day of the resulting
+// month when it is too short for t's day
+func addMonthsClamped(t time.Time, months int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	day := min(t.Day(), daysInMonth(first.Year(), first.Month()))
+	return first.AddDate(0, 0, day-1)
+}
+
+// calculateNextPatternDate is calculateNextActiveDate without the seasonal
+// window
+func calculateNextPatternDate(startDate time.Time, pattern RecurrencePattern, after time.Time) (time.Time, bool) {
+	if pattern.RRule != "" {
+		rule, err := newRRule(pattern, startDate)
+		if err != nil {
+			log.Printf("Invalid rrule %q: %v", pattern.RRule, err)
+			return time.Time{}, false
+		}
+		next := rule.After(after, false)
+		return next, !next.IsZero()
+	}
+
+	if pattern.Frequency == "weekdays" {
+		return calculateNextWeekdayDate(startDate, pattern.Interval, after), true
+	}
+
+	// For weekly recurrence with specific days of week
+	if pattern.Frequency == "weekly" && len(pattern.DaysOfWeek) > 0 {
+		return calculateNextWeeklyDate(startDate, pattern.DaysOfWeek, pattern.Interval, after), true
+	}
+
+	if pattern.Frequency == "monthly" {
+		return calculateNextMonthlyDate(startDate, pattern, after)
+	}
+
+	// A yearly pattern is a monthly one on the start date's day every 12
+	// months, so 29 February falls on the 28th in other years
+	if pattern.Frequency == "yearly" {
+		pattern.Interval = 12 * max(pattern.Interval, 1)
+		return calculateNextMonthlyDate(startDate, pattern, after)
+	}
+
+	days := max(pattern.Interval, 1)
+	if pattern.Frequency == "weekly" {
+		days *= 7
+	}
+
+	// Jump straight to the occurrence on or before after's day rather than
+	// stepping through every one since the start
+	n := 0
+	if after.After(startDate) {
+		n = daysBetween(startDate, after.In(startDate.Location())) / days
+	}
+	for {
+		nextDate := startDate.AddDate(0, 0, n*days)
+		if nextDate.After(after) {
+			return nextDate, true
+		}
+		n++
+	}
+}
+
+// maxMonthsSearched bounds the search for a monthly occurrence, which can
+// skip months (e.g. a fifth Friday) but will always find one well within
+// this many
+const maxMonthsSearched = 1200
+
+// calculateNextMonthlyDate finds the first occurrence after the given time of
+// a pattern that repeats every interval months, counted from startDate's
+// month. Each occurrence is computed from its own month rather than from the
+// previous occurrence, so a pattern starting on the 31st falls on the last
+// day of shorter months and returns to the 31st afterwards.
+func calculateNextMonthlyDate(startDate time.Time, pattern RecurrencePattern, after time.Time) (time.Time, bool) {
+	interval := max(pattern.Interval, 1)
+	loc := startDate.Location()
+	startMonth := time.Date(startDate.Year(), startDate.Month(), 1, 0, 0, 0, 0, loc)
+
+	// Jump straight to the active month containing after
+	month := 0
+	if after.After(startDate) {
+		month = monthsBetween(startDate, after.In(loc))
+		month -= month % interval
+	}
+
+	for range maxMonthsSearched / interval {
+		first := startMonth.AddDate(0, month, 0)
+		if day, ok := monthlyOccurrenceDay(first.Year(), first.Month(), pattern, startDate); ok {
+			candidate := time.Date(first.Year(), first.Month(), day,
+				startDate.Hour(), startDate.Minute(), startDate.Second(), startDate.Nanosecond(), loc)
+			if candidate.After(after) && !candidate.Before(startDate) {
+				return candidate, true
+			}
+		}
+		month += interval
+	}
+
+	return time.Time{}, false
+}
+
+// monthlyOccurrenceDay returns the day of the given month on which a monthly
+// pattern falls, or false if it has no occurrence that month (e.g. a fifth
+// Friday)
+func monthlyOccurrenceDay(year int, month time.Month, pattern RecurrencePattern, startDate time.Time) (int, bool) {
+	days := daysInMonth(year, month)
+
+	if pattern.WeekOfMonth != 0 {
+		wd := weekdaysByName[pattern.DayOfWeek]
+		if pattern.WeekOfMonth < 0 {
+			lastWeekday := time.Date(year, month, days, 0, 0, 0, 0, time.UTC).Weekday()
+			return days - (int(lastWeekday)-int(wd)+7)%7, true
+		}
+
+		firstWeekday := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC).Weekday()
+		day := 1 + (int(wd)-int(firstWeekday)+7)%7 + 7*(pattern.WeekOfMonth-1)
+		return day, day <= days
+	}
+
+	day := pattern.DayOfMonth
+	if day == 0 {
+		day = startDate.Day()
+	}
+	if day < 0 {
+		return days, true
+	}
+	return min(day, days), true
+}
+
+// daysInMonth returns the number of days in the given month
+func daysInMonth(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// monthsBetween returns the number of calendar months from a's month to b's
+func monthsBetween(a, b time.Time) int {
+	return (b.Year()-a.Year())*12 + int(b.Month()) - int(a.Month())
+}
+
+// calculateNextWeeklyDate finds the first occurrence after the given time of
+// a pattern that repeats on the given days every interval weeks.
+//
+// Weeks run Monday to Sunday (matching RRULE's default WKST=MO) and are
+// counted from the week containing startDate, so with an interval of 2 the
+// start week, the week two after it, and so on are active. Occurrences fall
+// at startDate's time of day, in its location, and never before startDate.
+func calculateNextWeeklyDate(startDate time.Time, daysOfWeek []string, interval int, after time.Time) time.Time {
+	interval = max(interval, 1)
+
+	// Days of the week as offsets from Monday, in week order
+	var offsets []int
+	seen := make(map[int]bool)
+	for _, day := range daysOfWeek {
+		if wd, ok := weekdaysByName[day]; ok && !seen[mondayOffset(wd)] {
+			seen[mondayOffset(wd)] = true
+			offsets = append(offsets, mondayOffset(wd))
+		}
+	}
+	if len(offsets) == 0 {
+		// No valid days; repeat on the start date's weekday
+		offsets = append(offsets, mondayOffset(startDate.Weekday()))
+	}
+	sort.Ints(offsets)
+
+	// Monday of the start week, at startDate's time of day
+	weekStart := startDate.AddDate(0, 0, -mondayOffset(startDate.Weekday()))
+
+	// Jump straight to the first active week that could contain the next
+	// occurrence rather than stepping through every week since the start
+	week := 0
+	if after.After(weekStart) {
+		week = daysBetween(weekStart, after.In(startDate.Location())) / 7
+		week -= week % interval
+	}
+
+	// The next occurrence is in this active week or the following one
+	for {
+		for _, offset := range offsets {
+			candidate := weekStart.AddDate(0, 0, 7*week+offset)
+			if candidate.After(after) && !candidate.Before(startDate) {
+				return candidate
+			}
+		}
+		week += interval
+	}
+}
+
+// calculateNextWeekdayDate finds the first occurrence after the given time of
+// a pattern that repeats every interval weekdays (Monday to Friday), counted
+// from startDate or, if it is at the weekend, the Monday after it.
+// Occurrences fall at startDate's time of day, in its location.
+func calculateNextWeekdayDate(startDate time.Time, interval int, after time.Time) time.Time {
+	interval = max(interval, 1)
+	first := weekdayIndex(startDate)
+
+	// Jump straight to the occurrence on or before after's day
+	n := 0
+	if after.After(startDate) {
+		n = (weekdayIndex(after.In(startDate.Location())) - first) / interval
+	}
+	for {
+		candidate := weekdayAt(first+n*interval, startDate)
+		if candidate.After(after) {
+			return candidate
+		}
+		n++
+	}
+}
+
+// weekdayEpoch is the Monday weekdays are numbered from
+var weekdayEpoch = time.Date(2000, 1, 3, 0, 0, 0, 0, time.UTC)
+
+// weekdayIndex numbers weekdays consecutively, skipping weekends: it returns
+// the number of t's day if that is a weekday, or else of the Monday after it
+func weekdayIndex(t time.Time) int {
+	days := daysBetween(weekdayEpoch, t)
+	week, day := days/7, days%7
+	if day < 0 {
+		week, day = week-1, day+7
+	}
+	return 5*week + min(day, 5)
+}
+
+// weekdayAt returns the weekday numbered index by weekdayIndex, at t's time
+// of day in its location
+func weekdayAt(index int, t time.Time) time.Time {
+	week, day := index/5, index%5
+	if day < 0 {
+		week, day = week-1, day+5
+	}
+	date := weekdayEpoch.AddDate(0, 0, 7*week+day)
+	return time.Date(date.Year(), date.Month(), date.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+}
+
+// mondayOffset returns the number of days from Monday to wd
+func mondayOffset(wd time.Weekday) int {
+	return (int(wd) + 6) % 7
+}
+
+// daysBetween returns the number of calendar days from a to b, ignoring the
+// time of day and any daylight saving transitions in between
+func daysBetween(a, b time.Time) int {
+	dayA := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	dayB := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(dayB.Sub(dayA).Hours() / 24)
+}
+
+// startOfDay returns midnight at the start of t's day in loc
+func startOfDay(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
@@ -0,0 +1,775 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// date returns 09:00 UTC on the given day
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 9, 0, 0, 0, time.UTC)
+}
+
+// occurrences returns the first n due dates of pattern after the given time,
+// feeding each result back in as the next after time
+func occurrences(t *testing.T, start time.Time, pattern RecurrencePattern, after time.Time, n int) []time.Time {
+	t.Helper()
+
+	var dates []time.Time
+	for range n {
+		next, ok := calculateNextDueDate(start, pattern, after)
+		if !ok {
+			t.Fatalf("pattern %+v ended after %d occurrences", pattern, len(dates))
+		}
+		if !next.After(after) {
+			t.Fatalf("calculateNextDueDate(%v) = %v, which is not after it", after, next)
+		}
+		dates = append(dates, next)
+		after = next
+	}
+	return dates
+}
+
+func assertDates(t *testing.T, got, want []time.Time) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d dates %v, want %d dates %v", len(got), got, len(want), want)
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Errorf("occurrence %d = %s, want %s", i, got[i].Format("Mon 2006-01-02 15:04 MST"), want[i].Format("Mon 2006-01-02 15:04 MST"))
+		}
+	}
+}
+
+func TestCalculateNextWeeklyDate(t *testing.T) {
+	// Monday 5 January 2026
+	monday := date(2026, 1, 5)
+
+	tests := []struct {
+		name     string
+		start    time.Time
+		days     []string
+		interval int
+		after    time.Time
+		want     []time.Time
+	}{
+		{
+			name:     "every week on one day",
+			start:    monday,
+			days:     []string{"Wednesday"},
+			interval: 1,
+			after:    monday,
+			want:     []time.Time{date(2026, 1, 7), date(2026, 1, 14), date(2026, 1, 21)},
+		},
+		{
+			name:     "every week on several days",
+			start:    monday,
+			days:     []string{"Monday", "Wednesday", "Friday"},
+			interval: 1,
+			after:    monday,
+			want:     []time.Time{date(2026, 1, 7), date(2026, 1, 9), date(2026, 1, 12), date(2026, 1, 14)},
+		},
+		{
+			name:     "every two weeks on Monday and Thursday",
+			start:    monday,
+			days:     []string{"Monday", "Thursday"},
+			interval: 2,
+			after:    monday.Add(-time.Minute),
+			want:     []time.Time{date(2026, 1, 5), date(2026, 1, 8), date(2026, 1, 19), date(2026, 1, 22), date(2026, 2, 2)},
+		},
+		{
+			name:     "every three weeks",
+			start:    monday,
+			days:     []string{"Saturday"},
+			interval: 3,
+			after:    monday,
+			want:     []time.Time{date(2026, 1, 10), date(2026, 1, 31), date(2026, 2, 21)},
+		},
+		{
+			name:     "start mid-week skips earlier days of the start week",
+			start:    date(2026, 1, 7), // Wednesday
+			days:     []string{"Monday", "Thursday"},
+			interval: 2,
+			after:    date(2026, 1, 1),
+			want:     []time.Time{date(2026, 1, 8), date(2026, 1, 19), date(2026, 1, 22)},
+		},
+		{
+			name:     "start on Sunday belongs to the week starting the previous Monday",
+			start:    date(2026, 1, 11), // Sunday
+			days:     []string{"Monday", "Sunday"},
+			interval: 2,
+			after:    date(2026, 1, 1),
+			want:     []time.Time{date(2026, 1, 11), date(2026, 1, 19), date(2026, 1, 25), date(2026, 2, 2)},
+		},
+		{
+			name:     "after falls in an off week",
+			start:    monday,
+			days:     []string{"Tuesday"},
+			interval: 2,
+			after:    date(2026, 1, 14),
+			want:     []time.Time{date(2026, 1, 20), date(2026, 2, 3)},
+		},
+		{
+			name:     "after falls long after the start",
+			start:    monday,
+			days:     []string{"Friday"},
+			interval: 4,
+			after:    date(2027, 6, 1),
+			want:     []time.Time{date(2027, 6, 25), date(2027, 7, 23)},
+		},
+		{
+			name:     "after is exactly an occurrence",
+			start:    monday,
+			days:     []string{"Monday", "Friday"},
+			interval: 2,
+			after:    date(2026, 1, 9),
+			want:     []time.Time{date(2026, 1, 19), date(2026, 1, 23)},
+		},
+		{
+			name:     "after is earlier the same day as an occurrence",
+			start:    monday,
+			days:     []string{"Friday"},
+			interval: 1,
+			after:    time.Date(2026, 1, 9, 8, 0, 0, 0, time.UTC),
+			want:     []time.Time{date(2026, 1, 9)},
+		},
+		{
+			name:     "unordered and duplicate days",
+			start:    monday,
+			days:     []string{"Friday", "Tuesday", "Friday"},
+			interval: 1,
+			after:    monday,
+			want:     []time.Time{date(2026, 1, 6), date(2026, 1, 9), date(2026, 1, 13)},
+		},
+		{
+			name:     "interval below one is treated as one",
+			start:    monday,
+			days:     []string{"Monday"},
+			interval: 0,
+			after:    monday,
+			want:     []time.Time{date(2026, 1, 12), date(2026, 1, 19)},
+		},
+		{
+			name:     "no valid days falls back to the start weekday",
+			start:    monday,
+			days:     []string{"Someday"},
+			interval: 2,
+			after:    monday,
+			want:     []time.Time{date(2026, 1, 19), date(2026, 2, 2)},
+		},
+		{
+			name:     "crosses a year boundary",
+			start:    date(2025, 12, 22), // Monday
+			days:     []string{"Wednesday"},
+			interval: 2,
+			after:    date(2025, 12, 22),
+			want:     []time.Time{date(2025, 12, 24), date(2026, 1, 7), date(2026, 1, 21)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pattern := RecurrencePattern{Frequency: "weekly", Interval: tt.interval, DaysOfWeek: tt.days}
+			assertDates(t, occurrences(t, tt.start, pattern, tt.after, len(tt.want)), tt.want)
+		})
+	}
+}
+
+func TestCalculateNextWeeklyDateKeepsLocalTimeAcrossDST(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+
+	// Clocks go forward on Sunday 29 March 2026
+	start := time.Date(2026, 3, 16, 9, 0, 0, 0, london) // Monday
+	pattern := RecurrencePattern{Frequency: "weekly", Interval: 2, DaysOfWeek: []string{"Monday"}}
+
+	got := occurrences(t, start, pattern, start, 2)
+	want := []time.Time{
+		time.Date(2026, 3, 30, 9, 0, 0, 0, london),
+		time.Date(2026, 4, 13, 9, 0, 0, 0, london),
+	}
+	assertDates(t, got, want)
+}
+
+func TestCalculateNextWeeklyDateIsDeterministic(t *testing.T) {
+	start := date(2026, 1, 7) // Wednesday
+	pattern := RecurrencePattern{Frequency: "weekly", Interval: 2, DaysOfWeek: []string{"Monday", "Thursday", "Saturday"}}
+
+	// Walking occurrence by occurrence and jumping straight to any point in
+	// time must agree, however many hours past the previous occurrence the
+	// evaluation happens
+	all := occurrences(t, start, pattern, start.Add(-time.Second), 60)
+	for i := 1; i < len(all); i++ {
+		for _, offset := range []time.Duration{0, time.Hour, 23 * time.Hour} {
+			after := all[i-1].Add(offset)
+			if !after.Before(all[i]) {
+				continue
+			}
+			got, _ := calculateNextDueDate(start, pattern, after)
+			if !got.Equal(all[i]) {
+				t.Errorf("calculateNextDueDate(%v) = %v, want %v", after, got, all[i])
+			}
+		}
+	}
+
+	// Only the start week and every second week after it are active
+	weekStart := date(2026, 1, 5)
+	for _, occurrence := range all {
+		if week := daysBetween(weekStart, occurrence) / 7; week%2 != 0 {
+			t.Errorf("occurrence %v falls in off week %d", occurrence, week)
+		}
+	}
+}
+
+func TestWeeklyPatternMatchesRRule(t *testing.T) {
+	dayNames := []string{"Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday", "Sunday"}
+	daySets := [][]string{
+		{"Monday"},
+		{"Sunday"},
+		{"Monday", "Thursday"},
+		{"Tuesday", "Saturday", "Sunday"},
+		dayNames,
+	}
+
+	for startDay := 5; startDay <= 11; startDay++ { // Monday 5 to Sunday 11 January 2026
+		start := date(2026, 1, startDay)
+		for interval := 1; interval <= 3; interval++ {
+			for _, days := range daySets {
+				pattern := RecurrencePattern{Frequency: "weekly", Interval: interval, DaysOfWeek: days}
+				rule, err := pattern.toRRule(start)
+				if err != nil {
+					t.Fatalf("toRRule: %v", err)
+				}
+
+				name := fmt.Sprintf("start %s, %s", start.Weekday(), rule)
+				simple := occurrences(t, start, pattern, start.Add(-time.Second), 20)
+				viaRRule := occurrences(t, start, RecurrencePattern{RRule: rule}, start.Add(-time.Second), 20)
+				for i := range simple {
+					if !simple[i].Equal(viaRRule[i]) {
+						t.Errorf("%s: occurrence %d is %v, but the RRULE gives %v", name, i, simple[i], viaRRule[i])
+						break
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestCalculateNextDueDateDailyAndMonthly(t *testing.T) {
+	start := date(2026, 1, 10)
+
+	daily := RecurrencePattern{Frequency: "daily", Interval: 3}
+	assertDates(t, occurrences(t, start, daily, start, 3),
+		[]time.Time{date(2026, 1, 13), date(2026, 1, 16), date(2026, 1, 19)})
+
+	weekly := RecurrencePattern{Frequency: "weekly", Interval: 2}
+	assertDates(t, occurrences(t, start, weekly, date(2026, 2, 1), 2),
+		[]time.Time{date(2026, 2, 7), date(2026, 2, 21)})
+
+	monthly := RecurrencePattern{Frequency: "monthly", Interval: 1}
+	assertDates(t, occurrences(t, start, monthly, start, 3),
+		[]time.Time{date(2026, 2, 10), date(2026, 3, 10), date(2026, 4, 10)})
+}
+
+func TestDaysBetween(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+
+	tests := []struct {
+		a, b time.Time
+		want int
+	}{
+		{date(2026, 1, 1), date(2026, 1, 1), 0},
+		{date(2026, 1, 1), time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC), 1},
+		{date(2026, 1, 31), date(2026, 3, 1), 29},
+		{date(2026, 1, 10), date(2026, 1, 3), -7},
+		{time.Date(2026, 3, 28, 23, 0, 0, 0, london), time.Date(2026, 3, 30, 0, 30, 0, 0, london), 2},
+	}
+	for _, tt := range tests {
+		if got := daysBetween(tt.a, tt.b); got != tt.want {
+			t.Errorf("daysBetween(%v, %v) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestCalculateNextMonthlyDate(t *testing.T) {
+	tests := []struct {
+		name    string
+		start   time.Time
+		pattern RecurrencePattern
+		after   time.Time
+		want    []time.Time
+	}{
+		{
+			name:    "31st clamps to short months without drifting",
+			start:   date(2026, 1, 31),
+			pattern: RecurrencePattern{Frequency: "monthly", Interval: 1},
+			after:   date(2026, 1, 31),
+			want:    []time.Time{date(2026, 2, 28), date(2026, 3, 31), date(2026, 4, 30), date(2026, 5, 31)},
+		},
+		{
+			name:    "29th in a leap year",
+			start:   date(2027, 12, 29),
+			pattern: RecurrencePattern{Frequency: "monthly", Interval: 2},
+			after:   date(2027, 12, 29),
+			want:    []time.Time{date(2028, 2, 29), date(2028, 4, 29)},
+		},
+		{
+			name:    "explicit day of month",
+			start:   date(2026, 1, 10),
+			pattern: RecurrencePattern{Frequency: "monthly", Interval: 1, DayOfMonth: 30},
+			after:   date(2026, 1, 10),
+			want:    []time.Time{date(2026, 1, 30), date(2026, 2, 28), date(2026, 3, 30)},
+		},
+		{
+			name:    "explicit day earlier than the start day starts next month",
+			start:   date(2026, 1, 10),
+			pattern: RecurrencePattern{Frequency: "monthly", Interval: 1, DayOfMonth: 1},
+			after:   date(2026, 1, 10),
+			want:    []time.Time{date(2026, 2, 1), date(2026, 3, 1)},
+		},
+		{
+			name:    "last day of month",
+			start:   date(2026, 1, 5),
+			pattern: RecurrencePattern{Frequency: "monthly", Interval: 1, DayOfMonth: -1},
+			after:   date(2026, 1, 5),
+			want:    []time.Time{date(2026, 1, 31), date(2026, 2, 28), date(2026, 3, 31), date(2026, 4, 30)},
+		},
+		{
+			name:    "second Tuesday",
+			start:   date(2026, 1, 1),
+			pattern: RecurrencePattern{Frequency: "monthly", Interval: 1, WeekOfMonth: 2, DayOfWeek: "Tuesday"},
+			after:   date(2026, 1, 1),
+			want:    []time.Time{date(2026, 1, 13), date(2026, 2, 10), date(2026, 3, 10)},
+		},
+		{
+			name:    "first Sunday when the month starts on a Sunday",
+			start:   date(2026, 2, 1),
+			pattern: RecurrencePattern{Frequency: "monthly", Interval: 1, WeekOfMonth: 1, DayOfWeek: "Sunday"},
+			after:   date(2026, 1, 1),
+			want:    []time.Time{date(2026, 2, 1), date(2026, 3, 1), date(2026, 4, 5)},
+		},
+		{
+			name:    "last Friday",
+			start:   date(2026, 1, 1),
+			pattern: RecurrencePattern{Frequency: "monthly", Interval: 1, WeekOfMonth: -1, DayOfWeek: "Friday"},
+			after:   date(2026, 1, 1),
+			want:    []time.Time{date(2026, 1, 30), date(2026, 2, 27), date(2026, 3, 27), date(2026, 4, 24)},
+		},
+		{
+			name:    "fifth Friday skips months without one",
+			start:   date(2026, 1, 1),
+			pattern: RecurrencePattern{Frequency: "monthly", Interval: 1, WeekOfMonth: 5, DayOfWeek: "Friday"},
+			after:   date(2026, 1, 1),
+			want:    []time.Time{date(2026, 1, 30), date(2026, 5, 29), date(2026, 7, 31)},
+		},
+		{
+			name:    "every three months anchored to the start month",
+			start:   date(2026, 1, 15),
+			pattern: RecurrencePattern{Frequency: "monthly", Interval: 3},
+			after:   date(2026, 5, 20),
+			want:    []time.Time{date(2026, 7, 15), date(2026, 10, 15), date(2027, 1, 15)},
+		},
+		{
+			name:    "after long after the start",
+			start:   date(2026, 1, 31),
+			pattern: RecurrencePattern{Frequency: "monthly", Interval: 1},
+			after:   date(2031, 2, 1),
+			want:    []time.Time{date(2031, 2, 28), date(2031, 3, 31)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertDates(t, occurrences(t, tt.start, tt.pattern, tt.after, len(tt.want)), tt.want)
+		})
+	}
+}
+
+func TestMonthlyPatternMatchesRRule(t *testing.T) {
+	patterns := []RecurrencePattern{
+		{Frequency: "monthly"},
+		{Frequency: "monthly", DayOfMonth: 29},
+		{Frequency: "monthly", DayOfMonth: 31},
+		{Frequency: "monthly", DayOfMonth: -1},
+		{Frequency: "monthly", WeekOfMonth: 1, DayOfWeek: "Monday"},
+		{Frequency: "monthly", WeekOfMonth: 4, DayOfWeek: "Wednesday"},
+		{Frequency: "monthly", WeekOfMonth: 5, DayOfWeek: "Saturday"},
+		{Frequency: "monthly", WeekOfMonth: -1, DayOfWeek: "Sunday"},
+	}
+
+	for _, startDay := range []int{1, 15, 28, 29, 30, 31} {
+		start := date(2026, 1, startDay)
+		for interval := 1; interval <= 3; interval++ {
+			for _, pattern := range patterns {
+				pattern.Interval = interval
+				rule, err := pattern.toRRule(start)
+				if err != nil {
+					t.Fatalf("toRRule: %v", err)
+				}
+
+				simple := occurrences(t, start, pattern, start.Add(-time.Second), 24)
+				viaRRule := occurrences(t, start, RecurrencePattern{RRule: rule}, start.Add(-time.Second), 24)
+				for i := range simple {
+					if !simple[i].Equal(viaRRule[i]) {
+						t.Errorf("start %s, %s: occurrence %d is %v, but the RRULE gives %v",
+							start.Format("2006-01-02"), rule, i, simple[i], viaRRule[i])
+						break
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestValidateMonthlyPatterns(t *testing.T) {
+	valid := []RecurrencePattern{
+		{Frequency: "monthly", Interval: 1, DayOfMonth: 31},
+		{Frequency: "monthly", Interval: 1, DayOfMonth: -1},
+		{Frequency: "monthly", Interval: 1, WeekOfMonth: 2, DayOfWeek: "Tuesday"},
+		{Frequency: "monthly", Interval: 1, WeekOfMonth: -1, DayOfWeek: "Friday"},
+	}
+	for _, pattern := range valid {
+		if err := validateRecurrencePattern(pattern); err != nil {
+			t.Errorf("validateRecurrencePattern(%+v) = %v, want nil", pattern, err)
+		}
+	}
+
+	invalid := []RecurrencePattern{
+		{Frequency: "monthly", Interval: 1, DayOfMonth: 32},
+		{Frequency: "monthly", Interval: 1, DayOfMonth: -2},
+		{Frequency: "monthly", Interval: 1, WeekOfMonth: 6, DayOfWeek: "Monday"},
+		{Frequency: "monthly", Interval: 1, WeekOfMonth: 2},
+		{Frequency: "monthly", Interval: 1, DayOfWeek: "Monday"},
+		{Frequency: "monthly", Interval: 1, WeekOfMonth: 1, DayOfWeek: "Funday"},
+		{Frequency: "monthly", Interval: 1, DayOfMonth: 3, WeekOfMonth: 1, DayOfWeek: "Monday"},
+		{Frequency: "weekly", Interval: 1, DayOfMonth: 3},
+	}
+	for _, pattern := range invalid {
+		if err := validateRecurrencePattern(pattern); err == nil {
+			t.Errorf("validateRecurrencePattern(%+v) = nil, want an error", pattern)
+		}
+	}
+}
+
+func TestCalculateNextYearlyDate(t *testing.T) {
+	tests := []struct {
+		name    string
+		start   time.Time
+		pattern RecurrencePattern
+		want    []time.Time
+	}{
+		{
+			name:    "every year",
+			start:   date(2026, 10, 1),
+			pattern: RecurrencePattern{Frequency: "yearly", Interval: 1},
+			want:    []time.Time{date(2027, 10, 1), date(2028, 10, 1), date(2029, 10, 1)},
+		},
+		{
+			name:    "every other year",
+			start:   date(2026, 3, 15),
+			pattern: RecurrencePattern{Frequency: "yearly", Interval: 2},
+			want:    []time.Time{date(2028, 3, 15), date(2030, 3, 15), date(2032, 3, 15)},
+		},
+		{
+			name:    "29 February falls on the 28th in other years",
+			start:   date(2028, 2, 29),
+			pattern: RecurrencePattern{Frequency: "yearly", Interval: 1},
+			want:    []time.Time{date(2029, 2, 28), date(2030, 2, 28), date(2031, 2, 28), date(2032, 2, 29)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertDates(t, occurrences(t, tt.start, tt.pattern, tt.start, len(tt.want)), tt.want)
+		})
+	}
+}
+
+func TestActiveMonthsSuppressOccurrences(t *testing.T) {
+	aprilToOctober := []int{4, 5, 6, 7, 8, 9, 10}
+
+	tests := []struct {
+		name    string
+		start   time.Time
+		pattern RecurrencePattern
+		after   time.Time
+		want    []time.Time
+	}{
+		{
+			name:    "weekly mowing starts in April",
+			start:   date(2026, 3, 7),
+			pattern: RecurrencePattern{Frequency: "weekly", Interval: 1, DaysOfWeek: []string{"Saturday"}, ActiveMonths: aprilToOctober},
+			after:   date(2026, 3, 7),
+			want:    []time.Time{date(2026, 4, 4), date(2026, 4, 11)},
+		},
+		{
+			name:    "weekly mowing resumes the next April",
+			start:   date(2026, 3, 7),
+			pattern: RecurrencePattern{Frequency: "weekly", Interval: 1, DaysOfWeek: []string{"Saturday"}, ActiveMonths: aprilToOctober},
+			after:   date(2026, 10, 24),
+			want:    []time.Time{date(2026, 10, 31), date(2027, 4, 3)},
+		},
+		{
+			name:    "window wrapping the new year",
+			start:   date(2026, 10, 30),
+			pattern: RecurrencePattern{Frequency: "daily", Interval: 1, ActiveMonths: []int{11, 12, 1, 2}},
+			after:   date(2027, 2, 27),
+			want:    []time.Time{date(2027, 2, 28), date(2027, 11, 1), date(2027, 11, 2)},
+		},
+		{
+			name:    "monthly skips inactive months",
+			start:   date(2026, 1, 15),
+			pattern: RecurrencePattern{Frequency: "monthly", Interval: 1, ActiveMonths: []int{3, 6, 9, 12}},
+			after:   date(2026, 1, 15),
+			want:    []time.Time{date(2026, 3, 15), date(2026, 6, 15), date(2026, 9, 15), date(2026, 12, 15), date(2027, 3, 15)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertDates(t, occurrences(t, tt.start, tt.pattern, tt.after, len(tt.want)), tt.want)
+		})
+	}
+}
+
+func TestYearlyAndSeasonalPatternsMatchRRule(t *testing.T) {
+	patterns := []RecurrencePattern{
+		{Frequency: "yearly", Interval: 1},
+		{Frequency: "yearly", Interval: 3},
+		{Frequency: "daily", Interval: 5, ActiveMonths: []int{12, 1, 2}},
+		{Frequency: "weekly", Interval: 2, DaysOfWeek: []string{"Tuesday", "Saturday"}, ActiveMonths: []int{4, 5, 6, 7, 8, 9, 10}},
+		{Frequency: "monthly", Interval: 2, DayOfMonth: 31, ActiveMonths: []int{2, 3, 4, 5}},
+		{Frequency: "monthly", Interval: 1, WeekOfMonth: -1, DayOfWeek: "Friday", ActiveMonths: []int{9, 10, 11}},
+	}
+
+	for _, start := range []time.Time{date(2026, 1, 31), date(2028, 2, 29), date(2026, 7, 14)} {
+		for _, pattern := range patterns {
+			rule, err := pattern.toRRule(start)
+			if err != nil {
+				t.Fatalf("toRRule: %v", err)
+			}
+
+			simple := occurrences(t, start, pattern, start.Add(-time.Second), 12)
+			viaRRule := occurrences(t, start, RecurrencePattern{RRule: rule}, start.Add(-time.Second), 12)
+			for i := range simple {
+				if !simple[i].Equal(viaRRule[i]) {
+					t.Errorf("start %s, %s: occurrence %d is %v, but the RRULE gives %v",
+						start.Format("2006-01-02"), rule, i, simple[i], viaRRule[i])
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestValidateYearlyAndSeasonalPatterns(t *testing.T) {
+	valid := []RecurrencePattern{
+		{Frequency: "yearly", Interval: 1},
+		{Frequency: "weekly", Interval: 1, DaysOfWeek: []string{"Saturday"}, ActiveMonths: []int{4, 5, 6, 7, 8, 9, 10}},
+		{Frequency: "daily", Interval: 1, ActiveMonths: []int{12, 1, 2}},
+	}
+	for _, pattern := range valid {
+		if err := validateRecurrencePattern(pattern); err != nil {
+			t.Errorf("validateRecurrencePattern(%+v) = %v, want nil", pattern, err)
+		}
+	}
+
+	invalid := []RecurrencePattern{
+		{Frequency: "yearly", Interval: 0},
+		{Frequency: "yearly", Interval: 1, ActiveMonths: []int{4}},
+		{Frequency: "daily", Interval: 1, ActiveMonths: []int{0}},
+		{Frequency: "daily", Interval: 1, ActiveMonths: []int{13}},
+		{RRule: "FREQ=DAILY", ActiveMonths: []int{4}},
+	}
+	for _, pattern := range invalid {
+		if err := validateRecurrencePattern(pattern); err == nil {
+			t.Errorf("validateRecurrencePattern(%+v) = nil, want an error", pattern)
+		}
+	}
+}
+
+func TestDefinitionNextDueDateHonorsEndConditions(t *testing.T) {
+	start := date(2026, 1, 5)
+	endDate := date(2026, 1, 7)
+
+	tests := []struct {
+		name string
+		def  RecurringItemDefinition
+		want []time.Time
+	}{
+		{
+			name: "end date is inclusive",
+			def:  RecurringItemDefinition{StartDate: start, Pattern: RecurrencePattern{Frequency: "daily", Interval: 1}, EndDate: &endDate},
+			want: []time.Time{date(2026, 1, 5), date(2026, 1, 6), date(2026, 1, 7)},
+		},
+		{
+			name: "occurrences are counted from the start date",
+			def:  RecurringItemDefinition{StartDate: start, Pattern: RecurrencePattern{Frequency: "weekly", Interval: 1, DaysOfWeek: []string{"Monday", "Thursday"}}, MaxOccurrences: 4},
+			want: []time.Time{date(2026, 1, 5), date(2026, 1, 8), date(2026, 1, 12), date(2026, 1, 15)},
+		},
+		{
+			name: "the earlier condition wins",
+			def:  RecurringItemDefinition{StartDate: start, Pattern: RecurrencePattern{Frequency: "daily", Interval: 1}, EndDate: &endDate, MaxOccurrences: 2},
+			want: []time.Time{date(2026, 1, 5), date(2026, 1, 6)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []time.Time
+			after := start.Add(-time.Second)
+			for {
+				next, ok := tt.def.nextDueDate(after)
+				if !ok {
+					break
+				}
+				if len(got) > len(tt.want) {
+					t.Fatalf("too many occurrences: %v", got)
+				}
+				got = append(got, next)
+				after = next
+			}
+			assertDates(t, got, tt.want)
+		})
+	}
+}
+
+func TestValidateEndConditions(t *testing.T) {
+	pattern := RecurrencePattern{Frequency: "daily", Interval: 1}
+	start := date(2026, 1, 5)
+	before := start.AddDate(0, 0, -1)
+
+	if err := validateRecurringDefinition(&RecurringItemDefinition{Title: "Physio", Pattern: pattern, StartDate: start, MaxOccurrences: 10}); err != nil {
+		t.Errorf("validateRecurringDefinition with maxOccurrences: %v", err)
+	}
+	if err := validateRecurringDefinition(&RecurringItemDefinition{Title: "Physio", Pattern: pattern, StartDate: start, MaxOccurrences: -1}); err == nil {
+		t.Error("expected a negative maxOccurrences to be rejected")
+	}
+	if err := validateRecurringDefinition(&RecurringItemDefinition{Title: "Physio", Pattern: pattern, StartDate: start, MaxOccurrences: maxOccurrencesLimit}); err != nil {
+		t.Errorf("validateRecurringDefinition with the largest maxOccurrences: %v", err)
+	}
+	if err := validateRecurringDefinition(&RecurringItemDefinition{Title: "Physio", Pattern: pattern, StartDate: start, MaxOccurrences: maxOccurrencesLimit + 1}); err == nil {
+		t.Error("expected a maxOccurrences over the limit to be rejected")
+	}
+	if err := validateRecurringDefinition(&RecurringItemDefinition{Title: "Physio", Pattern: pattern, StartDate: start, EndDate: &before}); err == nil {
+		t.Error("expected an end date before the start date to be rejected")
+	}
+}
+
+func TestMaxOccurrencesCountFromCreation(t *testing.T) {
+	// Created mid-series, so occurrences before then are not counted
+	def := RecurringItemDefinition{
+		StartDate:      date(2026, 1, 1),
+		CreatedAt:      date(2026, 3, 1).Add(time.Hour),
+		Pattern:        RecurrencePattern{Frequency: "daily", Interval: 1},
+		MaxOccurrences: 2,
+	}
+
+	after := def.CreatedAt
+	var got []time.Time
+	for next, ok := def.nextDueDate(after); ok; next, ok = def.nextDueDate(after) {
+		got = append(got, next)
+		after = next
+	}
+	assertDates(t, got, []time.Time{date(2026, 3, 2), date(2026, 3, 3)})
+}
+
+func TestFindOccurrenceLimit(t *testing.T) {
+	def := RecurringItemDefinition{
+		StartDate:      date(2026, 1, 1),
+		Pattern:        RecurrencePattern{Frequency: "daily", Interval: 1},
+		MaxOccurrences: maxOccurrencesLimit,
+	}
+	if end := def.findOccurrenceLimit(); !end.limited || !end.last.Equal(date(2026, 1, 1).AddDate(0, 0, maxOccurrencesLimit-1)) {
+		t.Errorf("limit of %d daily occurrences = %+v", maxOccurrencesLimit, end)
+	}
+
+	def.Pattern.Interval = 2
+	def.MaxOccurrences = 3
+	if end := def.findOccurrenceLimit(); !end.limited || !end.last.Equal(date(2026, 1, 5)) {
+		t.Errorf("limit of 3 occurrences every other day = %+v, want 5 January", end)
+	}
+
+	// A pattern that ends first leaves nothing for MaxOccurrences to limit
+	def.Pattern = RecurrencePattern{RRule: "FREQ=DAILY;COUNT=2"}
+	if end := def.findOccurrenceLimit(); end.limited {
+		t.Errorf("limit of 3 occurrences of a 2 occurrence RRULE = %+v, want none", end)
+	}
+	def.MaxOccurrences = 0
+	if end := def.findOccurrenceLimit(); end.limited {
+		t.Errorf("limit with no maxOccurrences = %+v, want none", end)
+	}
+}
+
+func TestCalculateNextCompletionDueDate(t *testing.T) {
+	completedAt := time.Date(2026, 1, 31, 18, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		pattern RecurrencePattern
+		want    time.Time
+	}{
+		{RecurrencePattern{Frequency: "daily", Interval: 10}, time.Date(2026, 2, 10, 18, 30, 0, 0, time.UTC)},
+		{RecurrencePattern{Frequency: "weekly", Interval: 2}, time.Date(2026, 2, 14, 18, 30, 0, 0, time.UTC)},
+		{RecurrencePattern{Frequency: "monthly", Interval: 1}, time.Date(2026, 2, 28, 18, 30, 0, 0, time.UTC)},
+		{RecurrencePattern{Frequency: "monthly", Interval: 2}, time.Date(2026, 3, 31, 18, 30, 0, 0, time.UTC)},
+		{RecurrencePattern{Frequency: "yearly", Interval: 1}, time.Date(2027, 1, 31, 18, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		tt.pattern.Mode = recurrenceModeAfterCompletion
+		if got := calculateNextCompletionDueDate(tt.pattern, completedAt); !got.Equal(tt.want) {
+			t.Errorf("%d %s after completion = %v, want %v", tt.pattern.Interval, tt.pattern.Frequency, got, tt.want)
+		}
+	}
+}
+
+func TestValidateAfterCompletionPatterns(t *testing.T) {
+	valid := []RecurrencePattern{
+		{Frequency: "daily", Interval: 3, Mode: recurrenceModeAfterCompletion},
+		{Frequency: "monthly", Interval: 1, Mode: recurrenceModeAfterCompletion},
+	}
+	for _, pattern := range valid {
+		if err := validateRecurrencePattern(pattern); err != nil {
+			t.Errorf("validateRecurrencePattern(%+v) = %v, want nil", pattern, err)
+		}
+	}
+
+	invalid := []RecurrencePattern{
+		{Frequency: "daily", Interval: 1, Mode: "whenever"},
+		{Frequency: "weekly", Interval: 1, DaysOfWeek: []string{"Monday"}, Mode: recurrenceModeAfterCompletion},
+		{Frequency: "monthly", Interval: 1, DayOfMonth: -1, Mode: recurrenceModeAfterCompletion},
+		{Frequency: "daily", Interval: 1, ActiveMonths: []int{4}, Mode: recurrenceModeAfterCompletion},
+		{RRule: "FREQ=DAILY", Mode: recurrenceModeAfterCompletion},
+	}
+	for _, pattern := range invalid {
+		if err := validateRecurrencePattern(pattern); err == nil {
+			t.Errorf("validateRecurrencePattern(%+v) = nil, want an error", pattern)
+		}
+	}
+
+	def := &RecurringItemDefinition{Title: "Change the filter", Pattern: valid[1], MaxOccurrences: 3}
+	if err := validateRecurringDefinition(def); err == nil {
+		t.Error("expected maxOccurrences to be rejected for an afterCompletion pattern")
+	}
+}
+
+func TestExceptionDatesAreSkipped(t *testing.T) {
+	start := date(2026, 1, 5)
+	// Exceptions match by day, whatever their time of day
+	exceptions := []time.Time{time.Date(2026, 1, 12, 0, 0, 0, 0, time.UTC), date(2026, 1, 26)}
+
+	for _, pattern := range []RecurrencePattern{
+		{Frequency: "weekly", Interval: 1, DaysOfWeek: []string{"Monday"}, ExceptionDates: exceptions},
+		{RRule: "FREQ=WEEKLY;BYDAY=MO", ExceptionDates: exceptions},
+	} {
+		got := occurrences(t, start, pattern, start.Add(-time.Second), 3)
+		assertDates(t, got, []time.Time{date(2026, 1, 5), date(2026, 1, 19), date(2026, 2, 2)})
+	}
+}
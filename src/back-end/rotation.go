@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// A recurring definition with a rotation assigns each instance to one person
+// from its AssignedTo list rather than to everyone, so chores can be shared
+// out fairly. The rotation's state is saved on the definition whenever an
+// instance is assigned or completed.
+
+// Rotation modes
+const (
+	rotationRoundRobin  = "roundRobin"  // Each instance goes to the next person in AssignedTo
+	rotationLeastRecent = "leastRecent" // Each instance goes to whoever completed one least recently
+)
+
+// AssigneeRotation controls how a recurring definition's instances are
+// assigned among its AssignedTo list
+type AssigneeRotation struct {
+	Mode          string               `json:"mode"`                    // "roundRobin" or "leastRecent"
+	Next          int                  `json:"next"`                    // roundRobin: index in AssignedTo of the next assignee
+	LastCompleted map[string]time.Time `json:"lastCompleted,omitempty"` // When each assignee last completed an instance
+}
+
+// clone returns a copy of the rotation that shares no mutable state with it
+func (r *AssigneeRotation) clone() *AssigneeRotation {
+	if r == nil {
+		return nil
+	}
+	c := *r
+	if r.LastCompleted != nil {
+		c.LastCompleted = make(map[string]time.Time, len(r.LastCompleted))
+		for assignee, at := range r.LastCompleted {
+			c.LastCompleted[assignee] = at
+		}
+	}
+	return &c
+}
+
+// validateRotation validates a recurring definition's assignee rotation
+func validateRotation(def *RecurringItemDefinition) error {
+	if def.Rotation == nil {
+		return nil
+	}
+	if def.Rotation.Mode != rotationRoundRobin && def.Rotation.Mode != rotationLeastRecent {
+		return fmt.Errorf("rotation mode must be 'roundRobin' or 'leastRecent'")
+	}
+	if len(def.AssignedTo) < 2 {
+		return fmt.Errorf("rotation needs at least two assignees")
+	}
+	if def.Rotation.Next < 0 {
+		return fmt.Errorf("rotation next must not be negative")
+	}
+	return nil
+}
+
+// applyRotationUpdate sets def's rotation from an edit, keeping the state of
+// an existing rotation so the turn order carries on
+func applyRotationUpdate(def *RecurringItemDefinition, update *AssigneeRotation) {
+	if update == nil {
+		def.Rotation = nil
+		return
+	}
+	if def.Rotation == nil {
+		def.Rotation = &AssigneeRotation{Next: update.Next}
+	}
+	def.Rotation.Mode = update.Mode
+}
+
+// createRecurringInstance creates def's instance due on dueDate, assigning it
+// to the next person in def's rotation if it has one
+func createRecurringInstance(tx Store, def *RecurringItemDefinition, dueDate time.Time, position int) (*TodoItem, error) {
+	instance := newRecurringInstance(def, dueDate, position)
+	if def.Rotation != nil && len(def.AssignedTo) > 0 {
+		assignee, err := nextRotationAssignee(tx, def)
+		if err != nil {
+			return nil, err
+		}
+		instance.AssignedTo = []string{assignee}
+		if err := tx.UpdateRecurringDef(def); err != nil {
+			return nil, err
+		}
+	}
+	return instance, tx.CreateTodo(instance)
+}
+
+// nextRotationAssignee picks the assignee of def's next instance and advances
+// the rotation. The caller is responsible for saving def.
+//
+// For leastRecent rotations, people who already have a pending instance are
+// picked last, so instances created ahead of time are still shared out.
+func nextRotationAssignee(tx Store, def *RecurringItemDefinition) (string, error) {
+	if def.Rotation.Mode == rotationRoundRobin {
+		i := def.Rotation.Next % len(def.AssignedTo)
+		def.Rotation.Next = (i + 1) % len(def.AssignedTo)
+		return def.AssignedTo[i], nil
+	}
+
+	todos, err := tx.ListTodos()
+	if err != nil {
+		return "", err
+	}
+	pending := make(map[string]int)
+	for _, todo := range todos {
+		if todo.RecurrenceID != nil && *todo.RecurrenceID == def.ID && !todo.Completed {
+			for _, assignee := range todo.AssignedTo {
+				pending[strings.ToLower(assignee)]++
+			}
+		}
+	}
+
+	best := 0
+	for i, assignee := range def.AssignedTo {
+		chosen := def.AssignedTo[best]
+		if n, m := pending[strings.ToLower(assignee)], pending[strings.ToLower(chosen)]; n != m {
+			if n < m {
+				best = i
+			}
+			continue
+		}
+		if def.Rotation.LastCompleted[assignee].Before(def.Rotation.LastCompleted[chosen]) {
+			best = i
+		}
+	}
+	return def.AssignedTo[best], nil
+}
+
+// creditRotationCompletion records that a newly completed recurring instance
+// was done, crediting whoever completed it if they are one of the definition's
+// assignees, or otherwise the person it was assigned to
+func creditRotationCompletion(tx Store, todo *TodoItem) error {
+	if todo.RecurrenceID == nil || todo.CompletedAt == nil {
+		return nil
+	}
+
+	def, err := tx.GetRecurringDef(*todo.RecurrenceID)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if def.Rotation == nil {
+		return nil
+	}
+
+	assignee := rotationMember(def.AssignedTo, todo.CompletedBy)
+	if assignee == "" && len(todo.AssignedTo) == 1 {
+		assignee = rotationMember(def.AssignedTo, todo.AssignedTo[0])
+	}
+	if assignee == "" {
+		return nil
+	}
+
+	if def.Rotation.LastCompleted == nil {
+		def.Rotation.LastCompleted = make(map[string]time.Time)
+	}
+	def.Rotation.LastCompleted[assignee] = *todo.CompletedAt
+	return tx.UpdateRecurringDef(def)
+}
+
+// rotationMember returns the entry of assignedTo matching who, which may be
+// an email address whose local part is used as the assignee's name, or ""
+// if there is none
+func rotationMember(assignedTo []string, who string) string {
+	if who == "" {
+		return ""
+	}
+	name, _, _ := strings.Cut(who, "@")
+	for _, assignee := range assignedTo {
+		if isAssignedTo([]string{assignee}, who) || isAssignedTo([]string{assignee}, name) {
+			return assignee
+		}
+	}
+	return ""
+}
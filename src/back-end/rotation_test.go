@@ -0,0 +1,157 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// createRotatingDefinition stores a daily definition rotating between alice,
+// bob and carol, with its first instance created the way createRecurringDef
+// does
+func createRotatingDefinition(t *testing.T, s Store, mode string, due time.Time) (*RecurringItemDefinition, *TodoItem) {
+	t.Helper()
+
+	def := &RecurringItemDefinition{
+		Title:      "Wash up",
+		AssignedTo: []string{"alice", "bob", "carol"},
+		Pattern:    RecurrencePattern{Frequency: "daily", Interval: 1},
+		StartDate:  due,
+		CreatedAt:  time.Now(),
+		Rotation:   &AssigneeRotation{Mode: mode},
+	}
+	var todo *TodoItem
+	err := s.Update(func(tx Store) error {
+		if err := tx.CreateRecurringDef(def); err != nil {
+			return err
+		}
+		var err error
+		todo, err = createRecurringInstance(tx, def, due, 0)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("creating rotating definition: %v", err)
+	}
+	return def, todo
+}
+
+// completeTodoAs marks todo complete on behalf of who, the same way
+// updateTodo does
+func completeTodoAs(t *testing.T, s Store, todo *TodoItem, who string) {
+	t.Helper()
+
+	err := s.Update(func(tx Store) error {
+		current, err := tx.GetTodo(todo.ID)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		current.Completed = true
+		current.CompletedAt = &now
+		current.CompletedBy = who
+		if err := creditRotationCompletion(tx, current); err != nil {
+			return err
+		}
+		if err := spawnNextInstance(tx, current); err != nil {
+			return err
+		}
+		*todo = *current
+		return tx.UpdateTodo(current)
+	})
+	if err != nil {
+		t.Fatalf("completing todo %d: %v", todo.ID, err)
+	}
+}
+
+func TestRoundRobinRotation(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			now := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+			def, _ := createRotatingDefinition(t, s, rotationRoundRobin, now.Add(time.Hour))
+			if _, err := materializeUpcoming(s, now, 4*24*time.Hour); err != nil {
+				t.Fatalf("materializeUpcoming: %v", err)
+			}
+
+			var got []string
+			for _, todo := range instancesOf(t, s, def.ID) {
+				if len(todo.AssignedTo) != 1 {
+					t.Fatalf("instance assigned to %v, want one person", todo.AssignedTo)
+				}
+				got = append(got, todo.AssignedTo[0])
+			}
+			want := []string{"alice", "bob", "carol", "alice"}
+			if len(got) != len(want) {
+				t.Fatalf("assignees = %v, want %v", got, want)
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Fatalf("assignees = %v, want %v", got, want)
+				}
+			}
+
+			saved, err := s.GetRecurringDef(def.ID)
+			if err != nil {
+				t.Fatalf("GetRecurringDef: %v", err)
+			}
+			if saved.Rotation == nil || saved.Rotation.Next != 1 {
+				t.Errorf("rotation = %+v, want bob next", saved.Rotation)
+			}
+		})
+	}
+}
+
+func TestLeastRecentRotation(t *testing.T) {
+	s := newMemoryStore()
+	due := time.Now().Add(time.Hour).Truncate(time.Second)
+	def, todo := createRotatingDefinition(t, s, rotationLeastRecent, due)
+	if todo.AssignedTo[0] != "alice" {
+		t.Fatalf("first instance assigned to %v, want alice", todo.AssignedTo)
+	}
+
+	// Carol does alice's turn, so carol is credited and alice, who still
+	// hasn't done one, goes again
+	completeTodoAs(t, s, todo, "carol@example.com")
+	next, err := s.GetTodo(*todo.NextInstanceID)
+	if err != nil {
+		t.Fatalf("GetTodo: %v", err)
+	}
+	if next.AssignedTo[0] != "alice" {
+		t.Errorf("next instance assigned to %v, want alice, who has never completed one", next.AssignedTo)
+	}
+
+	saved, err := s.GetRecurringDef(def.ID)
+	if err != nil {
+		t.Fatalf("GetRecurringDef: %v", err)
+	}
+	if _, ok := saved.Rotation.LastCompleted["carol"]; !ok || len(saved.Rotation.LastCompleted) != 1 {
+		t.Errorf("LastCompleted = %v, want carol credited", saved.Rotation.LastCompleted)
+	}
+
+	// Instances created ahead of time go to people without one pending
+	if _, err := materializeUpcoming(s, time.Now(), 4*24*time.Hour); err != nil {
+		t.Fatalf("materializeUpcoming: %v", err)
+	}
+	pending := make(map[string]int)
+	for _, todo := range instancesOf(t, s, def.ID) {
+		if !todo.Completed {
+			pending[todo.AssignedTo[0]]++
+		}
+	}
+	if pending["alice"] != 1 || pending["bob"] != 1 || pending["carol"] != 1 {
+		t.Errorf("pending instances per assignee = %v, want one each", pending)
+	}
+}
+
+func TestRotationMember(t *testing.T) {
+	assignedTo := []string{"Alice", "bob@example.com"}
+	tests := map[string]string{
+		"alice@example.com": "Alice",
+		"BOB@example.com":   "bob@example.com",
+		"carol@example.com": "",
+		"":                  "",
+	}
+	for who, want := range tests {
+		if got := rotationMember(assignedTo, who); got != want {
+			t.Errorf("rotationMember(%q) = %q, want %q", who, got, want)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/teambition/rrule-go"
+)
+
+// Recurrence patterns can be given as an RFC 5545 RRULE string (for example
+// "FREQ=MONTHLY;BYDAY=-1FR") instead of the simple frequency/interval/days
+// fields. The rule's DTSTART is always the definition's StartDate, so the
+// string itself must contain just the RRULE parts.
+
+// supportedRRuleParts are the RRULE parts accepted in a pattern. Sub-day
+// parts (BYHOUR etc.) are rejected because due dates are per occurrence day.
+var supportedRRuleParts = map[string]bool{
+	"FREQ":       true,
+	"INTERVAL":   true,
+	"BYDAY":      true,
+	"BYMONTHDAY": true,
+	"BYMONTH":    true,
+	"BYSETPOS":   true,
+	"COUNT":      true,
+	"UNTIL":      true,
+	"WKST":       true,
+}
+
+// rruleWeekdays maps DaysOfWeek names to their RRULE BYDAY codes
+var rruleWeekdays = map[string]string{
+	"Monday":    "MO",
+	"Tuesday":   "TU",
+	"Wednesday": "WE",
+	"Thursday":  "TH",
+	"Friday":    "FR",
+	"Saturday":  "SA",
+	"Sunday":    "SU",
+}
+
+// validateRRule checks that rule is a well-formed RRULE using only the
+// supported parts
+func validateRRule(rule string) error {
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	if strings.ContainsAny(rule, "\r\n") {
+		return fmt.Errorf("rrule must be a single RRULE line without DTSTART")
+	}
+
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(rule, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return fmt.Errorf("invalid rrule part %q", part)
+		}
+		name = strings.ToUpper(name)
+		if !supportedRRuleParts[name] {
+			return fmt.Errorf("unsupported rrule part %s", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("rrule part %s given more than once", name)
+		}
+		seen[name] = true
+	}
+	if !seen["FREQ"] {
+		return fmt.Errorf("rrule must include FREQ")
+	}
+	if seen["COUNT"] && seen["UNTIL"] {
+		return fmt.Errorf("rrule must not include both COUNT and UNTIL")
+	}
+
+	opt, err := rrule.StrToROption(strings.ToUpper(rule))
+	if err != nil {
+		return fmt.Errorf("invalid rrule: %w", err)
+	}
+	switch opt.Freq {
+	case rrule.YEARLY, rrule.MONTHLY, rrule.WEEKLY, rrule.DAILY:
+	default:
+		return fmt.Errorf("rrule FREQ must be DAILY, WEEKLY, MONTHLY or YEARLY")
+	}
+	if opt.Interval < 0 || opt.Count < 0 {
+		return fmt.Errorf("rrule INTERVAL and COUNT must be positive")
+	}
+
+	// NewRRule range-checks the BY* values
+	if _, err := rrule.NewRRule(*opt); err != nil {
+		return fmt.Errorf("invalid rrule: %w", err)
+	}
+	return nil
+}
+
+// newRRule builds the rule for pattern starting at startDate
+func newRRule(pattern RecurrencePattern, startDate time.Time) (*rrule.RRule, error) {
+	rule, err := pattern.toRRule(startDate)
+	if err != nil {
+		return nil, err
+	}
+
+	opt, err := rrule.StrToROptionInLocation(strings.ToUpper(rule), startDate.Location())
+	if err != nil {
+		return nil, fmt.Errorf("invalid rrule: %w", err)
+	}
+	opt.Dtstart = startDate
+	return rrule.NewRRule(*opt)
+}
+
+// toRRule returns the pattern as an RRULE string, translating the simple
+// fields when no rrule was given. startDate supplies the default day of the
+// month for monthly patterns.
+func (p RecurrencePattern) toRRule(startDate time.Time) (string, error) {
+	if p.RRule != "" {
+		return strings.TrimPrefix(strings.TrimSpace(p.RRule), "RRULE:"), nil
+	}
+
+	parts := make([]string, 0, 3)
+	switch p.Frequency {
+	case "daily":
+		parts = append(parts, "FREQ=DAILY")
+	case "weekdays":
+		if max(p.Interval, 1) > 1 {
+			return "", fmt.Errorf("weekdays with an interval has no RRULE equivalent")
+		}
+		return "FREQ=WEEKLY;INTERVAL=1;BYDAY=MO,TU,WE,TH,FR", nil
+	case "weekly":
+		parts = append(parts, "FREQ=WEEKLY")
+	case "monthly":
+		parts = append(parts, "FREQ=MONTHLY")
+	case "yearly":
+		parts = append(parts, "FREQ=YEARLY")
+	default:
+		return "", fmt.Errorf("frequency %q has no RRULE equivalent", p.Frequency)
+	}
+
+	parts = append(parts, fmt.Sprintf("INTERVAL=%d", max(p.Interval, 1)))
+
+	if p.Frequency == "weekly" && len(p.DaysOfWeek) > 0 {
+		days := make([]string, 0, len(p.DaysOfWeek))
+		for _, day := range p.DaysOfWeek {
+			code, ok := rruleWeekdays[day]
+			if !ok {
+				return "", fmt.Errorf("invalid day of week: %s", day)
+			}
+			days = append(days, code)
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+
+	if p.Frequency == "yearly" {
+		// Pin the start date's month so BYMONTHDAY below picks its day
+		parts = append(parts, fmt.Sprintf("BYMONTH=%d", int(startDate.Month())))
+	} else if len(p.ActiveMonths) > 0 {
+		parts = append(parts, "BYMONTH="+joinInts(slices.Compact(slices.Sorted(slices.Values(p.ActiveMonths)))))
+	}
+
+	if p.Frequency == "monthly" || p.Frequency == "yearly" {
+		switch day := p.DayOfMonth; {
+		case p.WeekOfMonth != 0:
+			code, ok := rruleWeekdays[p.DayOfWeek]
+			if !ok {
+				return "", fmt.Errorf("invalid day of week: %s", p.DayOfWeek)
+			}
+			parts = append(parts, fmt.Sprintf("BYDAY=%d%s", p.WeekOfMonth, code))
+		case day < 0:
+			parts = append(parts, "BYMONTHDAY=-1")
+		default:
+			if day == 0 {
+				day = startDate.Day()
+			}
+			if day <= 28 {
+				parts = append(parts, fmt.Sprintf("BYMONTHDAY=%d", day))
+				break
+			}
+			// RRULE skips months that are too short for BYMONTHDAY, so clamp
+			// by taking the last of the candidate days each month has
+			candidates := make([]string, 0, day-27)
+			for d := 28; d <= day; d++ {
+				candidates = append(candidates, fmt.Sprint(d))
+			}
+			parts = append(parts, "BYMONTHDAY="+strings.Join(candidates, ","), "BYSETPOS=-1")
+		}
+	}
+
+	return strings.Join(parts, ";"), nil
+}
+
+// joinInts formats values as a comma-separated RRULE list
+func joinInts(values []int) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = fmt.Sprint(v)
+	}
+	return strings.Join(s, ",")
+}
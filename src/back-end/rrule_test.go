@@ -0,0 +1,195 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateRRule(t *testing.T) {
+	valid := []string{
+		"FREQ=DAILY",
+		"RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH;WKST=SU",
+		"FREQ=MONTHLY;BYDAY=-1FR",
+		"FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1",
+		"FREQ=MONTHLY;BYMONTHDAY=15;COUNT=6",
+		"FREQ=YEARLY;BYMONTH=3,9;BYMONTHDAY=1;UNTIL=20301231T000000Z",
+		"freq=daily;interval=3",
+	}
+	for _, rule := range valid {
+		if err := validateRRule(rule); err != nil {
+			t.Errorf("validateRRule(%q) = %v, want nil", rule, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;BYHOUR=9",
+		"FREQ=DAILY;COUNT=3;UNTIL=20301231",
+		"FREQ=DAILY;FREQ=WEEKLY",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=DAILY;INTERVAL=",
+		"DTSTART:20260101T000000Z\nRRULE:FREQ=DAILY",
+	}
+	for _, rule := range invalid {
+		if err := validateRRule(rule); err == nil {
+			t.Errorf("validateRRule(%q) = nil, want an error", rule)
+		}
+	}
+}
+
+func TestCalculateNextDueDateWithRRule(t *testing.T) {
+	// Thursday 1 January 2026
+	start := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		rule  string
+		after time.Time
+		want  time.Time
+	}{
+		{
+			name:  "last Friday of the month",
+			rule:  "FREQ=MONTHLY;BYDAY=-1FR",
+			after: start,
+			want:  time.Date(2026, 1, 30, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "second Tuesday of the month",
+			rule:  "FREQ=MONTHLY;BYDAY=2TU",
+			after: time.Date(2026, 1, 14, 0, 0, 0, 0, time.UTC),
+			want:  time.Date(2026, 2, 10, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "last weekday of the month",
+			rule:  "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1",
+			after: time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC),
+			want:  time.Date(2026, 5, 29, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			// The start week runs Monday 29 December to Sunday 4 January, so
+			// Monday 5 January is in an off week
+			name:  "every other week on Monday",
+			rule:  "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO",
+			after: start,
+			want:  time.Date(2026, 1, 12, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "every other week on Monday skips the off week",
+			rule:  "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO",
+			after: time.Date(2026, 1, 12, 9, 0, 0, 0, time.UTC),
+			want:  time.Date(2026, 1, 26, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			// With weeks starting on Sunday, 4 January starts the second week
+			name:  "every other week on Sunday with WKST",
+			rule:  "FREQ=WEEKLY;INTERVAL=2;BYDAY=SU;WKST=SU",
+			after: start,
+			want:  time.Date(2026, 1, 11, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "yearly in selected months",
+			rule:  "FREQ=YEARLY;BYMONTH=3,9;BYMONTHDAY=1",
+			after: time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC),
+			want:  time.Date(2026, 9, 1, 9, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := calculateNextDueDate(start, RecurrencePattern{RRule: tt.rule}, tt.after)
+			if !ok {
+				t.Fatalf("calculateNextDueDate reported no further occurrences")
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("calculateNextDueDate = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalculateNextDueDateRRuleEnds(t *testing.T) {
+	start := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	count := RecurrencePattern{RRule: "FREQ=DAILY;COUNT=3"}
+	if got, ok := calculateNextDueDate(start, count, start.AddDate(0, 0, 1)); !ok || !got.Equal(start.AddDate(0, 0, 2)) {
+		t.Errorf("last occurrence of COUNT=3 = %v, %v; want %v", got, ok, start.AddDate(0, 0, 2))
+	}
+	if _, ok := calculateNextDueDate(start, count, start.AddDate(0, 0, 2)); ok {
+		t.Error("expected no occurrences after COUNT is reached")
+	}
+
+	until := RecurrencePattern{RRule: "FREQ=WEEKLY;UNTIL=20260120T000000Z"}
+	if _, ok := calculateNextDueDate(start, until, time.Date(2026, 1, 15, 9, 0, 0, 0, time.UTC)); ok {
+		t.Error("expected no occurrences after UNTIL")
+	}
+}
+
+func TestSimplePatternsTranslateToRRule(t *testing.T) {
+	start := time.Date(2026, 1, 10, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		pattern RecurrencePattern
+		want    string
+	}{
+		{RecurrencePattern{Frequency: "daily", Interval: 1}, "FREQ=DAILY;INTERVAL=1"},
+		{RecurrencePattern{Frequency: "weekdays", Interval: 1}, "FREQ=WEEKLY;INTERVAL=1;BYDAY=MO,TU,WE,TH,FR"},
+		{RecurrencePattern{Frequency: "weekly", Interval: 2, DaysOfWeek: []string{"Monday", "Friday"}}, "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR"},
+		{RecurrencePattern{Frequency: "monthly", Interval: 3}, "FREQ=MONTHLY;INTERVAL=3;BYMONTHDAY=10"},
+		{RecurrencePattern{Frequency: "monthly", Interval: 1, DayOfMonth: 30}, "FREQ=MONTHLY;INTERVAL=1;BYMONTHDAY=28,29,30;BYSETPOS=-1"},
+		{RecurrencePattern{Frequency: "monthly", Interval: 1, DayOfMonth: -1}, "FREQ=MONTHLY;INTERVAL=1;BYMONTHDAY=-1"},
+		{RecurrencePattern{Frequency: "monthly", Interval: 1, WeekOfMonth: 2, DayOfWeek: "Tuesday"}, "FREQ=MONTHLY;INTERVAL=1;BYDAY=2TU"},
+		{RecurrencePattern{Frequency: "monthly", Interval: 1, WeekOfMonth: -1, DayOfWeek: "Friday"}, "FREQ=MONTHLY;INTERVAL=1;BYDAY=-1FR"},
+		{RecurrencePattern{Frequency: "yearly", Interval: 1}, "FREQ=YEARLY;INTERVAL=1;BYMONTH=1;BYMONTHDAY=10"},
+		{RecurrencePattern{Frequency: "weekly", Interval: 1, ActiveMonths: []int{10, 4, 5}}, "FREQ=WEEKLY;INTERVAL=1;BYMONTH=4,5,10"},
+		{RecurrencePattern{Frequency: "daily", Interval: 1, RRule: "RRULE:FREQ=YEARLY"}, "FREQ=YEARLY"},
+	}
+	for _, tt := range tests {
+		got, err := tt.pattern.toRRule(start)
+		if err != nil {
+			t.Errorf("toRRule(%+v): %v", tt.pattern, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("toRRule(%+v) = %q, want %q", tt.pattern, got, tt.want)
+		}
+		if err := validateRRule(got); err != nil {
+			t.Errorf("translated rule %q is invalid: %v", got, err)
+		}
+	}
+}
+
+func TestTranslatedRRuleMatchesSimplePattern(t *testing.T) {
+	start := time.Date(2026, 1, 31, 9, 0, 0, 0, time.UTC)
+	after := time.Date(2026, 6, 10, 0, 0, 0, 0, time.UTC)
+
+	for _, pattern := range []RecurrencePattern{
+		{Frequency: "daily", Interval: 3},
+		{Frequency: "weekly", Interval: 1},
+		{Frequency: "monthly", Interval: 1},
+		{Frequency: "monthly", Interval: 2, DayOfMonth: 30},
+		{Frequency: "monthly", Interval: 1, DayOfMonth: -1},
+		{Frequency: "monthly", Interval: 1, WeekOfMonth: 5, DayOfWeek: "Friday"},
+	} {
+		rule, err := pattern.toRRule(start)
+		if err != nil {
+			t.Fatalf("toRRule: %v", err)
+		}
+		simple, _ := calculateNextDueDate(start, pattern, after)
+		viaRRule, _ := calculateNextDueDate(start, RecurrencePattern{RRule: rule}, after)
+		if !simple.Equal(viaRRule) {
+			t.Errorf("%s: simple pattern gives %v but %q gives %v", pattern.Frequency, simple, rule, viaRRule)
+		}
+	}
+}
+
+func TestValidateRecurrencePatternAcceptsRRule(t *testing.T) {
+	if err := validateRecurrencePattern(RecurrencePattern{RRule: "FREQ=MONTHLY;BYDAY=1MO"}); err != nil {
+		t.Errorf("validateRecurrencePattern with rrule: %v", err)
+	}
+	if err := validateRecurrencePattern(RecurrencePattern{RRule: "FREQ=SECONDLY"}); err == nil {
+		t.Error("expected an invalid rrule to be rejected")
+	}
+}
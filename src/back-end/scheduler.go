@@ -0,0 +1,312 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The recurrence scheduler periodically walks every recurring definition and
+// makes sure an instance exists for each occurrence due within the horizon,
+// so upcoming chores appear on the board without anyone completing the
+// previous one first. Each definition is materialized in its own store
+// Update, so the store lock is only held briefly and a failure for one
+// definition does not block the others.
+
+const (
+	defaultSchedulerInterval = time.Hour
+	defaultSchedulerHorizon  = 7 * 24 * time.Hour
+
+	// maxInstancesPerDefinition bounds the work done for one definition in a
+	// single run, e.g. a daily pattern with a very long horizon
+	maxInstancesPerDefinition = 100
+)
+
+// SchedulerStatus describes the scheduler's configuration and its last run
+type SchedulerStatus struct {
+	Enabled          bool       `json:"enabled"`
+	Interval         string     `json:"interval"`
+	Horizon          string     `json:"horizon"`
+	Running          bool       `json:"running"`
+	Runs             int        `json:"runs"`
+	LastRunStartedAt *time.Time `json:"lastRunStartedAt,omitempty"`
+	LastRunDuration  string     `json:"lastRunDuration,omitempty"`
+	LastRunCreated   int        `json:"lastRunCreated"`
+	LastRunError     string     `json:"lastRunError,omitempty"`
+	NextRunAt        *time.Time `json:"nextRunAt,omitempty"`
+}
+
+// recurrenceScheduler runs materializeUpcoming on a ticker until stopped
+type recurrenceScheduler struct {
+	store    Store
+	interval time.Duration
+	horizon  time.Duration
+
+	mu     sync.Mutex
+	status SchedulerStatus
+
+	trigger chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+var scheduler *recurrenceScheduler
+
+// newRecurrenceScheduler creates a scheduler; an interval of zero disables it
+func newRecurrenceScheduler(s Store, interval, horizon time.Duration) *recurrenceScheduler {
+	return &recurrenceScheduler{
+		store:    s,
+		interval: interval,
+		horizon:  horizon,
+		status: SchedulerStatus{
+			Enabled:  interval > 0,
+			Interval: interval.String(),
+			Horizon:  horizon.String(),
+		},
+		trigger: make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// schedulerConfigFromEnv reads RECURRENCE_SCHEDULER_INTERVAL (a Go duration,
+// "0" to disable) and RECURRENCE_HORIZON_DAYS
+func schedulerConfigFromEnv() (interval, horizon time.Duration) {
+	interval = defaultSchedulerInterval
+	if value := getEnv("RECURRENCE_SCHEDULER_INTERVAL", ""); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed < 0 {
+			log.Printf("Invalid RECURRENCE_SCHEDULER_INTERVAL %q, using %s", value, defaultSchedulerInterval)
+		} else {
+			interval = parsed
+		}
+	}
+
+	horizon = defaultSchedulerHorizon
+	if value := getEnv("RECURRENCE_HORIZON_DAYS", ""); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 1 {
+			log.Printf("Invalid RECURRENCE_HORIZON_DAYS %q, using %d", value, int(defaultSchedulerHorizon/(24*time.Hour)))
+		} else {
+			horizon = time.Duration(days) * 24 * time.Hour
+		}
+	}
+
+	return interval, horizon
+}
+
+// Start runs the scheduler in a background goroutine. It runs once
+// immediately and then every interval until Stop is called.
+func (sc *recurrenceScheduler) Start() {
+	if sc.interval <= 0 {
+		log.Println("Recurrence scheduler disabled")
+		close(sc.done)
+		return
+	}
+
+	log.Printf("Recurrence scheduler running every %s with a %s horizon", sc.interval, sc.horizon)
+	go sc.loop()
+}
+
+// Stop signals the scheduler to exit and waits for any in-progress run to
+// finish
+func (sc *recurrenceScheduler) Stop() {
+	select {
+	case <-sc.stop:
+	default:
+		close(sc.stop)
+	}
+	<-sc.done
+}
+
+// Trigger requests a run as soon as possible, e.g. after a definition is
+// created or changed. It never blocks.
+func (sc *recurrenceScheduler) Trigger() {
+	if sc == nil || sc.interval <= 0 {
+		return
+	}
+	select {
+	case sc.trigger <- struct{}{}:
+	default:
+		// A run is already pending
+	}
+}
+
+// RunNow runs the scheduler once, even if it is disabled, and returns how
+// many instances were created
+func (sc *recurrenceScheduler) RunNow() (int, error) {
+	return sc.runOnce()
+}
+
+// Status returns a copy of the scheduler's current status
+func (sc *recurrenceScheduler) Status() SchedulerStatus {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.status
+}
+
+func (sc *recurrenceScheduler) loop() {
+	defer close(sc.done)
+
+	ticker := time.NewTicker(sc.interval)
+	defer ticker.Stop()
+
+	sc.runOnce()
+	for {
+		select {
+		case <-sc.stop:
+			return
+		case <-ticker.C:
+			sc.runOnce()
+		case <-sc.trigger:
+			sc.runOnce()
+		}
+	}
+}
+
+// runOnce materializes upcoming instances and records the outcome. Runs are
+// timed by the real clock but schedule by clock.
+func (sc *recurrenceScheduler) runOnce() (int, error) {
+	started := time.Now()
+	sc.mu.Lock()
+	sc.status.Running = true
+	sc.status.LastRunStartedAt = &started
+	sc.mu.Unlock()
+
+	created, err := materializeUpcoming(sc.store, clock.Now(), sc.horizon)
+	if err != nil {
+		log.Printf("Recurrence scheduler run failed: %v", err)
+	} else if created > 0 {
+		log.Printf("Recurrence scheduler created %d instance(s)", created)
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.status.Running = false
+	sc.status.Runs++
+	sc.status.LastRunDuration = time.Since(started).String()
+	sc.status.LastRunCreated = created
+	sc.status.LastRunError = ""
+	if err != nil {
+		sc.status.LastRunError = err.Error()
+	}
+	if sc.interval > 0 {
+		next := started.Add(sc.interval)
+		sc.status.NextRunAt = &next
+	}
+	return created, err
+}
+
+// materializeUpcoming ensures every recurring definition has an instance for
+// each occurrence due up to now+horizon and returns how many were created.
+// It keeps going after a failing definition and returns the first error.
+func materializeUpcoming(s Store, now time.Time, horizon time.Duration) (int, error) {
+	defs, err := s.ListRecurringDefs()
+	if err != nil {
+		return 0, err
+	}
+
+	until := now.Add(horizon)
+	created := 0
+	var firstErr error
+	for _, def := range defs {
+		n := 0
+		err := s.Update(func(tx Store) error {
+			var err error
+			n, err = materializeDefinition(tx, def.ID, now, until)
+			return err
+		})
+		if err != nil {
+			log.Printf("Failed to materialize instances for recurring definition %d: %v", def.ID, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		created += n
+	}
+	return created, firstErr
+}
+
+// materializeDefinition creates the missing instances of one definition due
+// after the later of now and its latest existing instance, up to until.
+// Starting after the latest instance means occurrences the user has deleted
+// are not recreated.
+func materializeDefinition(tx Store, defID int, now, until time.Time) (int, error) {
+	if err := tx.LockRecurringDef(defID); err != nil {
+		return 0, err
+	}
+	def, err := tx.GetRecurringDef(defID)
+	if errors.Is(err, ErrNotFound) {
+		// Deleted since the definitions were listed
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if def.Paused || def.Pattern.Mode == recurrenceModeAfterCompletion {
+		// Paused definitions generate nothing, and the next instance of an
+		// afterCompletion definition is only known once the current one is
+		// completed
+		return 0, nil
+	}
+
+	todos, err := tx.ListTodos()
+	if err != nil {
+		return 0, err
+	}
+
+	// Occurrences missed since the latest instance are only filled in when
+	// catching up. Instances after until, such as an occurrence edited ahead
+	// of time, are left out so the occurrences before them are still created.
+	scheduled := instancesDueBy(todos, until)
+	from := latestInstanceDueDate(scheduled, def.ID, now)
+	if latest := latestInstanceDueDate(scheduled, def.ID, time.Time{}); def.MissedPolicy == missedPolicyCatchUp && !latest.IsZero() {
+		from = latest
+	}
+
+	end := def.findOccurrenceLimit()
+	position := len(todos)
+	created := 0
+	for created < maxInstancesPerDefinition {
+		dueDate, ok := def.nextDueDateWithin(from, end)
+		if !ok || dueDate.After(until) || !dueDate.After(from) {
+			break
+		}
+		if hasInstanceFor(todos, def.ID, dueDate) {
+			from = dueDate
+			continue
+		}
+		if _, err := createRecurringInstance(tx, def, dueDate, position); err != nil {
+			return created, err
+		}
+		position++
+		created++
+		from = dueDate
+	}
+	if err := applyMissedPolicy(tx, def, now); err != nil {
+		return created, err
+	}
+	return created, refreshFinished(tx, def, latestInstanceDueDate(todos, def.ID, from))
+}
+
+// instancesDueBy returns the todos that are not due after until
+func instancesDueBy(todos []*TodoItem, until time.Time) []*TodoItem {
+	var due []*TodoItem
+	for _, todo := range todos {
+		if todo.DueDate == nil || !todo.DueDate.After(until) {
+			due = append(due, todo)
+		}
+	}
+	return due
+}
+
+// getSchedulerStatus returns the recurrence scheduler's last run status
+func getSchedulerStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(scheduler.Status())
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMaterializeUpcomingFillsHorizon(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+			def, _ := createDailyDefinition(t, s, now.Add(-time.Hour))
+
+			created, err := materializeUpcoming(s, now, 7*24*time.Hour)
+			if err != nil {
+				t.Fatalf("materializeUpcoming: %v", err)
+			}
+
+			// The existing instance is overdue, so the 7 days after now each
+			// need one
+			if created != 7 {
+				t.Errorf("created %d instances, want 7", created)
+			}
+			instances := instancesOf(t, s, def.ID)
+			if len(instances) != 8 {
+				t.Fatalf("got %d instances, want 8", len(instances))
+			}
+			last := instances[len(instances)-1]
+			if want := now.Add(-time.Hour).AddDate(0, 0, 7); !last.DueDate.Equal(want) {
+				t.Errorf("last instance due %v, want %v", last.DueDate, want)
+			}
+		})
+	}
+}
+
+func TestMaterializeUpcomingIsIdempotent(t *testing.T) {
+	s := newMemoryStore()
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	def, _ := createDailyDefinition(t, s, now.Add(time.Hour))
+
+	if _, err := materializeUpcoming(s, now, 3*24*time.Hour); err != nil {
+		t.Fatalf("first materializeUpcoming: %v", err)
+	}
+	created, err := materializeUpcoming(s, now, 3*24*time.Hour)
+	if err != nil {
+		t.Fatalf("second materializeUpcoming: %v", err)
+	}
+	if created != 0 {
+		t.Errorf("second run created %d instances, want 0", created)
+	}
+	if got := len(instancesOf(t, s, def.ID)); got != 3 {
+		t.Errorf("got %d instances, want 3", got)
+	}
+}
+
+func TestConcurrentMaterializeUpcomingCreatesEachInstanceOnce(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+			def, _ := createDailyDefinition(t, s, now.Add(time.Hour))
+
+			// As when every replica runs a scheduler
+			var wg sync.WaitGroup
+			errs := make(chan error, 4)
+			for range 4 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if _, err := materializeUpcoming(s, now, 5*24*time.Hour); err != nil {
+						errs <- err
+					}
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				t.Errorf("materializeUpcoming: %v", err)
+			}
+
+			instances := instancesOf(t, s, def.ID)
+			seen := make(map[time.Time]bool)
+			for _, instance := range instances {
+				if seen[instance.DueDate.UTC()] {
+					t.Errorf("more than one instance due %v", instance.DueDate)
+				}
+				seen[instance.DueDate.UTC()] = true
+			}
+			if len(instances) != 5 {
+				t.Errorf("got %d instances, want 5", len(instances))
+			}
+		})
+	}
+}
+
+func TestMaterializeUpcomingDoesNotRecreateDeletedInstances(t *testing.T) {
+	s := newMemoryStore()
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	def, _ := createDailyDefinition(t, s, now.Add(time.Hour))
+
+	if _, err := materializeUpcoming(s, now, 3*24*time.Hour); err != nil {
+		t.Fatalf("materializeUpcoming: %v", err)
+	}
+	instances := instancesOf(t, s, def.ID)
+	if err := s.DeleteTodo(instances[1].ID); err != nil {
+		t.Fatalf("DeleteTodo: %v", err)
+	}
+
+	created, err := materializeUpcoming(s, now, 3*24*time.Hour)
+	if err != nil {
+		t.Fatalf("materializeUpcoming after delete: %v", err)
+	}
+	if created != 0 {
+		t.Errorf("created %d instances after a delete, want 0", created)
+	}
+}
+
+func TestCompletingLinksMaterializedInstance(t *testing.T) {
+	s := newMemoryStore()
+	due := time.Now().Add(time.Hour).Truncate(time.Second)
+	def, todo := createDailyDefinition(t, s, due)
+
+	if _, err := materializeUpcoming(s, time.Now(), 3*24*time.Hour); err != nil {
+		t.Fatalf("materializeUpcoming: %v", err)
+	}
+	before := len(instancesOf(t, s, def.ID))
+
+	completeTodo(t, s, todo)
+
+	if after := len(instancesOf(t, s, def.ID)); after != before {
+		t.Errorf("completing created a duplicate: %d instances before, %d after", before, after)
+	}
+	if todo.NextInstanceID == nil {
+		t.Error("completed instance was not linked to the materialized successor")
+	}
+}
+
+func TestSchedulerRunsAndStops(t *testing.T) {
+	s := newMemoryStore()
+	createDailyDefinition(t, s, time.Now().Add(time.Hour))
+
+	sc := newRecurrenceScheduler(s, time.Hour, 2*24*time.Hour)
+	sc.Start()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for sc.Status().Runs == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("scheduler did not complete its first run")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	sc.Stop()
+
+	status := sc.Status()
+	if !status.Enabled || status.Running || status.LastRunStartedAt == nil || status.LastRunError != "" {
+		t.Errorf("unexpected status after first run: %+v", status)
+	}
+	if status.LastRunCreated != 1 {
+		t.Errorf("first run created %d instances, want 1", status.LastRunCreated)
+	}
+
+	// Stopping twice and triggering after stop must not block or panic
+	sc.Stop()
+	sc.Trigger()
+}
+
+func TestDisabledSchedulerStops(t *testing.T) {
+	sc := newRecurrenceScheduler(newMemoryStore(), 0, defaultSchedulerHorizon)
+	sc.Start()
+	sc.Stop()
+
+	if status := sc.Status(); status.Enabled || status.Runs != 0 {
+		t.Errorf("disabled scheduler status = %+v", status)
+	}
+}
+
+func TestMaterializeUpcomingStopsAtEndDate(t *testing.T) {
+	s := newMemoryStore()
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	def, _ := createDailyDefinition(t, s, now.Add(time.Hour))
+	endDate := now.AddDate(0, 0, 3)
+	def.EndDate = &endDate
+	if err := s.UpdateRecurringDef(def); err != nil {
+		t.Fatalf("UpdateRecurringDef: %v", err)
+	}
+
+	if _, err := materializeUpcoming(s, now, 7*24*time.Hour); err != nil {
+		t.Fatalf("materializeUpcoming: %v", err)
+	}
+
+	// Due 13:00 on the 2nd to the 4th; the 5th is after the end date
+	if got := len(instancesOf(t, s, def.ID)); got != 3 {
+		t.Errorf("got %d instances, want 3", got)
+	}
+	got, err := s.GetRecurringDef(def.ID)
+	if err != nil {
+		t.Fatalf("GetRecurringDef: %v", err)
+	}
+	if !got.Finished {
+		t.Error("definition not marked finished after its last occurrence")
+	}
+}
+
+func TestMaterializeUpcomingSkipsAfterCompletionDefinitions(t *testing.T) {
+	s := newMemoryStore()
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	def, _ := createDailyDefinition(t, s, now.Add(time.Hour))
+	def.Pattern.Mode = recurrenceModeAfterCompletion
+	if err := s.UpdateRecurringDef(def); err != nil {
+		t.Fatalf("UpdateRecurringDef: %v", err)
+	}
+
+	created, err := materializeUpcoming(s, now, 7*24*time.Hour)
+	if err != nil {
+		t.Fatalf("materializeUpcoming: %v", err)
+	}
+	if created != 0 {
+		t.Errorf("created %d instances ahead of completion, want 0", created)
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"time"
+)
+
+// Edits to a recurring definition apply to one of three scopes, as in
+// calendar apps: the whole series, a single occurrence, or an occurrence and
+// every one after it. The last splits the series in two, so the instances
+// already done keep pointing at the definition they were created from.
+
+const (
+	editScopeAll       = "all"       // The whole series (the default)
+	editScopeThis      = "this"      // Only the occurrence on the given date
+	editScopeFollowing = "following" // The occurrence on the given date and those after it
+)
+
+// applyDefinitionUpdates copies the editable fields of updates onto def.
+// Exception dates are kept unless the update replaces them.
+func applyDefinitionUpdates(def, updates *RecurringItemDefinition) {
+	def.Title = updates.Title
+	def.Description = updates.Description
+	def.AssignedTo = updates.AssignedTo
+	applyRotationUpdate(def, updates.Rotation)
+	exceptionDates := def.Pattern.ExceptionDates
+	def.Pattern = updates.Pattern
+	if def.Pattern.ExceptionDates == nil {
+		def.Pattern.ExceptionDates = exceptionDates
+	}
+	def.EndDate = updates.EndDate
+	def.MaxOccurrences = updates.MaxOccurrences
+	def.MissedPolicy = updates.MissedPolicy
+	def.TimeZone = updates.TimeZone
+	def.AllDay = updates.AllDay
+	def.HolidayRegion = updates.HolidayRegion
+	def.HolidayRule = updates.HolidayRule
+}
+
+// editOccurrence applies the title, description and assignees of updates to
+// def's instance for the occurrence on the given day, creating that instance
+// first if the scheduler has not yet done so
+func editOccurrence(tx Store, def *RecurringItemDefinition, updates *RecurringItemDefinition, date, now time.Time) (*TodoItem, error) {
+	if err := tx.LockRecurringDef(def.ID); err != nil {
+		return nil, err
+	}
+	todos, err := tx.ListTodos()
+	if err != nil {
+		return nil, err
+	}
+
+	instance := pendingInstanceOn(todos, def, date)
+	if instance == nil {
+		// Only a future occurrence of a running schedule can be created
+		// ahead of the scheduler
+		if def.Paused || def.Pattern.Mode == recurrenceModeAfterCompletion {
+			return nil, errNotAnOccurrence
+		}
+		loc := def.location()
+		next, ok := def.nextDueDate(startOfDay(date, loc).Add(-time.Nanosecond))
+		if !ok || daysBetween(next.In(loc), date.In(loc)) != 0 || !next.After(now) || hasInstanceFor(todos, def.ID, next) {
+			return nil, errNotAnOccurrence
+		}
+		if instance, err = createRecurringInstance(tx, def, next, len(todos)); err != nil {
+			return nil, err
+		}
+	}
+
+	instance.Title = updates.Title
+	instance.Description = updates.Description
+	instance.AssignedTo = updates.AssignedTo
+	return instance, tx.UpdateTodo(instance)
+}
+
+// splitRecurringDef ends def before the given day and starts a new definition
+// with updates applied from that day on, returning the new definition.
+// Completed instances stay with def as its history, while pending instances
+// from that day on are replaced by the new series. Splitting on or before the
+// series' first day updates the whole series instead. A count limit applies
+// to both series together.
+func splitRecurringDef(tx Store, def *RecurringItemDefinition, updates *RecurringItemDefinition, date, now time.Time) (*RecurringItemDefinition, error) {
+	loc := def.location()
+	dayStart := startOfDay(date, loc)
+	if !dayStart.After(def.StartDate) {
+		applyDefinitionUpdates(def, updates)
+		return def, updateRecurringDefInstances(tx, def, now)
+	}
+
+	// A count limit covers the whole series, so the new series only gets the
+	// occurrences the original has left, and there is nothing to split off
+	// once they have all been used
+	used := 0
+	if updates.MaxOccurrences > 0 {
+		used = def.countOccurrencesBefore(dayStart, updates.MaxOccurrences)
+		if used == updates.MaxOccurrences {
+			return def, nil
+		}
+	}
+
+	// The new series keeps the original time of day
+	original := def.StartDate.In(loc)
+	start := time.Date(dayStart.Year(), dayStart.Month(), dayStart.Day(),
+		original.Hour(), original.Minute(), original.Second(), original.Nanosecond(), loc)
+	split := &RecurringItemDefinition{
+		StartDate: start,
+		CreatedAt: now,
+		Paused:    def.Paused,
+		PausedAt:  def.PausedAt,
+		Rotation:  def.Rotation.clone(), // Carry on the turn order
+	}
+	applyDefinitionUpdates(split, updates)
+	split.MaxOccurrences -= used
+	if updates.Pattern.ExceptionDates == nil {
+		split.Pattern.ExceptionDates = nil
+		for _, exception := range def.Pattern.ExceptionDates {
+			if !exception.Before(dayStart) {
+				split.Pattern.ExceptionDates = append(split.Pattern.ExceptionDates, exception)
+			}
+		}
+	}
+	if err := tx.CreateRecurringDef(split); err != nil {
+		return nil, err
+	}
+
+	// The new series starts with the first occurrence from the split, or
+	// after now, but still includes an overdue occurrence being replaced
+	after := now
+	if dayStart.Add(-time.Nanosecond).After(after) {
+		after = dayStart.Add(-time.Nanosecond)
+	}
+	todos, err := tx.ListTodos()
+	if err != nil {
+		return nil, err
+	}
+	for _, todo := range todos {
+		if todo.RecurrenceID == nil || *todo.RecurrenceID != def.ID || todo.Completed || todo.DueDate == nil {
+			continue
+		}
+		scheduled := *todo.DueDate
+		if todo.OriginalDueDate != nil {
+			scheduled = *todo.OriginalDueDate
+		}
+		if scheduled.Before(dayStart) {
+			continue
+		}
+		if err := tx.DeleteTodo(todo.ID); err != nil {
+			return nil, err
+		}
+		if scheduled.Add(-time.Nanosecond).Before(after) {
+			after = scheduled.Add(-time.Nanosecond)
+		}
+	}
+
+	// End the original series the day before
+	end := dayStart.Add(-time.Nanosecond)
+	if def.EndDate == nil || def.EndDate.After(end) {
+		def.EndDate = &end
+	}
+	if err := tx.UpdateRecurringDef(def); err != nil {
+		return nil, err
+	}
+	if err := refreshFinished(tx, def, latestInstanceDueDate(todos, def.ID, now)); err != nil {
+		return nil, err
+	}
+
+	position := len(todos)
+	if split.Paused {
+		return split, nil
+	}
+	dueDate, ok := split.nextDueDate(after)
+	if !ok {
+		return split, refreshFinished(tx, split, after)
+	}
+	if _, err := createRecurringInstance(tx, split, dueDate, position); err != nil {
+		return nil, err
+	}
+	return split, refreshFinished(tx, split, dueDate)
+}
+
+// updateRecurringDefInstances saves an edited definition and carries the
+// edit over to its uncompleted instances
+func updateRecurringDefInstances(tx Store, def *RecurringItemDefinition, now time.Time) error {
+	if err := tx.UpdateRecurringDef(def); err != nil {
+		return err
+	}
+
+	todos, err := tx.ListTodos()
+	if err != nil {
+		return err
+	}
+
+	// Changing the end conditions can finish the series or extend it
+	if err := refreshFinished(tx, def, latestInstanceDueDate(todos, def.ID, now)); err != nil {
+		return err
+	}
+	for _, todo := range todos {
+		if todo.RecurrenceID != nil && *todo.RecurrenceID == def.ID && !todo.Completed {
+			todo.Title = def.Title
+			todo.Description = def.Description
+			if def.Rotation == nil {
+				// Rotating instances keep the assignee they were given
+				todo.AssignedTo = def.AssignedTo
+			}
+			if err := tx.UpdateTodo(todo); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSplitRecurringDefKeepsHistory(t *testing.T) {
+	s := newMemoryStore()
+	now := time.Now().Truncate(time.Second)
+	def, first := createDailyDefinition(t, s, now.Add(time.Hour))
+	completeTodo(t, s, first)
+	if _, err := materializeUpcoming(s, now, 5*24*time.Hour); err != nil {
+		t.Fatalf("materializeUpcoming: %v", err)
+	}
+
+	// From three days on, the chore becomes weekly
+	splitDay := now.Add(time.Hour).AddDate(0, 0, 3)
+	updates := &RecurringItemDefinition{
+		Title:   "Feed the cat (weekly)",
+		Pattern: RecurrencePattern{Frequency: "weekly", Interval: 1},
+	}
+	var split *RecurringItemDefinition
+	err := s.Update(func(tx Store) error {
+		var err error
+		split, err = splitRecurringDef(tx, def, updates, splitDay, now)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("splitRecurringDef: %v", err)
+	}
+	if split.ID == def.ID {
+		t.Fatal("split returned the original definition")
+	}
+
+	original, err := s.GetRecurringDef(def.ID)
+	if err != nil {
+		t.Fatalf("GetRecurringDef: %v", err)
+	}
+	if original.Title != "Feed the cat" || original.EndDate == nil || !original.EndDate.Before(startOfDay(splitDay, time.Local)) {
+		t.Errorf("original definition = %+v, want unchanged and ending before the split", original)
+	}
+	for _, todo := range instancesOf(t, s, def.ID) {
+		if !todo.DueDate.Before(splitDay) {
+			t.Errorf("original series still has an instance due %v", todo.DueDate)
+		}
+	}
+	if got, err := s.GetTodo(first.ID); err != nil || !got.Completed || got.Title != "Feed the cat" {
+		t.Errorf("completed instance = %+v, %v, want it kept as history", got, err)
+	}
+
+	instances := instancesOf(t, s, split.ID)
+	if len(instances) != 1 {
+		t.Fatalf("got %d instances of the new series, want 1", len(instances))
+	}
+	if !instances[0].DueDate.Equal(splitDay) || instances[0].Title != updates.Title {
+		t.Errorf("new series instance = %+v, want %q due %v", instances[0], updates.Title, splitDay)
+	}
+}
+
+func TestSplitRecurringDefAtStartUpdatesSeries(t *testing.T) {
+	s := newMemoryStore()
+	now := time.Now().Truncate(time.Second)
+	def, todo := createDailyDefinition(t, s, now.Add(time.Hour))
+
+	updates := &RecurringItemDefinition{Title: "Feed the dog", Pattern: def.Pattern}
+	var got *RecurringItemDefinition
+	err := s.Update(func(tx Store) error {
+		var err error
+		got, err = splitRecurringDef(tx, def, updates, def.StartDate, now)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("splitRecurringDef: %v", err)
+	}
+	if got.ID != def.ID || got.Title != "Feed the dog" {
+		t.Errorf("got definition %+v, want the original updated", got)
+	}
+	if instance, err := s.GetTodo(todo.ID); err != nil || instance.Title != "Feed the dog" {
+		t.Errorf("pending instance = %+v, %v, want it retitled", instance, err)
+	}
+}
+
+func TestEditOccurrence(t *testing.T) {
+	s := newMemoryStore()
+	now := time.Now().Truncate(time.Second)
+	def, _ := createDailyDefinition(t, s, now.Add(time.Hour))
+
+	// The occurrence two days out has no instance yet
+	day := now.Add(time.Hour).AddDate(0, 0, 2)
+	updates := &RecurringItemDefinition{Title: "Feed the cat twice", AssignedTo: []string{"bob"}}
+	var instance *TodoItem
+	err := s.Update(func(tx Store) error {
+		var err error
+		instance, err = editOccurrence(tx, def, updates, day, now)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("editOccurrence: %v", err)
+	}
+	if !instance.DueDate.Equal(day) || instance.Title != updates.Title {
+		t.Errorf("edited instance = %+v, want %q due %v", instance, updates.Title, day)
+	}
+
+	for _, todo := range instancesOf(t, s, def.ID) {
+		if todo.ID != instance.ID && todo.Title != "Feed the cat" {
+			t.Errorf("instance due %v was edited too", todo.DueDate)
+		}
+	}
+	if got, err := s.GetRecurringDef(def.ID); err != nil || got.Title != "Feed the cat" {
+		t.Errorf("definition = %+v, %v, want it unchanged", got, err)
+	}
+
+	err = s.Update(func(tx Store) error {
+		_, err := editOccurrence(tx, def, updates, now.AddDate(0, 0, -3), now)
+		return err
+	})
+	if !errors.Is(err, errNotAnOccurrence) {
+		t.Errorf("editing a day with no pending instance: err = %v, want errNotAnOccurrence", err)
+	}
+}
+
+func TestEditOccurrenceCreatesOnlyItsInstance(t *testing.T) {
+	s := newMemoryStore()
+	now := time.Now().Truncate(time.Second)
+	def, _ := createDailyDefinition(t, s, now.Add(time.Hour))
+	def.Pattern = RecurrencePattern{Frequency: "weekly", Interval: 1}
+	if err := s.UpdateRecurringDef(def); err != nil {
+		t.Fatalf("UpdateRecurringDef: %v", err)
+	}
+	edit := func(day time.Time) error {
+		return s.Update(func(tx Store) error {
+			_, err := editOccurrence(tx, def, &RecurringItemDefinition{Title: "Feed the cat early"}, day, now)
+			return err
+		})
+	}
+
+	// Further out than the scheduler would ever create in one go
+	far := now.Add(time.Hour).AddDate(0, 0, 7*(maxInstancesPerDefinition+10))
+	if err := edit(far); err != nil {
+		t.Fatalf("editOccurrence: %v", err)
+	}
+	if got := len(instancesOf(t, s, def.ID)); got != 2 {
+		t.Errorf("got %d instances after editing a far occurrence, want 2", got)
+	}
+
+	// A day between occurrences creates nothing
+	if err := edit(far.AddDate(0, 0, 1)); !errors.Is(err, errNotAnOccurrence) {
+		t.Errorf("editing a day between occurrences: err = %v, want errNotAnOccurrence", err)
+	}
+	if got := len(instancesOf(t, s, def.ID)); got != 2 {
+		t.Errorf("got %d instances after editing a day between occurrences, want 2", got)
+	}
+
+	// The scheduler still creates the occurrences before the edited one
+	if _, err := materializeUpcoming(s, now, 15*24*time.Hour); err != nil {
+		t.Fatalf("materializeUpcoming: %v", err)
+	}
+	if got := len(instancesOf(t, s, def.ID)); got != 4 {
+		t.Errorf("got %d instances after scheduling two weeks ahead, want 4", got)
+	}
+}
+
+func TestSplitRecurringDefKeepsOccurrenceCount(t *testing.T) {
+	s := newMemoryStore()
+	now := time.Now().Truncate(time.Second)
+	due := now.Add(time.Hour)
+	def, _ := createDailyDefinition(t, s, due)
+	def.MaxOccurrences = 10
+	if err := s.UpdateRecurringDef(def); err != nil {
+		t.Fatalf("UpdateRecurringDef: %v", err)
+	}
+	split := func(day time.Time, maxOccurrences int) *RecurringItemDefinition {
+		t.Helper()
+		updates := &RecurringItemDefinition{Title: "Feed the dog", Pattern: def.Pattern, MaxOccurrences: maxOccurrences}
+		var got *RecurringItemDefinition
+		err := s.Update(func(tx Store) error {
+			var err error
+			got, err = splitRecurringDef(tx, def, updates, day, now)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("splitRecurringDef: %v", err)
+		}
+		return got
+	}
+
+	// Six of the ten occurrences come before the split, leaving four
+	following := split(due.AddDate(0, 0, 6), 10)
+	if following.ID == def.ID || following.MaxOccurrences != 4 {
+		t.Fatalf("split = %+v, want a new series with 4 occurrences", following)
+	}
+	if got := following.occurrencesBetween(now, time.Time{}, 20); len(got) != 4 {
+		t.Errorf("new series has %d occurrences, want 4", len(got))
+	}
+
+	// Once every occurrence is used there is no new series
+	def = following
+	if got := split(due.AddDate(0, 0, 12), following.MaxOccurrences); got.ID != following.ID {
+		t.Errorf("split after the last occurrence created definition %d, want none", got.ID)
+	}
+}
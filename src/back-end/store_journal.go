@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// The journal gives the in-memory store durability without an external
+// database. Every batch of mutations is appended to journal.log as a single
+// line of the form "<crc32 hex> <json>\n" and fsynced before the lock is
+// released. Once the journal holds snapshotEvery entries the full state is
+// written to snapshot.json (via a temporary file and rename) and the journal
+// is truncated. On startup the snapshot is loaded and the journal replayed on
+// top of it; a torn or corrupted tail, e.g. from a crash mid-write, is
+// truncated instead of failing startup.
+
+const (
+	journalFileName  = "journal.log"
+	snapshotFileName = "snapshot.json"
+
+	defaultSnapshotEvery = 1000
+)
+
+// journalOp is a single mutation recorded in the journal
+type journalOp struct {
+	Op             string                   `json:"op"` // "putTodo", "deleteTodo", "reorder", "putRecurringDef", "deleteRecurringDef", "putInstanceRecord"
+	ID             int                      `json:"id,omitempty"`
+	Todo           *TodoItem                `json:"todo,omitempty"`
+	RecurringDef   *RecurringItemDefinition `json:"recurringDef,omitempty"`
+	Order          []ReorderItem            `json:"order,omitempty"`
+	InstanceRecord *InstanceRecord          `json:"instanceRecord,omitempty"`
+}
+
+// journalEntry groups the mutations made under one lock acquisition so that
+// an Update is replayed all-or-nothing
+type journalEntry struct {
+	Ops []journalOp `json:"ops"`
+}
+
+// memorySnapshot is the compacted state written to snapshot.json
+type memorySnapshot struct {
+	NextTodoID      int                        `json:"nextTodoId"`
+	NextRecurringID int                        `json:"nextRecurringId"`
+	Todos           []*TodoItem                `json:"todos"`
+	RecurringDefs   []*RecurringItemDefinition `json:"recurringDefs"`
+	InstanceHistory []*InstanceRecord          `json:"instanceHistory,omitempty"`
+}
+
+// journal is the append-only log attached to a memoryStore
+type journal struct {
+	dir           string
+	file          journalFile
+	entries       int
+	snapshotEvery int
+	broken        error // Set when a failed append couldn't be removed, after which nothing more is appended
+}
+
+// journalFile is the part of *os.File the journal uses, so tests can make
+// writes fail
+type journalFile interface {
+	io.WriteSeeker
+	io.Closer
+	Sync() error
+	Truncate(size int64) error
+}
+
+// newJournaledMemoryStore restores a memoryStore from the snapshot and
+// journal in dir (creating them if needed) and journals further changes
+func newJournaledMemoryStore(dir string, snapshotEvery int) (*memoryStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create journal directory: %w", err)
+	}
+	if snapshotEvery < 1 {
+		snapshotEvery = defaultSnapshotEvery
+	}
+
+	s := newMemoryStore()
+	if err := s.loadSnapshot(filepath.Join(dir, snapshotFileName)); err != nil {
+		return nil, err
+	}
+
+	j := &journal{dir: dir, snapshotEvery: snapshotEvery}
+	if err := j.replay(s); err != nil {
+		return nil, err
+	}
+	s.journal = j
+
+	log.Printf("Restored %d todos and %d recurring definitions from %s (%d journal entries)",
+		len(s.todos), len(s.recurringDefs), dir, j.entries)
+	return s, nil
+}
+
+// loadSnapshot replaces the store's contents with the snapshot at path, if it
+// exists
+func (s *memoryStore) loadSnapshot(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	var snap memorySnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("failed to parse snapshot %s: %w", path, err)
+	}
+
+	for _, todo := range snap.Todos {
+		s.todos[todo.ID] = todo
+	}
+	for _, def := range snap.RecurringDefs {
+		s.recurringDefs[def.ID] = def
+	}
+	for _, rec := range snap.InstanceHistory {
+		s.history[rec.TodoID] = rec
+	}
+	s.nextTodoID = max(s.nextTodoID, snap.NextTodoID)
+	s.nextRecurringID = max(s.nextRecurringID, snap.NextRecurringID)
+	return nil
+}
+
+// replay applies every valid journal entry to s, truncates anything after
+// the last valid entry and leaves the journal open for appending
+func (j *journal) replay(s *memoryStore) error {
+	path := filepath.Join(j.dir, journalFileName)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+
+	reader := bufio.NewReader(file)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				log.Printf("Journal %s ends with an incomplete entry; discarding %d bytes", path, len(line))
+			}
+			break
+		}
+		if err != nil {
+			file.Close()
+			return fmt.Errorf("failed to read journal: %w", err)
+		}
+
+		entry, err := decodeJournalLine(line)
+		if err != nil {
+			log.Printf("Journal %s has a corrupt entry at offset %d (%v); discarding it and everything after it", path, offset, err)
+			break
+		}
+
+		s.apply(entry)
+		j.entries++
+		offset += int64(len(line))
+	}
+
+	// Drop any torn or corrupt tail so new entries follow the last good one
+	if err := file.Truncate(offset); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to truncate journal: %w", err)
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to seek journal: %w", err)
+	}
+
+	j.file = file
+	return nil
+}
+
+// append writes ops as a single durable journal entry. If that fails, the
+// journal is returned to how it was, so a partly written entry doesn't hide
+// the ones after it when the journal is replayed.
+func (j *journal) append(ops []journalOp) error {
+	if j.broken != nil {
+		return fmt.Errorf("journal is unusable after an earlier failure: %w", j.broken)
+	}
+	payload, err := json.Marshal(journalEntry{Ops: ops})
+	if err != nil {
+		return fmt.Errorf("failed to encode journal entry: %w", err)
+	}
+
+	line := make([]byte, 0, len(payload)+10)
+	line = fmt.Appendf(line, "%08x ", crc32.ChecksumIEEE(payload))
+	line = append(line, payload...)
+	line = append(line, '\n')
+
+	offset, err := j.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("failed to seek journal: %w", err)
+	}
+	if _, err := j.file.Write(line); err != nil {
+		return j.rewind(offset, fmt.Errorf("failed to write journal entry: %w", err))
+	}
+	if err := j.file.Sync(); err != nil {
+		return j.rewind(offset, fmt.Errorf("failed to sync journal: %w", err))
+	}
+
+	j.entries++
+	return nil
+}
+
+// rewind removes anything written to the journal after offset by an append
+// that failed with err, which it returns
+func (j *journal) rewind(offset int64, err error) error {
+	if truncErr := j.file.Truncate(offset); truncErr != nil {
+		j.broken = fmt.Errorf("failed to truncate journal: %w", truncErr)
+		return errors.Join(err, j.broken)
+	}
+	if _, seekErr := j.file.Seek(offset, io.SeekStart); seekErr != nil {
+		j.broken = fmt.Errorf("failed to seek journal: %w", seekErr)
+		return errors.Join(err, j.broken)
+	}
+	return err
+}
+
+// compact writes a snapshot of s and empties the journal. The caller must
+// hold s.mu.
+func (j *journal) compact(s *memoryStore) error {
+	snap := memorySnapshot{
+		NextTodoID:      s.nextTodoID,
+		NextRecurringID: s.nextRecurringID,
+	}
+	snap.Todos, _ = memoryTx{s}.ListTodos()
+	snap.RecurringDefs, _ = memoryTx{s}.ListRecurringDefs()
+	for _, rec := range s.history {
+		snap.InstanceHistory = append(snap.InstanceHistory, rec)
+	}
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	path := filepath.Join(j.dir, snapshotFileName)
+	if err := writeFileAtomic(path, data); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	// The snapshot now contains everything in the journal
+	if err := j.file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate journal: %w", err)
+	}
+	if _, err := j.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek journal: %w", err)
+	}
+	if err := j.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync journal: %w", err)
+	}
+
+	j.entries = 0
+	return nil
+}
+
+func (j *journal) close() error {
+	return j.file.Close()
+}
+
+// decodeJournalLine verifies the checksum of a journal line and decodes it
+func decodeJournalLine(line []byte) (journalEntry, error) {
+	var entry journalEntry
+
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	checksum, payload, ok := bytes.Cut(line, []byte(" "))
+	if !ok {
+		return entry, fmt.Errorf("missing checksum")
+	}
+
+	want, err := strconv.ParseUint(string(checksum), 16, 32)
+	if err != nil {
+		return entry, fmt.Errorf("invalid checksum: %w", err)
+	}
+	if got := crc32.ChecksumIEEE(payload); uint32(want) != got {
+		return entry, fmt.Errorf("checksum mismatch")
+	}
+
+	if err := json.Unmarshal(payload, &entry); err != nil {
+		return entry, fmt.Errorf("invalid entry: %w", err)
+	}
+	return entry, nil
+}
+
+// writeFileAtomic replaces path with data so that readers see either the old
+// or the new contents, never a partial write
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
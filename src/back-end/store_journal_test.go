@@ -0,0 +1,243 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJournaledStoreSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := newJournaledMemoryStore(dir, 100)
+	if err != nil {
+		t.Fatalf("newJournaledMemoryStore: %v", err)
+	}
+	first := &TodoItem{Title: "first", CreatedAt: time.Now().UTC()}
+	second := &TodoItem{Title: "second", Position: 1, CreatedAt: time.Now().UTC()}
+	if err := s.CreateTodo(first); err != nil {
+		t.Fatalf("CreateTodo: %v", err)
+	}
+	if err := s.CreateTodo(second); err != nil {
+		t.Fatalf("CreateTodo: %v", err)
+	}
+	if err := s.DeleteTodo(second.ID); err != nil {
+		t.Fatalf("DeleteTodo: %v", err)
+	}
+	s.Close()
+
+	s, err = newJournaledMemoryStore(dir, 100)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s.Close()
+
+	todos, _ := s.ListTodos()
+	if len(todos) != 1 || todos[0].Title != "first" {
+		t.Fatalf("after restart ListTodos = %+v, want only %q", todos, "first")
+	}
+
+	// IDs must not be reused, even for deleted items
+	third := &TodoItem{Title: "third", CreatedAt: time.Now().UTC()}
+	if err := s.CreateTodo(third); err != nil {
+		t.Fatalf("CreateTodo: %v", err)
+	}
+	if third.ID <= second.ID {
+		t.Errorf("new todo got ID %d, want > %d", third.ID, second.ID)
+	}
+}
+
+func TestJournaledStoreCompactsIntoSnapshot(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := newJournaledMemoryStore(dir, 3)
+	if err != nil {
+		t.Fatalf("newJournaledMemoryStore: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		if err := s.CreateTodo(&TodoItem{Title: "item", Position: i, CreatedAt: time.Now().UTC()}); err != nil {
+			t.Fatalf("CreateTodo: %v", err)
+		}
+	}
+	s.Close()
+
+	if _, err := os.Stat(filepath.Join(dir, snapshotFileName)); err != nil {
+		t.Fatalf("expected a snapshot to be written: %v", err)
+	}
+	if s.journal.entries != 1 {
+		t.Errorf("journal holds %d entries after compaction, want 1", s.journal.entries)
+	}
+
+	s, err = newJournaledMemoryStore(dir, 3)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s.Close()
+
+	todos, _ := s.ListTodos()
+	if len(todos) != 4 {
+		t.Errorf("after restart ListTodos returned %d items, want 4", len(todos))
+	}
+}
+
+func TestJournaledStoreTruncatesCorruptTail(t *testing.T) {
+	tests := map[string]string{
+		"torn write":        `0badc0de {"ops":[{"op":"putTodo","todo":{"id":`,
+		"checksum mismatch": "00000000 {\"ops\":[{\"op\":\"deleteTodo\",\"id\":1}]}\n",
+		"garbage":           "not a journal entry\n",
+	}
+
+	for name, tail := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			s, err := newJournaledMemoryStore(dir, 100)
+			if err != nil {
+				t.Fatalf("newJournaledMemoryStore: %v", err)
+			}
+			if err := s.CreateTodo(&TodoItem{Title: "kept", CreatedAt: time.Now().UTC()}); err != nil {
+				t.Fatalf("CreateTodo: %v", err)
+			}
+			s.Close()
+
+			path := filepath.Join(dir, journalFileName)
+			good, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("ReadFile: %v", err)
+			}
+			if err := os.WriteFile(path, append(append([]byte{}, good...), tail...), 0o644); err != nil {
+				t.Fatalf("WriteFile: %v", err)
+			}
+
+			s, err = newJournaledMemoryStore(dir, 100)
+			if err != nil {
+				t.Fatalf("reopen with corrupt tail: %v", err)
+			}
+			defer s.Close()
+
+			todos, _ := s.ListTodos()
+			if len(todos) != 1 || todos[0].Title != "kept" {
+				t.Fatalf("ListTodos = %+v, want the entry written before the corruption", todos)
+			}
+
+			after, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("ReadFile: %v", err)
+			}
+			if string(after) != string(good) {
+				t.Errorf("journal was not truncated back to the last good entry")
+			}
+
+			// New entries must be readable after the truncated tail
+			if err := s.CreateTodo(&TodoItem{Title: "appended", CreatedAt: time.Now().UTC()}); err != nil {
+				t.Fatalf("CreateTodo: %v", err)
+			}
+			s.Close()
+			s, err = newJournaledMemoryStore(dir, 100)
+			if err != nil {
+				t.Fatalf("second reopen: %v", err)
+			}
+			todos, _ = s.ListTodos()
+			if len(todos) != 2 {
+				t.Errorf("after append and restart ListTodos returned %d items, want 2", len(todos))
+			}
+		})
+	}
+}
+
+func TestJournaledStoreDoesNotJournalFailedUpdates(t *testing.T) {
+	dir := t.TempDir()
+	s, err := newJournaledMemoryStore(dir, 100)
+	if err != nil {
+		t.Fatalf("newJournaledMemoryStore: %v", err)
+	}
+	if err := s.Update(func(tx Store) error {
+		if err := tx.CreateTodo(&TodoItem{Title: "discarded", CreatedAt: time.Now().UTC()}); err != nil {
+			return err
+		}
+		return errors.New("boom")
+	}); err == nil {
+		t.Fatal("Update succeeded, want an error")
+	}
+	s.Close()
+
+	restored, err := newJournaledMemoryStore(dir, 100)
+	if err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	defer restored.Close()
+	if todos, _ := restored.ListTodos(); len(todos) != 0 {
+		t.Errorf("restored todos = %+v, want none", todos)
+	}
+}
+
+// failingJournalFile makes a journal's writes fail, after writing part of the
+// entry if writePartly is set, or its syncs fail if failSync is set
+type failingJournalFile struct {
+	*os.File
+	writePartly bool
+	failSync    bool
+}
+
+func (f *failingJournalFile) Write(p []byte) (int, error) {
+	if f.failSync {
+		return f.File.Write(p)
+	}
+	n := 0
+	if f.writePartly {
+		n, _ = f.File.Write(p[:len(p)/2])
+	}
+	return n, errors.New("disk full")
+}
+
+func (f *failingJournalFile) Sync() error {
+	if f.failSync {
+		return errors.New("sync failed")
+	}
+	return f.File.Sync()
+}
+
+func TestJournaledStoreUndoesChangesItCannotJournal(t *testing.T) {
+	for name, failing := range map[string]*failingJournalFile{
+		"write":         {},
+		"partial write": {writePartly: true},
+		"sync":          {failSync: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			s, err := newJournaledMemoryStore(dir, 100)
+			if err != nil {
+				t.Fatalf("newJournaledMemoryStore: %v", err)
+			}
+			file := s.journal.file.(*os.File)
+			failing.File = file
+
+			s.journal.file = failing
+			if err := s.CreateTodo(&TodoItem{Title: "lost", CreatedAt: time.Now().UTC()}); err == nil {
+				t.Fatal("CreateTodo succeeded with a failing journal, want an error")
+			}
+			if todos, _ := s.ListTodos(); len(todos) != 0 {
+				t.Errorf("todos after a failed journal write = %+v, want none", todos)
+			}
+
+			// Later changes are kept, and replayed after a restart
+			s.journal.file = file
+			kept := &TodoItem{Title: "kept", CreatedAt: time.Now().UTC()}
+			if err := s.CreateTodo(kept); err != nil {
+				t.Fatalf("CreateTodo: %v", err)
+			}
+			s.Close()
+
+			restored, err := newJournaledMemoryStore(dir, 100)
+			if err != nil {
+				t.Fatalf("reopening: %v", err)
+			}
+			defer restored.Close()
+			if todos, _ := restored.ListTodos(); len(todos) != 1 || todos[0].Title != "kept" || todos[0].ID != kept.ID {
+				t.Errorf("restored todos = %+v, want only %q", todos, "kept")
+			}
+		})
+	}
+}
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"sync"
+)
+
+// memoryStore keeps all data in maps guarded by a mutex. Data is lost when the
+// server restarts unless a journal is attached (see store_journal.go).
+type memoryStore struct {
+	mu              sync.RWMutex
+	todos           map[int]*TodoItem
+	recurringDefs   map[int]*RecurringItemDefinition
+	history         map[int]*InstanceRecord // Keyed by todo ID
+	nextTodoID      int
+	nextRecurringID int
+
+	journal *journal    // nil when running purely in memory
+	pending []journalOp // mutations made under the current write lock
+	undo    []func()    // undoes the mutations made under the current write lock, latest last
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		todos:           make(map[int]*TodoItem),
+		recurringDefs:   make(map[int]*RecurringItemDefinition),
+		history:         make(map[int]*InstanceRecord),
+		nextTodoID:      1,
+		nextRecurringID: 1,
+	}
+}
+
+func (s *memoryStore) ListTodos() ([]*TodoItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return memoryTx{s}.ListTodos()
+}
+
+func (s *memoryStore) GetTodo(id int) (*TodoItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return memoryTx{s}.GetTodo(id)
+}
+
+func (s *memoryStore) CreateTodo(todo *TodoItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flush(memoryTx{s}.CreateTodo(todo))
+}
+
+func (s *memoryStore) UpdateTodo(todo *TodoItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flush(memoryTx{s}.UpdateTodo(todo))
+}
+
+func (s *memoryStore) DeleteTodo(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flush(memoryTx{s}.DeleteTodo(id))
+}
+
+func (s *memoryStore) ReorderTodos(order []ReorderItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flush(memoryTx{s}.ReorderTodos(order))
+}
+
+func (s *memoryStore) ListRecurringDefs() ([]*RecurringItemDefinition, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return memoryTx{s}.ListRecurringDefs()
+}
+
+func (s *memoryStore) GetRecurringDef(id int) (*RecurringItemDefinition, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return memoryTx{s}.GetRecurringDef(id)
+}
+
+func (s *memoryStore) CreateRecurringDef(def *RecurringItemDefinition) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flush(memoryTx{s}.CreateRecurringDef(def))
+}
+
+func (s *memoryStore) UpdateRecurringDef(def *RecurringItemDefinition) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flush(memoryTx{s}.UpdateRecurringDef(def))
+}
+
+func (s *memoryStore) DeleteRecurringDef(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flush(memoryTx{s}.DeleteRecurringDef(id))
+}
+
+func (s *memoryStore) ListInstanceHistory(recurrenceID int) ([]*InstanceRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return memoryTx{s}.ListInstanceHistory(recurrenceID)
+}
+
+// LockRecurringDef does nothing, as Update already holds the write lock
+func (s *memoryStore) LockRecurringDef(id int) error {
+	return nil
+}
+
+// Update runs fn while holding the write lock. If fn returns an error, the
+// changes it made are undone and not journaled.
+func (s *memoryStore) Update(fn func(tx Store) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flush(fn(memoryTx{s}))
+}
+
+func (s *memoryStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.journal == nil {
+		return nil
+	}
+	return s.journal.close()
+}
+
+// record queues a mutation for the journal. The caller must hold s.mu.
+func (s *memoryStore) record(op journalOp) {
+	if s.journal != nil {
+		s.pending = append(s.pending, op)
+	}
+}
+
+// remember saves m[key] so the mutation about to be made to it can be
+// undone. The caller must hold s.mu.
+func remember[V any](s *memoryStore, m map[int]V, key int) {
+	old, existed := m[key]
+	s.undo = append(s.undo, func() {
+		if existed {
+			m[key] = old
+		} else {
+			delete(m, key)
+		}
+	})
+}
+
+// flush ends the mutations made under the current write lock. If opErr is
+// non-nil they are undone; otherwise they are appended to the journal as one
+// entry, which is compacted into a snapshot when it grows large, and are
+// undone if that fails. The caller must hold s.mu.
+func (s *memoryStore) flush(opErr error) error {
+	undo, ops := s.undo, s.pending
+	s.undo, s.pending = nil, nil
+	if opErr == nil && s.journal != nil && len(ops) > 0 {
+		if opErr = s.journal.append(ops); opErr != nil {
+			log.Printf("Failed to journal %d change(s): %v", len(ops), opErr)
+		}
+	}
+	if opErr != nil {
+		for i := len(undo) - 1; i >= 0; i-- {
+			undo[i]()
+		}
+		return opErr
+	}
+	if s.journal == nil || len(ops) == 0 {
+		return nil
+	}
+
+	if s.journal.entries >= s.journal.snapshotEvery {
+		if err := s.journal.compact(s); err != nil {
+			// The journal is still intact, so this only delays compaction
+			log.Printf("Failed to compact journal: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// apply replays a journal entry. It is idempotent, so replaying entries that
+// are already reflected in the snapshot (e.g. after a crash between writing
+// the snapshot and truncating the journal) is harmless.
+func (s *memoryStore) apply(entry journalEntry) {
+	for _, op := range entry.Ops {
+		switch op.Op {
+		case "putTodo":
+			s.todos[op.Todo.ID] = op.Todo
+			s.nextTodoID = max(s.nextTodoID, op.Todo.ID+1)
+		case "deleteTodo":
+			delete(s.todos, op.ID)
+		case "reorder":
+			for _, item := range op.Order {
+				if todo, exists := s.todos[item.ID]; exists {
+					todo.Position = item.Position
+				}
+			}
+		case "putRecurringDef":
+			s.recurringDefs[op.RecurringDef.ID] = op.RecurringDef
+			s.nextRecurringID = max(s.nextRecurringID, op.RecurringDef.ID+1)
+		case "deleteRecurringDef":
+			delete(s.recurringDefs, op.ID)
+		case "putInstanceRecord":
+			s.history[op.InstanceRecord.TodoID] = op.InstanceRecord
+		default:
+			log.Printf("Ignoring unknown journal operation %q", op.Op)
+		}
+	}
+}
+
+// memoryTx is the view of a memoryStore passed to Update callbacks. Its
+// methods assume the caller already holds the appropriate lock.
+type memoryTx struct {
+	s *memoryStore
+}
+
+func (tx memoryTx) ListTodos() ([]*TodoItem, error) {
+	todos := make([]*TodoItem, 0, len(tx.s.todos))
+	for _, todo := range tx.s.todos {
+		todos = append(todos, todo.clone())
+	}
+
+	// Sort by position
+	sort.Slice(todos, func(i, j int) bool {
+		if todos[i].Position != todos[j].Position {
+			return todos[i].Position < todos[j].Position
+		}
+		return todos[i].ID < todos[j].ID
+	})
+
+	return todos, nil
+}
+
+func (tx memoryTx) GetTodo(id int) (*TodoItem, error) {
+	todo, exists := tx.s.todos[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return todo.clone(), nil
+}
+
+func (tx memoryTx) CreateTodo(todo *TodoItem) error {
+	nextTodoID := tx.s.nextTodoID
+	tx.s.undo = append(tx.s.undo, func() { tx.s.nextTodoID = nextTodoID })
+	todo.ID = tx.s.nextTodoID
+	tx.s.nextTodoID++
+	remember(tx.s, tx.s.todos, todo.ID)
+	tx.s.todos[todo.ID] = todo.clone()
+	tx.s.record(journalOp{Op: "putTodo", Todo: todo.clone()})
+	tx.saveInstanceRecord(todo)
+	return nil
+}
+
+func (tx memoryTx) UpdateTodo(todo *TodoItem) error {
+	if _, exists := tx.s.todos[todo.ID]; !exists {
+		return ErrNotFound
+	}
+	remember(tx.s, tx.s.todos, todo.ID)
+	tx.s.todos[todo.ID] = todo.clone()
+	tx.s.record(journalOp{Op: "putTodo", Todo: todo.clone()})
+	tx.saveInstanceRecord(todo)
+	return nil
+}
+
+func (tx memoryTx) DeleteTodo(id int) error {
+	if _, exists := tx.s.todos[id]; !exists {
+		return ErrNotFound
+	}
+	remember(tx.s, tx.s.todos, id)
+	delete(tx.s.todos, id)
+	tx.s.record(journalOp{Op: "deleteTodo", ID: id})
+	tx.removeInstanceRecord(id)
+	return nil
+}
+
+func (tx memoryTx) ReorderTodos(order []ReorderItem) error {
+	for _, item := range order {
+		if todo, exists := tx.s.todos[item.ID]; exists {
+			remember(tx.s, tx.s.todos, item.ID)
+			todo = todo.clone()
+			todo.Position = item.Position
+			tx.s.todos[item.ID] = todo
+		}
+	}
+	tx.s.record(journalOp{Op: "reorder", Order: order})
+	return nil
+}
+
+func (tx memoryTx) ListRecurringDefs() ([]*RecurringItemDefinition, error) {
+	defs := make([]*RecurringItemDefinition, 0, len(tx.s.recurringDefs))
+	for _, def := range tx.s.recurringDefs {
+		defs = append(defs, def.clone())
+	}
+
+	sort.Slice(defs, func(i, j int) bool {
+		return defs[i].ID < defs[j].ID
+	})
+
+	return defs, nil
+}
+
+func (tx memoryTx) GetRecurringDef(id int) (*RecurringItemDefinition, error) {
+	def, exists := tx.s.recurringDefs[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return def.clone(), nil
+}
+
+func (tx memoryTx) CreateRecurringDef(def *RecurringItemDefinition) error {
+	nextRecurringID := tx.s.nextRecurringID
+	tx.s.undo = append(tx.s.undo, func() { tx.s.nextRecurringID = nextRecurringID })
+	def.ID = tx.s.nextRecurringID
+	tx.s.nextRecurringID++
+	remember(tx.s, tx.s.recurringDefs, def.ID)
+	tx.s.recurringDefs[def.ID] = def.clone()
+	tx.s.record(journalOp{Op: "putRecurringDef", RecurringDef: def.clone()})
+	return nil
+}
+
+func (tx memoryTx) UpdateRecurringDef(def *RecurringItemDefinition) error {
+	if _, exists := tx.s.recurringDefs[def.ID]; !exists {
+		return ErrNotFound
+	}
+	remember(tx.s, tx.s.recurringDefs, def.ID)
+	tx.s.recurringDefs[def.ID] = def.clone()
+	tx.s.record(journalOp{Op: "putRecurringDef", RecurringDef: def.clone()})
+	return nil
+}
+
+func (tx memoryTx) DeleteRecurringDef(id int) error {
+	if _, exists := tx.s.recurringDefs[id]; !exists {
+		return ErrNotFound
+	}
+	remember(tx.s, tx.s.recurringDefs, id)
+	delete(tx.s.recurringDefs, id)
+	tx.s.record(journalOp{Op: "deleteRecurringDef", ID: id})
+	return nil
+}
+
+func (tx memoryTx) ListInstanceHistory(recurrenceID int) ([]*InstanceRecord, error) {
+	records := make([]*InstanceRecord, 0)
+	for _, rec := range tx.s.history {
+		if rec.RecurrenceID == recurrenceID {
+			records = append(records, rec.clone())
+		}
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].TodoID < records[j].TodoID
+	})
+
+	return records, nil
+}
+
+// saveInstanceRecord updates the history record of todo, which has just
+// been saved, marking it removed if todo is no longer a recurring instance
+func (tx memoryTx) saveInstanceRecord(todo *TodoItem) {
+	if todo.RecurrenceID == nil {
+		tx.removeInstanceRecord(todo.ID)
+		return
+	}
+	tx.putInstanceRecord(newInstanceRecord(todo))
+}
+
+// removeInstanceRecord marks the history record of a deleted or unlinked
+// instance removed, if it has one
+func (tx memoryTx) removeInstanceRecord(todoID int) {
+	rec, exists := tx.s.history[todoID]
+	if !exists || rec.RemovedAt != nil {
+		return
+	}
+	rec = rec.clone()
+	now := clock.Now()
+	rec.RemovedAt = &now
+	tx.putInstanceRecord(rec)
+}
+
+func (tx memoryTx) putInstanceRecord(rec *InstanceRecord) {
+	remember(tx.s, tx.s.history, rec.TodoID)
+	tx.s.history[rec.TodoID] = rec
+	tx.s.record(journalOp{Op: "putInstanceRecord", InstanceRecord: rec.clone()})
+}
+
+func (tx memoryTx) LockRecurringDef(id int) error {
+	return nil
+}
+
+func (tx memoryTx) Update(fn func(tx Store) error) error {
+	return fn(tx)
+}
+
+func (tx memoryTx) Close() error {
+	return nil
+}
@@ -0,0 +1,570 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// sqlStore is a Store backed by a database/sql connection. Slices and the
+// recurrence pattern are stored as JSON text columns.
+type sqlStore struct {
+	db      *sql.DB
+	dialect sqlDialect
+}
+
+// sqlDialect identifies the SQL database behind a sqlStore
+type sqlDialect string
+
+const (
+	dialectSQLite   sqlDialect = "sqlite"
+	dialectPostgres sqlDialect = "postgres"
+)
+
+// rebind rewrites ? placeholders into the dialect's native form
+func (d sqlDialect) rebind(query string) string {
+	if d != dialectPostgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// sqlQuerier is the subset of *sql.DB and *sql.Tx used by sqlTx
+type sqlQuerier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// sqlTx implements the Store operations against either the database or an
+// open transaction
+type sqlTx struct {
+	q       sqlQuerier
+	dialect sqlDialect
+}
+
+func (tx sqlTx) exec(query string, args ...any) (sql.Result, error) {
+	return tx.q.Exec(tx.dialect.rebind(query), args...)
+}
+
+func (tx sqlTx) query(query string, args ...any) (*sql.Rows, error) {
+	return tx.q.Query(tx.dialect.rebind(query), args...)
+}
+
+func (tx sqlTx) queryRow(query string, args ...any) *sql.Row {
+	return tx.q.QueryRow(tx.dialect.rebind(query), args...)
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+const todoColumns = `id, title, description, assigned_to, completed, position,
+	is_recurring, recurrence_id, due_date, completed_at, created_at, next_instance_id, skipped, original_due_date, completed_by,
+	missed, missed_occurrences, all_day`
+
+const recurringDefColumns = `id, title, description, assigned_to, pattern, start_date, created_at,
+	end_date, max_occurrences, finished_at, paused_at, rotation, missed_policy, missed_count, time_zone, all_day,
+	holiday_region, holiday_rule`
+
+const instanceRecordColumns = `todo_id, recurrence_id, title, assigned_to, due_date, original_due_date,
+	completed, completed_at, completed_by, skipped, missed, missed_occurrences, created_at, removed_at`
+
+func (s *sqlStore) tx(q sqlQuerier) sqlTx {
+	return sqlTx{q: q, dialect: s.dialect}
+}
+
+func (s *sqlStore) ListTodos() ([]*TodoItem, error) {
+	return s.tx(s.db).ListTodos()
+}
+
+func (s *sqlStore) GetTodo(id int) (*TodoItem, error) {
+	return s.tx(s.db).GetTodo(id)
+}
+
+func (s *sqlStore) CreateTodo(todo *TodoItem) error {
+	return s.Update(func(tx Store) error {
+		return tx.CreateTodo(todo)
+	})
+}
+
+func (s *sqlStore) UpdateTodo(todo *TodoItem) error {
+	return s.Update(func(tx Store) error {
+		return tx.UpdateTodo(todo)
+	})
+}
+
+func (s *sqlStore) DeleteTodo(id int) error {
+	return s.Update(func(tx Store) error {
+		return tx.DeleteTodo(id)
+	})
+}
+
+func (s *sqlStore) ReorderTodos(order []ReorderItem) error {
+	return s.Update(func(tx Store) error {
+		return tx.ReorderTodos(order)
+	})
+}
+
+func (s *sqlStore) ListRecurringDefs() ([]*RecurringItemDefinition, error) {
+	return s.tx(s.db).ListRecurringDefs()
+}
+
+func (s *sqlStore) GetRecurringDef(id int) (*RecurringItemDefinition, error) {
+	return s.tx(s.db).GetRecurringDef(id)
+}
+
+func (s *sqlStore) CreateRecurringDef(def *RecurringItemDefinition) error {
+	return s.tx(s.db).CreateRecurringDef(def)
+}
+
+func (s *sqlStore) UpdateRecurringDef(def *RecurringItemDefinition) error {
+	return s.tx(s.db).UpdateRecurringDef(def)
+}
+
+func (s *sqlStore) DeleteRecurringDef(id int) error {
+	return s.tx(s.db).DeleteRecurringDef(id)
+}
+
+func (s *sqlStore) ListInstanceHistory(recurrenceID int) ([]*InstanceRecord, error) {
+	return s.tx(s.db).ListInstanceHistory(recurrenceID)
+}
+
+// LockRecurringDef does nothing outside a transaction
+func (s *sqlStore) LockRecurringDef(id int) error {
+	return nil
+}
+
+// Update runs fn inside a database transaction, committing if fn succeeds
+// and rolling back otherwise
+func (s *sqlStore) Update(fn func(tx Store) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if err := fn(s.tx(tx)); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlStore) Close() error {
+	return s.db.Close()
+}
+
+func (tx sqlTx) ListTodos() ([]*TodoItem, error) {
+	rows, err := tx.query(`SELECT ` + todoColumns + ` FROM todos ORDER BY position, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list todos: %w", err)
+	}
+	defer rows.Close()
+
+	todos := make([]*TodoItem, 0)
+	for rows.Next() {
+		todo, err := scanTodo(rows)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, todo)
+	}
+	return todos, rows.Err()
+}
+
+func (tx sqlTx) GetTodo(id int) (*TodoItem, error) {
+	row := tx.queryRow(`SELECT `+todoColumns+` FROM todos WHERE id = ?`, id)
+	return scanTodo(row)
+}
+
+func (tx sqlTx) CreateTodo(todo *TodoItem) error {
+	assignedTo, err := json.Marshal(todo.AssignedTo)
+	if err != nil {
+		return err
+	}
+
+	err = tx.queryRow(`INSERT INTO todos (title, description, assigned_to, completed, position,
+			is_recurring, recurrence_id, due_date, completed_at, created_at, next_instance_id, skipped, original_due_date,
+			completed_by, missed, missed_occurrences, all_day)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+		todo.Title, todo.Description, string(assignedTo), todo.Completed, todo.Position,
+		todo.IsRecurring, nullInt(todo.RecurrenceID), nullTime(todo.DueDate), nullTime(todo.CompletedAt), todo.CreatedAt,
+		nullInt(todo.NextInstanceID), todo.Skipped, nullTime(todo.OriginalDueDate), todo.CompletedBy,
+		todo.Missed, todo.MissedOccurrences, todo.AllDay,
+	).Scan(&todo.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create todo: %w", err)
+	}
+	return tx.saveInstanceRecord(todo)
+}
+
+func (tx sqlTx) UpdateTodo(todo *TodoItem) error {
+	assignedTo, err := json.Marshal(todo.AssignedTo)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.exec(`UPDATE todos SET title = ?, description = ?, assigned_to = ?, completed = ?,
+			position = ?, is_recurring = ?, recurrence_id = ?, due_date = ?, completed_at = ?, next_instance_id = ?,
+			skipped = ?, original_due_date = ?, completed_by = ?, missed = ?, missed_occurrences = ?, all_day = ?
+		WHERE id = ?`,
+		todo.Title, todo.Description, string(assignedTo), todo.Completed,
+		todo.Position, todo.IsRecurring, nullInt(todo.RecurrenceID), nullTime(todo.DueDate), nullTime(todo.CompletedAt),
+		nullInt(todo.NextInstanceID), todo.Skipped, nullTime(todo.OriginalDueDate), todo.CompletedBy,
+		todo.Missed, todo.MissedOccurrences, todo.AllDay,
+		todo.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update todo: %w", err)
+	}
+	if err := requireRowAffected(result); err != nil {
+		return err
+	}
+	return tx.saveInstanceRecord(todo)
+}
+
+func (tx sqlTx) DeleteTodo(id int) error {
+	result, err := tx.exec(`DELETE FROM todos WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete todo: %w", err)
+	}
+	if err := requireRowAffected(result); err != nil {
+		return err
+	}
+	return tx.removeInstanceRecord(id)
+}
+
+func (tx sqlTx) ReorderTodos(order []ReorderItem) error {
+	for _, item := range order {
+		if _, err := tx.exec(`UPDATE todos SET position = ? WHERE id = ?`, item.Position, item.ID); err != nil {
+			return fmt.Errorf("failed to reorder todos: %w", err)
+		}
+	}
+	return nil
+}
+
+func (tx sqlTx) ListRecurringDefs() ([]*RecurringItemDefinition, error) {
+	rows, err := tx.query(`SELECT ` + recurringDefColumns + ` FROM recurring_defs ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recurring definitions: %w", err)
+	}
+	defer rows.Close()
+
+	defs := make([]*RecurringItemDefinition, 0)
+	for rows.Next() {
+		def, err := scanRecurringDef(rows)
+		if err != nil {
+			return nil, err
+		}
+		defs = append(defs, def)
+	}
+	return defs, rows.Err()
+}
+
+func (tx sqlTx) GetRecurringDef(id int) (*RecurringItemDefinition, error) {
+	row := tx.queryRow(`SELECT `+recurringDefColumns+` FROM recurring_defs WHERE id = ?`, id)
+	return scanRecurringDef(row)
+}
+
+func (tx sqlTx) CreateRecurringDef(def *RecurringItemDefinition) error {
+	assignedTo, err := json.Marshal(def.AssignedTo)
+	if err != nil {
+		return err
+	}
+	pattern, err := json.Marshal(def.Pattern)
+	if err != nil {
+		return err
+	}
+	rotation, err := json.Marshal(def.Rotation)
+	if err != nil {
+		return err
+	}
+
+	err = tx.queryRow(`INSERT INTO recurring_defs (title, description, assigned_to, pattern, start_date, created_at,
+			end_date, max_occurrences, finished_at, paused_at, rotation, missed_policy, missed_count, time_zone, all_day,
+			holiday_region, holiday_rule)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+		def.Title, def.Description, string(assignedTo), string(pattern), def.StartDate, def.CreatedAt,
+		nullTime(def.EndDate), def.MaxOccurrences, nullTime(def.FinishedAt), nullTime(def.PausedAt), string(rotation),
+		def.MissedPolicy, def.MissedCount, def.TimeZone, def.AllDay, def.HolidayRegion, def.HolidayRule,
+	).Scan(&def.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create recurring definition: %w", err)
+	}
+	return nil
+}
+
+func (tx sqlTx) UpdateRecurringDef(def *RecurringItemDefinition) error {
+	assignedTo, err := json.Marshal(def.AssignedTo)
+	if err != nil {
+		return err
+	}
+	pattern, err := json.Marshal(def.Pattern)
+	if err != nil {
+		return err
+	}
+	rotation, err := json.Marshal(def.Rotation)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.exec(`UPDATE recurring_defs SET title = ?, description = ?, assigned_to = ?, pattern = ?, start_date = ?,
+			end_date = ?, max_occurrences = ?, finished_at = ?, paused_at = ?, rotation = ?, missed_policy = ?, missed_count = ?,
+			time_zone = ?, all_day = ?, holiday_region = ?, holiday_rule = ?
+		WHERE id = ?`,
+		def.Title, def.Description, string(assignedTo), string(pattern), def.StartDate,
+		nullTime(def.EndDate), def.MaxOccurrences, nullTime(def.FinishedAt), nullTime(def.PausedAt), string(rotation),
+		def.MissedPolicy, def.MissedCount, def.TimeZone, def.AllDay, def.HolidayRegion, def.HolidayRule,
+		def.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update recurring definition: %w", err)
+	}
+	return requireRowAffected(result)
+}
+
+func (tx sqlTx) DeleteRecurringDef(id int) error {
+	result, err := tx.exec(`DELETE FROM recurring_defs WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete recurring definition: %w", err)
+	}
+	return requireRowAffected(result)
+}
+
+// LockRecurringDef locks the definition's row until the transaction ends.
+// SQLite needs no lock, as its store serializes transactions on a single
+// connection.
+func (tx sqlTx) LockRecurringDef(id int) error {
+	if tx.dialect != dialectPostgres {
+		return nil
+	}
+	rows, err := tx.query(`SELECT id FROM recurring_defs WHERE id = ? FOR UPDATE`, id)
+	if err != nil {
+		return fmt.Errorf("failed to lock recurring definition: %w", err)
+	}
+	return rows.Close()
+}
+
+func (tx sqlTx) ListInstanceHistory(recurrenceID int) ([]*InstanceRecord, error) {
+	rows, err := tx.query(`SELECT `+instanceRecordColumns+` FROM instance_history WHERE recurrence_id = ? ORDER BY todo_id`, recurrenceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list instance history: %w", err)
+	}
+	defer rows.Close()
+
+	records := make([]*InstanceRecord, 0)
+	for rows.Next() {
+		rec, err := scanInstanceRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
+
+// saveInstanceRecord updates the history record of todo, which has just
+// been saved, marking it removed if todo is no longer a recurring instance
+func (tx sqlTx) saveInstanceRecord(todo *TodoItem) error {
+	if todo.RecurrenceID == nil {
+		return tx.removeInstanceRecord(todo.ID)
+	}
+
+	rec := newInstanceRecord(todo)
+	assignedTo, err := json.Marshal(rec.AssignedTo)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.exec(`INSERT INTO instance_history (`+instanceRecordColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NULL)
+		ON CONFLICT (todo_id) DO UPDATE SET recurrence_id = excluded.recurrence_id, title = excluded.title,
+			assigned_to = excluded.assigned_to, due_date = excluded.due_date, original_due_date = excluded.original_due_date,
+			completed = excluded.completed, completed_at = excluded.completed_at, completed_by = excluded.completed_by,
+			skipped = excluded.skipped, missed = excluded.missed, missed_occurrences = excluded.missed_occurrences,
+			removed_at = NULL`,
+		rec.TodoID, rec.RecurrenceID, rec.Title, string(assignedTo), nullTime(rec.DueDate), nullTime(rec.OriginalDueDate),
+		rec.Completed, nullTime(rec.CompletedAt), rec.CompletedBy, rec.Skipped, rec.Missed, rec.MissedOccurrences,
+		rec.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save instance history: %w", err)
+	}
+	return nil
+}
+
+// removeInstanceRecord marks the history record of a deleted or unlinked
+// instance removed, if it has one
+func (tx sqlTx) removeInstanceRecord(todoID int) error {
+	_, err := tx.exec(`UPDATE instance_history SET removed_at = ? WHERE todo_id = ? AND removed_at IS NULL`,
+		clock.Now(), todoID)
+	if err != nil {
+		return fmt.Errorf("failed to save instance history: %w", err)
+	}
+	return nil
+}
+
+// Update runs fn in the current transaction; transactions do not nest
+func (tx sqlTx) Update(fn func(tx Store) error) error {
+	return fn(tx)
+}
+
+func (tx sqlTx) Close() error {
+	return nil
+}
+
+func scanTodo(row rowScanner) (*TodoItem, error) {
+	var todo TodoItem
+	var assignedTo string
+	var recurrenceID, nextInstanceID sql.NullInt64
+	var dueDate, completedAt, originalDueDate sql.NullTime
+
+	err := row.Scan(&todo.ID, &todo.Title, &todo.Description, &assignedTo, &todo.Completed, &todo.Position,
+		&todo.IsRecurring, &recurrenceID, &dueDate, &completedAt, &todo.CreatedAt, &nextInstanceID,
+		&todo.Skipped, &originalDueDate, &todo.CompletedBy, &todo.Missed, &todo.MissedOccurrences, &todo.AllDay)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read todo: %w", err)
+	}
+
+	if err := json.Unmarshal([]byte(assignedTo), &todo.AssignedTo); err != nil {
+		return nil, fmt.Errorf("failed to decode assignees for todo %d: %w", todo.ID, err)
+	}
+	todo.RecurrenceID = intPtr(recurrenceID)
+	todo.NextInstanceID = intPtr(nextInstanceID)
+	if dueDate.Valid {
+		todo.DueDate = &dueDate.Time
+	}
+	if completedAt.Valid {
+		todo.CompletedAt = &completedAt.Time
+	}
+	if originalDueDate.Valid {
+		todo.OriginalDueDate = &originalDueDate.Time
+	}
+
+	return &todo, nil
+}
+
+func scanRecurringDef(row rowScanner) (*RecurringItemDefinition, error) {
+	var def RecurringItemDefinition
+	var assignedTo, pattern, rotation string
+	var endDate, finishedAt, pausedAt sql.NullTime
+
+	err := row.Scan(&def.ID, &def.Title, &def.Description, &assignedTo, &pattern, &def.StartDate, &def.CreatedAt,
+		&endDate, &def.MaxOccurrences, &finishedAt, &pausedAt, &rotation, &def.MissedPolicy, &def.MissedCount,
+		&def.TimeZone, &def.AllDay, &def.HolidayRegion, &def.HolidayRule)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recurring definition: %w", err)
+	}
+
+	if err := json.Unmarshal([]byte(assignedTo), &def.AssignedTo); err != nil {
+		return nil, fmt.Errorf("failed to decode assignees for recurring definition %d: %w", def.ID, err)
+	}
+	if err := json.Unmarshal([]byte(pattern), &def.Pattern); err != nil {
+		return nil, fmt.Errorf("failed to decode pattern for recurring definition %d: %w", def.ID, err)
+	}
+	if err := json.Unmarshal([]byte(rotation), &def.Rotation); err != nil {
+		return nil, fmt.Errorf("failed to decode rotation for recurring definition %d: %w", def.ID, err)
+	}
+	if endDate.Valid {
+		def.EndDate = &endDate.Time
+	}
+	if finishedAt.Valid {
+		def.FinishedAt = &finishedAt.Time
+		def.Finished = true
+	}
+	if pausedAt.Valid {
+		def.PausedAt = &pausedAt.Time
+		def.Paused = true
+	}
+
+	return &def, nil
+}
+
+func scanInstanceRecord(row rowScanner) (*InstanceRecord, error) {
+	var rec InstanceRecord
+	var assignedTo string
+	var dueDate, originalDueDate, completedAt, removedAt sql.NullTime
+
+	err := row.Scan(&rec.TodoID, &rec.RecurrenceID, &rec.Title, &assignedTo, &dueDate, &originalDueDate,
+		&rec.Completed, &completedAt, &rec.CompletedBy, &rec.Skipped, &rec.Missed, &rec.MissedOccurrences,
+		&rec.CreatedAt, &removedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read instance history: %w", err)
+	}
+
+	if err := json.Unmarshal([]byte(assignedTo), &rec.AssignedTo); err != nil {
+		return nil, fmt.Errorf("failed to decode assignees for instance history of todo %d: %w", rec.TodoID, err)
+	}
+	if dueDate.Valid {
+		rec.DueDate = &dueDate.Time
+	}
+	if originalDueDate.Valid {
+		rec.OriginalDueDate = &originalDueDate.Time
+	}
+	if completedAt.Valid {
+		rec.CompletedAt = &completedAt.Time
+	}
+	if removedAt.Valid {
+		rec.RemovedAt = &removedAt.Time
+	}
+
+	return &rec, nil
+}
+
+func requireRowAffected(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func nullInt(v *int) sql.NullInt64 {
+	if v == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(*v), Valid: true}
+}
+
+func intPtr(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	i := int(v.Int64)
+	return &i
+}
+
+func nullTime(v *time.Time) sql.NullTime {
+	if v == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *v, Valid: true}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	_ "modernc.org/sqlite"
+)
+
+// newSQLiteStore opens (creating if necessary) the SQLite database at path.
+// The schema is managed by migrations; see migrateDatabase.
+func newSQLiteStore(path string) (*sqlStore, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create directory for sqlite database: %w", err)
+		}
+	}
+
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+
+	// SQLite allows a single writer; serialising connections avoids
+	// SQLITE_BUSY errors when handlers run concurrently.
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+
+	return &sqlStore{db: db, dialect: dialectSQLite}, nil
+}
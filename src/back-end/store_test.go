@@ -0,0 +1,397 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// useStore makes the handlers use s until the test ends
+func useStore(t *testing.T, s Store) {
+	t.Helper()
+	previous := store
+	store = s
+	t.Cleanup(func() { store = previous })
+}
+
+// testStores returns a fresh instance of every Store implementation that can
+// run in this environment. PostgreSQL is included when TEST_POSTGRES_URL is
+// set, e.g. via `make test-backend-postgres`.
+func testStores(t *testing.T) map[string]Store {
+	t.Helper()
+
+	stores := map[string]Store{
+		"memory": newMemoryStore(),
+	}
+
+	journaled, err := newJournaledMemoryStore(t.TempDir(), 2)
+	if err != nil {
+		t.Fatalf("failed to open journaled store: %v", err)
+	}
+	stores["journal"] = journaled
+
+	sqlite, err := newStore("sqlite://"+filepath.Join(t.TempDir(), "todos.db"), true)
+	if err != nil {
+		t.Fatalf("failed to open sqlite store: %v", err)
+	}
+	stores["sqlite"] = sqlite
+
+	if dsn := os.Getenv("TEST_POSTGRES_URL"); dsn != "" {
+		pg, err := newPostgresStore(dsn)
+		if err != nil {
+			t.Fatalf("failed to open postgres store: %v", err)
+		}
+		if _, err := pg.db.Exec(`DROP TABLE IF EXISTS todos, recurring_defs, instance_history, schema_migrations`); err != nil {
+			t.Fatalf("failed to reset postgres database: %v", err)
+		}
+		if _, err := migrateDatabase(pg.db, pg.dialect); err != nil {
+			t.Fatalf("failed to migrate postgres store: %v", err)
+		}
+		stores["postgres"] = pg
+	}
+
+	for _, s := range stores {
+		t.Cleanup(func() { s.Close() })
+	}
+	return stores
+}
+
+func TestStoreTodoCRUD(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			due := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+			todo := &TodoItem{
+				Title:      "Take out the bins",
+				AssignedTo: []string{"alice", "bob"},
+				DueDate:    &due,
+				CreatedAt:  time.Now().UTC(),
+			}
+			if err := s.CreateTodo(todo); err != nil {
+				t.Fatalf("CreateTodo: %v", err)
+			}
+			if todo.ID == 0 {
+				t.Fatal("CreateTodo did not assign an ID")
+			}
+
+			got, err := s.GetTodo(todo.ID)
+			if err != nil {
+				t.Fatalf("GetTodo: %v", err)
+			}
+			if got.Title != todo.Title || len(got.AssignedTo) != 2 || got.DueDate == nil || !got.DueDate.Equal(due) {
+				t.Errorf("GetTodo = %+v, want %+v", got, todo)
+			}
+
+			got.Completed = true
+			completedAt := time.Now().UTC()
+			got.CompletedAt = &completedAt
+			got.Skipped = true
+			got.OriginalDueDate = &due
+			got.CompletedBy = "alice@example.com"
+			got.Missed = true
+			got.MissedOccurrences = 2
+			got.AllDay = true
+			if err := s.UpdateTodo(got); err != nil {
+				t.Fatalf("UpdateTodo: %v", err)
+			}
+			got, err = s.GetTodo(todo.ID)
+			if err != nil {
+				t.Fatalf("GetTodo after update: %v", err)
+			}
+			if !got.Completed || got.CompletedAt == nil || !got.Skipped || got.OriginalDueDate == nil || !got.OriginalDueDate.Equal(due) ||
+				got.CompletedBy != "alice@example.com" || !got.Missed || got.MissedOccurrences != 2 || !got.AllDay {
+				t.Errorf("update not persisted: %+v", got)
+			}
+
+			if err := s.DeleteTodo(todo.ID); err != nil {
+				t.Fatalf("DeleteTodo: %v", err)
+			}
+			if _, err := s.GetTodo(todo.ID); !errors.Is(err, ErrNotFound) {
+				t.Errorf("GetTodo after delete: err = %v, want ErrNotFound", err)
+			}
+			if err := s.DeleteTodo(todo.ID); !errors.Is(err, ErrNotFound) {
+				t.Errorf("second DeleteTodo: err = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestStoreReorderTodos(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			var ids []int
+			for i, title := range []string{"first", "second", "third"} {
+				todo := &TodoItem{Title: title, Position: i, CreatedAt: time.Now().UTC()}
+				if err := s.CreateTodo(todo); err != nil {
+					t.Fatalf("CreateTodo: %v", err)
+				}
+				ids = append(ids, todo.ID)
+			}
+
+			err := s.ReorderTodos([]ReorderItem{
+				{ID: ids[0], Position: 2},
+				{ID: ids[1], Position: 0},
+				{ID: ids[2], Position: 1},
+			})
+			if err != nil {
+				t.Fatalf("ReorderTodos: %v", err)
+			}
+
+			todos, err := s.ListTodos()
+			if err != nil {
+				t.Fatalf("ListTodos: %v", err)
+			}
+			var titles []string
+			for _, todo := range todos {
+				titles = append(titles, todo.Title)
+			}
+			want := []string{"second", "third", "first"}
+			if len(titles) != len(want) {
+				t.Fatalf("ListTodos titles = %v, want %v", titles, want)
+			}
+			for i := range want {
+				if titles[i] != want[i] {
+					t.Fatalf("ListTodos titles = %v, want %v", titles, want)
+				}
+			}
+		})
+	}
+}
+
+func TestStoreRecurringDefs(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			endDate := time.Date(2026, 6, 30, 0, 0, 0, 0, time.UTC)
+			def := &RecurringItemDefinition{
+				Title:          "Water plants",
+				Pattern:        RecurrencePattern{Frequency: "weekly", Interval: 2, DaysOfWeek: []string{"Monday"}},
+				StartDate:      time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC),
+				CreatedAt:      time.Now().UTC(),
+				EndDate:        &endDate,
+				MaxOccurrences: 10,
+			}
+
+			err := s.Update(func(tx Store) error {
+				if err := tx.CreateRecurringDef(def); err != nil {
+					return err
+				}
+				return tx.CreateTodo(&TodoItem{Title: def.Title, IsRecurring: true, RecurrenceID: &def.ID, CreatedAt: time.Now().UTC()})
+			})
+			if err != nil {
+				t.Fatalf("Update: %v", err)
+			}
+
+			got, err := s.GetRecurringDef(def.ID)
+			if err != nil {
+				t.Fatalf("GetRecurringDef: %v", err)
+			}
+			if got.Pattern.Interval != 2 || len(got.Pattern.DaysOfWeek) != 1 || !got.StartDate.Equal(def.StartDate) {
+				t.Errorf("GetRecurringDef = %+v, want %+v", got, def)
+			}
+			if got.EndDate == nil || !got.EndDate.Equal(endDate) || got.MaxOccurrences != 10 || got.Finished {
+				t.Errorf("GetRecurringDef end conditions = %v, %d, finished %v", got.EndDate, got.MaxOccurrences, got.Finished)
+			}
+
+			finishedAt := time.Now().UTC().Truncate(time.Second)
+			got.Finished = true
+			got.FinishedAt = &finishedAt
+			if err := s.UpdateRecurringDef(got); err != nil {
+				t.Fatalf("UpdateRecurringDef: %v", err)
+			}
+			if got, err := s.GetRecurringDef(def.ID); err != nil || !got.Finished || got.FinishedAt == nil || !got.FinishedAt.Equal(finishedAt) {
+				t.Errorf("GetRecurringDef after finishing = %+v, %v", got, err)
+			}
+
+			pausedAt := time.Now().UTC().Truncate(time.Second)
+			got.Paused = true
+			got.PausedAt = &pausedAt
+			if err := s.UpdateRecurringDef(got); err != nil {
+				t.Fatalf("UpdateRecurringDef: %v", err)
+			}
+			if got, err := s.GetRecurringDef(def.ID); err != nil || !got.Paused || got.PausedAt == nil || !got.PausedAt.Equal(pausedAt) {
+				t.Errorf("GetRecurringDef after pausing = %+v, %v", got, err)
+			}
+
+			completedAt := time.Now().UTC().Truncate(time.Second)
+			got.Rotation = &AssigneeRotation{Mode: rotationLeastRecent, Next: 1, LastCompleted: map[string]time.Time{"bob": completedAt}}
+			got.MissedPolicy = missedPolicySkip
+			got.MissedCount = 4
+			got.TimeZone = "Europe/London"
+			got.AllDay = true
+			got.HolidayRegion = "uk"
+			got.HolidayRule = holidayRuleSkip
+			if err := s.UpdateRecurringDef(got); err != nil {
+				t.Fatalf("UpdateRecurringDef: %v", err)
+			}
+			if got, err := s.GetRecurringDef(def.ID); err != nil || got.Rotation == nil || got.Rotation.Mode != rotationLeastRecent ||
+				got.Rotation.Next != 1 || !got.Rotation.LastCompleted["bob"].Equal(completedAt) ||
+				got.MissedPolicy != missedPolicySkip || got.MissedCount != 4 || got.TimeZone != "Europe/London" || !got.AllDay ||
+				got.HolidayRegion != "uk" || got.HolidayRule != holidayRuleSkip {
+				t.Errorf("GetRecurringDef after setting rotation = %+v, %v", got, err)
+			}
+
+			todos, err := s.ListTodos()
+			if err != nil {
+				t.Fatalf("ListTodos: %v", err)
+			}
+			if len(todos) != 1 || todos[0].RecurrenceID == nil || *todos[0].RecurrenceID != def.ID {
+				t.Errorf("expected one instance linked to definition %d, got %+v", def.ID, todos)
+			}
+
+			if err := s.DeleteRecurringDef(def.ID); err != nil {
+				t.Fatalf("DeleteRecurringDef: %v", err)
+			}
+			defs, err := s.ListRecurringDefs()
+			if err != nil {
+				t.Fatalf("ListRecurringDefs: %v", err)
+			}
+			if len(defs) != 0 {
+				t.Errorf("ListRecurringDefs after delete = %+v, want none", defs)
+			}
+		})
+	}
+}
+
+func TestStoreUpdateRollsBack(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			kept := &TodoItem{Title: "kept", Position: 1, CreatedAt: time.Now().UTC()}
+			if err := s.CreateTodo(kept); err != nil {
+				t.Fatalf("CreateTodo: %v", err)
+			}
+			def := &RecurringItemDefinition{Title: "def", Pattern: RecurrencePattern{Frequency: "daily", Interval: 1},
+				StartDate: time.Now().UTC(), CreatedAt: time.Now().UTC()}
+			if err := s.CreateRecurringDef(def); err != nil {
+				t.Fatalf("CreateRecurringDef: %v", err)
+			}
+
+			boom := errors.New("boom")
+			err := s.Update(func(tx Store) error {
+				if err := tx.CreateTodo(&TodoItem{Title: "discarded", RecurrenceID: &def.ID, CreatedAt: time.Now().UTC()}); err != nil {
+					return err
+				}
+				changed := kept.clone()
+				changed.Title = "changed"
+				if err := tx.UpdateTodo(changed); err != nil {
+					return err
+				}
+				if err := tx.ReorderTodos([]ReorderItem{{ID: kept.ID, Position: 5}}); err != nil {
+					return err
+				}
+				if err := tx.DeleteRecurringDef(def.ID); err != nil {
+					return err
+				}
+				return boom
+			})
+			if !errors.Is(err, boom) {
+				t.Fatalf("Update err = %v, want %v", err, boom)
+			}
+
+			todos, err := s.ListTodos()
+			if err != nil {
+				t.Fatalf("ListTodos: %v", err)
+			}
+			if len(todos) != 1 || todos[0].Title != "kept" || todos[0].Position != 1 {
+				t.Errorf("ListTodos after rollback = %+v, want only the unchanged kept todo", todos)
+			}
+			if _, err := s.GetRecurringDef(def.ID); err != nil {
+				t.Errorf("GetRecurringDef after rollback: %v", err)
+			}
+			if records, err := s.ListInstanceHistory(def.ID); err != nil || len(records) != 0 {
+				t.Errorf("ListInstanceHistory after rollback = %+v, %v, want none", records, err)
+			}
+
+			// The next ID is not taken by the discarded todo
+			next := &TodoItem{Title: "next", CreatedAt: time.Now().UTC()}
+			if err := s.CreateTodo(next); err != nil {
+				t.Fatalf("CreateTodo: %v", err)
+			}
+			if name != "sqlite" && name != "postgres" && next.ID != kept.ID+1 {
+				t.Errorf("next todo ID = %d, want %d", next.ID, kept.ID+1)
+			}
+		})
+	}
+}
+
+func TestMigrationsAreIdempotent(t *testing.T) {
+	s, err := newSQLiteStore(filepath.Join(t.TempDir(), "todos.db"))
+	if err != nil {
+		t.Fatalf("newSQLiteStore: %v", err)
+	}
+	defer s.Close()
+
+	applied, err := migrateDatabase(s.db, s.dialect)
+	if err != nil {
+		t.Fatalf("first migrateDatabase: %v", err)
+	}
+	if len(applied) == 0 {
+		t.Fatal("expected migrations to be applied to a new database")
+	}
+
+	applied, err = migrateDatabase(s.db, s.dialect)
+	if err != nil {
+		t.Fatalf("second migrateDatabase: %v", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("second migrateDatabase applied %d migrations, want 0", len(applied))
+	}
+
+	pending, err := pendingMigrations(s.db, s.dialect)
+	if err != nil {
+		t.Fatalf("pendingMigrations: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("pendingMigrations = %d, want 0", len(pending))
+	}
+}
+
+func TestMigrationsRejectNewerSchema(t *testing.T) {
+	s, err := newSQLiteStore(filepath.Join(t.TempDir(), "todos.db"))
+	if err != nil {
+		t.Fatalf("newSQLiteStore: %v", err)
+	}
+	defer s.Close()
+
+	if _, err := migrateDatabase(s.db, s.dialect); err != nil {
+		t.Fatalf("migrateDatabase: %v", err)
+	}
+	if _, err := s.db.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (9999, '9999_future', ?)`, time.Now()); err != nil {
+		t.Fatalf("failed to insert future migration: %v", err)
+	}
+
+	if _, err := migrateDatabase(s.db, s.dialect); err == nil {
+		t.Error("expected an error when the database schema is newer than the build")
+	}
+}
+
+func TestDialectRebind(t *testing.T) {
+	query := `UPDATE todos SET title = ?, position = ? WHERE id = ?`
+	if got := dialectSQLite.rebind(query); got != query {
+		t.Errorf("sqlite rebind = %q, want unchanged", got)
+	}
+	want := `UPDATE todos SET title = $1, position = $2 WHERE id = $3`
+	if got := dialectPostgres.rebind(query); got != want {
+		t.Errorf("postgres rebind = %q, want %q", got, want)
+	}
+}
+
+func TestMigrationsExistForEveryDialect(t *testing.T) {
+	sqliteMigrations, err := loadMigrations(dialectSQLite)
+	if err != nil {
+		t.Fatalf("loadMigrations(sqlite): %v", err)
+	}
+	postgresMigrations, err := loadMigrations(dialectPostgres)
+	if err != nil {
+		t.Fatalf("loadMigrations(postgres): %v", err)
+	}
+
+	if len(sqliteMigrations) != len(postgresMigrations) {
+		t.Fatalf("sqlite has %d migrations but postgres has %d", len(sqliteMigrations), len(postgresMigrations))
+	}
+	for i := range sqliteMigrations {
+		if sqliteMigrations[i].Name != postgresMigrations[i].Name {
+			t.Errorf("migration %d differs between dialects: %s vs %s", i, sqliteMigrations[i].Name, postgresMigrations[i].Name)
+		}
+	}
+}
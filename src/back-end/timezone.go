@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	_ "time/tzdata" // The container image has no zoneinfo database
+)
+
+// Recurrence is evaluated in each definition's IANA time zone, so a daily
+// chore rolls over at local midnight and keeps its local due time across
+// daylight saving changes wherever the server runs. Definitions without a
+// time zone use DEFAULT_TIME_ZONE, or failing that the zone of their start
+// date, which is UTC once it has been through a SQL store.
+//
+// All-day items are due on a day rather than at a time: their due date is
+// midnight at the start of that day in the item's time zone and clients show
+// only the date.
+
+// defaultLocation is the time zone of definitions without one; nil to use
+// their start date's zone
+var defaultLocation *time.Location
+
+// locations caches loaded time zones by name
+var locations sync.Map
+
+// loadLocation returns the IANA time zone with the given name
+func loadLocation(name string) (*time.Location, error) {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// initDefaultLocation sets the time zone of definitions without one from the
+// DEFAULT_TIME_ZONE value name, which may be empty
+func initDefaultLocation(name string) error {
+	if name == "" {
+		return nil
+	}
+	loc, err := loadLocation(name)
+	if err != nil {
+		return fmt.Errorf("invalid DEFAULT_TIME_ZONE %q: %w", name, err)
+	}
+	defaultLocation = loc
+	return nil
+}
+
+// validateTimeZone validates a recurring definition's time zone
+func validateTimeZone(def *RecurringItemDefinition) error {
+	if def.TimeZone == "" {
+		return nil
+	}
+	if _, err := loadLocation(def.TimeZone); err != nil || def.TimeZone == "Local" {
+		return fmt.Errorf("timeZone must be an IANA time zone such as 'Europe/London'")
+	}
+	return nil
+}
+
+// location returns the time zone the definition's recurrence is evaluated in
+func (d *RecurringItemDefinition) location() *time.Location {
+	if d.TimeZone != "" {
+		if loc, err := loadLocation(d.TimeZone); err == nil {
+			return loc
+		}
+	}
+	if defaultLocation != nil {
+		return defaultLocation
+	}
+	return d.StartDate.Location()
+}
+
+// localStart returns the definition's start date in its time zone, moved to
+// the start of the day for all-day definitions
+func (d *RecurringItemDefinition) localStart() time.Time {
+	loc := d.location()
+	if d.AllDay {
+		return startOfDay(d.StartDate, loc)
+	}
+	return d.StartDate.In(loc)
+}
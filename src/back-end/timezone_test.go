@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecurrenceKeepsLocalTimeAcrossDST(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Fatalf("LoadLocation: %v", err)
+	}
+
+	// The start date comes back from a SQL store in UTC; the clocks go
+	// forward on 29 March 2026
+	start := time.Date(2026, 3, 27, 9, 0, 0, 0, london).UTC()
+	def := &RecurringItemDefinition{
+		Pattern:   RecurrencePattern{Frequency: "daily", Interval: 1},
+		StartDate: start,
+		CreatedAt: start,
+	}
+
+	got := def.occurrencesBetween(start, time.Time{}, 3)
+	if got[2].UTC().Hour() != 9 {
+		t.Fatalf("without a time zone, occurrences = %v, want 09:00 UTC", got)
+	}
+
+	def.TimeZone = "Europe/London"
+	for _, occurrence := range def.occurrencesBetween(start, time.Time{}, 3) {
+		if local := occurrence.In(london); local.Hour() != 9 {
+			t.Errorf("occurrence %v is at %v in London, want 09:00", occurrence, local)
+		}
+	}
+}
+
+func TestAllDayOccurrences(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("LoadLocation: %v", err)
+	}
+
+	// Created at 22:00 in New York, which is already the next day in UTC
+	start := time.Date(2026, 3, 6, 22, 0, 0, 0, newYork)
+	def := &RecurringItemDefinition{
+		Pattern:   RecurrencePattern{Frequency: "daily", Interval: 1},
+		StartDate: start.UTC(),
+		CreatedAt: start.UTC(),
+		TimeZone:  "America/New_York",
+		AllDay:    true,
+	}
+
+	want := []time.Time{
+		time.Date(2026, 3, 7, 0, 0, 0, 0, newYork),
+		time.Date(2026, 3, 8, 0, 0, 0, 0, newYork),
+		time.Date(2026, 3, 9, 0, 0, 0, 0, newYork), // After the clocks go forward
+	}
+	got := def.occurrencesBetween(start, time.Time{}, len(want))
+	if len(got) != len(want) {
+		t.Fatalf("occurrences = %v, want %v", got, want)
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Errorf("occurrence %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	todo := newRecurringInstance(def, got[0], 0)
+	if !todo.AllDay {
+		t.Error("instance of an all-day definition is not all-day")
+	}
+}
+
+func TestValidateTimeZone(t *testing.T) {
+	for zone, valid := range map[string]bool{
+		"":                 true,
+		"UTC":              true,
+		"Europe/London":    true,
+		"America/New_York": true,
+		"Local":            false,
+		"Mars/Olympus":     false,
+		"+01:00":           false,
+	} {
+		err := validateTimeZone(&RecurringItemDefinition{TimeZone: zone})
+		if (err == nil) != valid {
+			t.Errorf("validateTimeZone(%q) = %v, want valid %v", zone, err, valid)
+		}
+	}
+}
@@ -51,8 +51,15 @@ export default defineConfig({
   /* Run your local dev server before starting the tests */
   webServer: [
     {
-      command: "cd ../back-end && go run main.go",
+      command: "cd ../back-end && go run .",
       url: "http://localhost:8080/api/todos",
+      env: {
+        // Tests assert on the instances they create, so keep the
+        // background recurrence scheduler from adding more
+        RECURRENCE_SCHEDULER_INTERVAL: "0",
+        // Holidays the working-day tests avoid
+        HOLIDAY_CALENDAR_DIR: "../front-end/tests/holidays",
+      },
       reuseExistingServer: false, // Always restart to ensure clean state
       timeout: 120 * 1000,
       stderr: "pipe",
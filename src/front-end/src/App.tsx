@@ -2,12 +2,79 @@ import { useState, useEffect } from 'react'
 import { DragDropContext, Droppable, Draggable, DropResult } from '@hello-pangea/dnd'
 import axios from 'axios'
 import './App.css'
-import type { TodoItem, RecurringItemDefinition, FormData, NewRowData, ReorderItem } from './types'
+import type { TodoItem, RecurringItemDefinition, RecurrencePattern, FormData, NewRowData, ReorderItem, EditScope, RecurringHistory, HolidayCalendar } from './types'
 import { useAuth } from './AuthProvider'
 import LoginPage from './LoginPage'
 
 const API_BASE = '/api'
 
+type PatternFormFields = Pick<FormData, 'frequency' | 'interval' | 'daysOfWeek' | 'rrule' | 'monthlyMode' | 'dayOfMonth' | 'weekOfMonth' | 'dayOfWeek' | 'activeMonths' | 'repeatAfterCompletion' | 'endDate' | 'maxOccurrences' | 'rotation' | 'missedPolicy' | 'holidayRegion' | 'holidayRule'>
+
+const DEFAULT_PATTERN_FIELDS: PatternFormFields = {
+  frequency: 'daily',
+  interval: '1',
+  daysOfWeek: [],
+  rrule: '',
+  monthlyMode: 'startDay',
+  dayOfMonth: '1',
+  weekOfMonth: '1',
+  dayOfWeek: 'Monday',
+  activeMonths: [],
+  repeatAfterCompletion: false,
+  endDate: '',
+  maxOccurrences: '',
+  rotation: '',
+  missedPolicy: '',
+  holidayRegion: '',
+  holidayRule: '',
+}
+
+const MONTH_NAMES = ['January', 'February', 'March', 'April', 'May', 'June', 'July', 'August', 'September', 'October', 'November', 'December']
+
+// Format an occurrence for the pattern preview, e.g. "Mon 3rd", adding the
+// month when it is not the current one
+const formatOccurrence = (value: string): string => {
+  const date = new Date(value)
+  const day = date.getDate()
+  const suffix = day % 10 === 1 && day !== 11 ? 'st' : day % 10 === 2 && day !== 12 ? 'nd' : day % 10 === 3 && day !== 13 ? 'rd' : 'th'
+  const weekday = date.toLocaleDateString(undefined, { weekday: 'short' })
+  const now = new Date()
+  const sameMonth = date.getMonth() === now.getMonth() && date.getFullYear() === now.getFullYear()
+  const month = sameMonth ? '' : ` ${date.toLocaleDateString(undefined, { month: 'short' })}`
+  return `${weekday} ${day}${suffix}${month}`
+}
+
+// The browser's IANA time zone, which new recurring items are evaluated in
+const TIME_ZONE = Intl.DateTimeFormat().resolvedOptions().timeZone
+
+// Convert a YYYY-MM-DD date input into an all-day due date: midnight at the
+// start of that day in the browser's time zone
+const toAllDayDueDate = (value: string): string => new Date(`${value}T00:00:00`).toISOString()
+
+// Convert a recurrence pattern from the API into form fields
+const patternToFormFields = (pattern: RecurrencePattern): PatternFormFields => {
+  const fields: PatternFormFields = {
+    ...DEFAULT_PATTERN_FIELDS,
+    frequency: pattern.rrule ? 'custom' : pattern.frequency || 'daily',
+    interval: String(pattern.interval || 1),
+    daysOfWeek: pattern.daysOfWeek || [],
+    rrule: pattern.rrule || '',
+    activeMonths: pattern.activeMonths || [],
+    repeatAfterCompletion: pattern.mode === 'afterCompletion',
+  }
+  if (pattern.weekOfMonth && pattern.dayOfWeek) {
+    fields.monthlyMode = 'weekday'
+    fields.weekOfMonth = String(pattern.weekOfMonth)
+    fields.dayOfWeek = pattern.dayOfWeek
+  } else if (pattern.dayOfMonth === -1) {
+    fields.monthlyMode = 'lastDay'
+  } else if (pattern.dayOfMonth) {
+    fields.monthlyMode = 'dayOfMonth'
+    fields.dayOfMonth = String(pattern.dayOfMonth)
+  }
+  return fields
+}
+
 function App() {
   const { isAuthenticated, user, logout, getAccessToken } = useAuth()
   const [todos, setTodos] = useState<TodoItem[]>([])
@@ -16,11 +83,14 @@ function App() {
   const [editingId, setEditingId] = useState<number | null>(null)
   const [editingRecurringDefId, setEditingRecurringDefId] = useState<number | null>(null)
   const [originallyRecurring, setOriginallyRecurring] = useState<boolean>(false) // Track if item was originally recurring
+  const [editScope, setEditScope] = useState<EditScope>('all') // Which occurrences a definition edit applies to
+  const [editScopeDate, setEditScopeDate] = useState<string | null>(null) // Due date of the occurrence the edit was started from
   const [inlineEditingId, setInlineEditingId] = useState<number | null>(null)
   const [inlineEditField, setInlineEditField] = useState<'title' | 'description' | 'assignedTo' | 'dueDate' | null>(null)
   const [inlineAssignees, setInlineAssignees] = useState<string[]>([]) // Track assignees during inline editing
   const [inlineAssigneeInput, setInlineAssigneeInput] = useState<string>('') // Input for new assignee
   const [allowedUsers, setAllowedUsers] = useState<string[]>([]) // List of allowed users for autocompletion
+  const [holidayCalendars, setHolidayCalendars] = useState<HolidayCalendar[]>([]) // Regions recurring items can avoid the holidays of
   const [showFormAutocomplete, setShowFormAutocomplete] = useState<boolean>(false)
   const [showInlineAutocomplete, setShowInlineAutocomplete] = useState<boolean>(false)
   const [showQuickAddAutocomplete, setShowQuickAddAutocomplete] = useState<boolean>(false)
@@ -29,15 +99,15 @@ function App() {
     description: '',
     assignedTo: '',
   })
+  const [preview, setPreview] = useState<{ occurrences: string[]; error?: string } | null>(null) // Next dates of the pattern being edited
+  const [history, setHistory] = useState<RecurringHistory | null>(null) // Streaks of the recurring item being viewed
   const [formData, setFormData] = useState<FormData>({
     title: '',
     description: '',
     assignedTo: [],
     currentAssignee: '',
     isRecurring: false,
-    frequency: 'daily',
-    interval: '1',
-    daysOfWeek: [],
+    ...DEFAULT_PATTERN_FIELDS,
     dueDate: '',
   })
 
@@ -67,6 +137,7 @@ function App() {
       loadTodos()
       loadRecurringDefs()
       loadAllowedUsers()
+      loadHolidayCalendars()
     }
   }, [isAuthenticated])
 
@@ -107,6 +178,15 @@ function App() {
     }
   }
 
+  const loadHolidayCalendars = async (): Promise<void> => {
+    try {
+      const response = await axios.get<HolidayCalendar[]>(`${API_BASE}/holidays`)
+      setHolidayCalendars(response.data || [])
+    } catch (error) {
+      console.error('Error loading holiday calendars:', error)
+    }
+  }
+
   // Helper function to get filtered autocomplete suggestions
   const getAutocompleteSuggestions = (input: string, existingAssignees: string[] = []): string[] => {
     if (!input.trim()) return []
@@ -116,21 +196,125 @@ function App() {
     )
   }
 
+  // Build the recurrence pattern sent to the API from the form
+  const buildPattern = (): RecurrencePattern => {
+    if (formData.frequency === 'custom') {
+      return { rrule: formData.rrule.trim() }
+    }
+    if (formData.repeatAfterCompletion) {
+      return {
+        frequency: formData.frequency,
+        interval: parseInt(formData.interval) || 1,
+        mode: 'afterCompletion',
+      }
+    }
+    const pattern: RecurrencePattern = {
+      frequency: formData.frequency,
+      interval: parseInt(formData.interval) || 1,
+      daysOfWeek: formData.daysOfWeek,
+    }
+    if (formData.frequency === 'monthly') {
+      switch (formData.monthlyMode) {
+        case 'dayOfMonth':
+          pattern.dayOfMonth = parseInt(formData.dayOfMonth) || 1
+          break
+        case 'lastDay':
+          pattern.dayOfMonth = -1
+          break
+        case 'weekday':
+          pattern.weekOfMonth = parseInt(formData.weekOfMonth) || 1
+          pattern.dayOfWeek = formData.dayOfWeek
+          break
+      }
+    }
+    if (formData.frequency !== 'yearly' && formData.activeMonths.length > 0) {
+      pattern.activeMonths = [...formData.activeMonths].sort((a, b) => a - b)
+    }
+    return pattern
+  }
+
+  // Whether todo is the last occurrence of a recurring definition that has
+  // reached its end date or occurrence count
+  const isFinalInstance = (todo: TodoItem): boolean => {
+    const recDef = recurringDefs.find(d => d.id === todo.recurrenceId)
+    if (!recDef?.finished || !todo.dueDate) return false
+    const dueDate = new Date(todo.dueDate)
+    return !todos.some(other =>
+      other.recurrenceId === todo.recurrenceId && other.dueDate && new Date(other.dueDate) > dueDate
+    )
+  }
+
+  // Build the optional end conditions sent with a recurring definition
+  const buildEndConditions = (): { endDate?: string; maxOccurrences?: number } => ({
+    // The end date is inclusive, so the series runs until the end of that day
+    endDate: formData.endDate ? new Date(`${formData.endDate}T23:59:59`).toISOString() : undefined,
+    maxOccurrences: formData.repeatAfterCompletion ? undefined : parseInt(formData.maxOccurrences) || undefined,
+  })
+
+  // Build the optional assignee rotation and missed policy sent with a
+  // recurring definition
+  const buildRotation = (): { rotation?: { mode: string } } =>
+    formData.rotation && formData.assignedTo.length > 1 ? { rotation: { mode: formData.rotation } } : {}
+  const buildMissedPolicy = (): { missedPolicy?: string } =>
+    formData.missedPolicy && !formData.repeatAfterCompletion ? { missedPolicy: formData.missedPolicy } : {}
+
+  // Build the optional holiday calendar and rule sent with a recurring
+  // definition
+  const buildHolidayRule = (): { holidayRegion?: string; holidayRule?: string } =>
+    formData.holidayRegion
+      ? { holidayRegion: formData.holidayRegion, ...(formData.holidayRule ? { holidayRule: formData.holidayRule } : {}) }
+      : {}
+
+  // Preview the next occurrences of the pattern in the form, so mistakes show
+  // before it is saved. The key changes whenever the pattern does.
+  const showPreview = formData.isRecurring && (editingRecurringDefId !== null || !editingId || !originallyRecurring)
+  const previewKey = showPreview ? JSON.stringify({ pattern: buildPattern(), ...buildEndConditions(), ...buildHolidayRule() }) : ''
+  useEffect(() => {
+    if (!previewKey) {
+      setPreview(null)
+      return
+    }
+
+    // Edits count occurrences from the existing definition's start
+    const recDef = recurringDefs.find(d => d.id === editingRecurringDefId)
+    const timer = setTimeout(async () => {
+      try {
+        const response = await axios.post<{ occurrences: string[] }>(`${API_BASE}/recurring/preview`, {
+          ...JSON.parse(previewKey),
+          startDate: recDef?.startDate,
+          createdAt: recDef?.createdAt,
+        })
+        setPreview({ occurrences: response.data.occurrences })
+      } catch (error) {
+        const message = axios.isAxiosError(error) && typeof error.response?.data === 'string'
+          ? error.response.data.trim()
+          : 'Could not preview this pattern'
+        setPreview({ occurrences: [], error: message })
+      }
+    }, 300)
+    return () => clearTimeout(timer)
+  }, [previewKey, editingRecurringDefId])
+
   const handleSubmit = async (e: React.FormEvent<HTMLFormElement>): Promise<void> => {
     e.preventDefault()
 
     try {
       if (editingRecurringDefId) {
-        // Update recurring definition
+        // Update recurring definition, keeping the time zone it was created in
+        const recDef = recurringDefs.find(d => d.id === editingRecurringDefId)
         await axios.put(`${API_BASE}/recurring/${editingRecurringDefId}`, {
           title: formData.title,
           description: formData.description,
           assignedTo: formData.assignedTo,
-          pattern: {
-            frequency: formData.frequency,
-            interval: parseInt(formData.interval) || 1,
-            daysOfWeek: formData.daysOfWeek,
-          },
+          pattern: buildPattern(),
+          ...buildEndConditions(),
+          ...buildRotation(),
+          ...buildMissedPolicy(),
+          ...buildHolidayRule(),
+          timeZone: recDef?.timeZone,
+          allDay: recDef?.allDay,
+          scope: editScope,
+          ...(editScope !== 'all' && editScopeDate ? { date: editScopeDate } : {}),
         })
         await loadRecurringDefs()
         await loadTodos() // Reload todos as they may be affected
@@ -142,11 +326,8 @@ function App() {
           // Convert to recurring
           await axios.post(`${API_BASE}/todos/${editingId}/convert-recurring`, {
             toRecurring: true,
-            pattern: {
-              frequency: formData.frequency,
-              interval: parseInt(formData.interval) || 1,
-              daysOfWeek: formData.daysOfWeek,
-            },
+            pattern: buildPattern(),
+            timeZone: TIME_ZONE,
           })
         } else if (!formData.isRecurring && currentTodo?.isRecurring) {
           // Convert from recurring to one-off
@@ -160,7 +341,8 @@ function App() {
               description: formData.description,
               assignedTo: formData.assignedTo,
               completed: false,
-              dueDate: new Date(formData.dueDate).toISOString(),
+              dueDate: toAllDayDueDate(formData.dueDate),
+              allDay: true,
             })
           }
         } else {
@@ -170,7 +352,8 @@ function App() {
             description: formData.description,
             assignedTo: formData.assignedTo,
             completed: false,
-            dueDate: formData.dueDate ? new Date(formData.dueDate).toISOString() : null,
+            dueDate: formData.dueDate ? toAllDayDueDate(formData.dueDate) : null,
+            allDay: !!formData.dueDate,
           })
         }
       } else if (formData.isRecurring) {
@@ -179,12 +362,13 @@ function App() {
           title: formData.title,
           description: formData.description,
           assignedTo: formData.assignedTo,
-          pattern: {
-            frequency: formData.frequency,
-            interval: parseInt(formData.interval) || 1,
-            daysOfWeek: formData.daysOfWeek,
-          },
+          pattern: buildPattern(),
           startDate: new Date().toISOString(),
+          timeZone: TIME_ZONE,
+          ...buildEndConditions(),
+          ...buildRotation(),
+          ...buildMissedPolicy(),
+          ...buildHolidayRule(),
         })
         await loadRecurringDefs()
       } else {
@@ -194,7 +378,8 @@ function App() {
           description: formData.description,
           assignedTo: formData.assignedTo,
           completed: false,
-          dueDate: formData.dueDate ? new Date(formData.dueDate).toISOString() : null,
+          dueDate: formData.dueDate ? toAllDayDueDate(formData.dueDate) : null,
+          allDay: !!formData.dueDate,
         })
       }
 
@@ -212,15 +397,15 @@ function App() {
       assignedTo: [],
       currentAssignee: '',
       isRecurring: false,
-      frequency: 'daily',
-      interval: '1',
-      daysOfWeek: [],
+      ...DEFAULT_PATTERN_FIELDS,
       dueDate: '',
     })
     setIsAdding(false)
     setEditingId(null)
     setEditingRecurringDefId(null)
     setOriginallyRecurring(false)
+    setEditScope('all')
+    setEditScopeDate(null)
   }
 
   // Day name to weekday number mapping for recurring patterns
@@ -236,15 +421,25 @@ function App() {
   const INLINE_EDIT_BLUR_DELAY = 200 // milliseconds
 
   // Calculate next occurrence date for a recurring item (used for validation)
-  const calculateNextInstanceDate = (currentDueDate: string, pattern: { frequency: string; interval: number; daysOfWeek?: string[] }): Date | null => {
-    if (!currentDueDate) return null
+  const calculateNextInstanceDate = (currentDueDate: string, pattern: RecurrencePattern): Date | null => {
+    // RRULE, nth weekday, seasonal and after-completion patterns are only
+    // evaluated by the backend
+    if (!currentDueDate || pattern.rrule || pattern.weekOfMonth || pattern.activeMonths?.length || pattern.mode) return null
+    const interval = pattern.interval || 1
 
     const current = new Date(currentDueDate)
     let nextDate = new Date(current)
 
     switch (pattern.frequency) {
       case 'daily':
-        nextDate.setDate(current.getDate() + pattern.interval)
+        nextDate.setDate(current.getDate() + interval)
+        break
+      case 'weekdays':
+        // Count weekdays, stepping over weekends
+        for (let remaining = interval; remaining > 0;) {
+          nextDate.setDate(nextDate.getDate() + 1)
+          if (nextDate.getDay() !== 0 && nextDate.getDay() !== 6) remaining--
+        }
         break
       case 'weekly':
         if (pattern.daysOfWeek && pattern.daysOfWeek.length > 0) {
@@ -261,12 +456,26 @@ function App() {
             }
           }
         } else {
-          nextDate.setDate(current.getDate() + (7 * pattern.interval))
+          nextDate.setDate(current.getDate() + (7 * interval))
         }
         break
-      case 'monthly':
-        nextDate.setMonth(current.getMonth() + pattern.interval)
+      case 'monthly': {
+        // Clamp to the last day of shorter months rather than overflowing
+        const day = pattern.dayOfMonth === -1 ? 31 : pattern.dayOfMonth || current.getDate()
+        nextDate.setDate(1)
+        nextDate.setMonth(current.getMonth() + interval)
+        const daysInMonth = new Date(nextDate.getFullYear(), nextDate.getMonth() + 1, 0).getDate()
+        nextDate.setDate(Math.min(day, daysInMonth))
+        break
+      }
+      case 'yearly': {
+        // 29 February falls on the 28th in other years
+        nextDate.setDate(1)
+        nextDate.setFullYear(current.getFullYear() + interval)
+        const daysInMonth = new Date(nextDate.getFullYear(), nextDate.getMonth() + 1, 0).getDate()
+        nextDate.setDate(Math.min(current.getDate(), daysInMonth))
         break
+      }
     }
 
     return nextDate
@@ -277,17 +486,13 @@ function App() {
     setOriginallyRecurring(todo.isRecurring || false)
 
     // If editing a recurring item, fetch its definition to get pattern details
-    let frequency: 'daily' | 'weekly' | 'monthly' = 'daily'
-    let interval: string = '1'
-    let daysOfWeek: string[] = []
+    let patternFields: PatternFormFields = DEFAULT_PATTERN_FIELDS
 
     if (todo.isRecurring && todo.recurrenceId) {
       try {
         const recDef = recurringDefs.find(def => def.id === todo.recurrenceId)
         if (recDef?.pattern) {
-          frequency = recDef.pattern.frequency || 'daily'
-          interval = String(recDef.pattern.interval || 1)
-          daysOfWeek = recDef.pattern.daysOfWeek || []
+          patternFields = patternToFormFields(recDef.pattern)
         }
       } catch (error) {
         console.error('Error loading recurrence pattern:', error)
@@ -312,9 +517,7 @@ function App() {
       assignedTo: Array.isArray(todo.assignedTo) ? [...todo.assignedTo] : [],
       currentAssignee: '',
       isRecurring: todo.isRecurring || false,
-      frequency: frequency,
-      interval: interval,
-      daysOfWeek: daysOfWeek,
+      ...patternFields,
       dueDate: dueDate,
     })
     setEditingId(todo.id)
@@ -335,12 +538,18 @@ function App() {
         assignedTo: Array.isArray(recDef.assignedTo) ? [...recDef.assignedTo] : [],
         currentAssignee: '',
         isRecurring: true,
-        frequency: recDef.pattern.frequency || 'daily',
-        interval: String(recDef.pattern.interval || 1),
-        daysOfWeek: recDef.pattern.daysOfWeek || [],
+        ...patternToFormFields(recDef.pattern),
+        endDate: recDef.endDate ? recDef.endDate.split('T')[0] : '',
+        maxOccurrences: recDef.maxOccurrences ? String(recDef.maxOccurrences) : '',
+        rotation: recDef.rotation?.mode ?? '',
+        missedPolicy: recDef.missedPolicy ?? '',
+        holidayRegion: recDef.holidayRegion ?? '',
+        holidayRule: recDef.holidayRule ?? '',
         dueDate: '',
       })
       setEditingRecurringDefId(todo.recurrenceId) // Store the recurring def ID
+      setEditScope('all')
+      setEditScopeDate(todo.dueDate ?? null)
       setEditingId(null) // Clear todo editing ID
       setIsAdding(true)
     } catch (error) {
@@ -397,12 +606,13 @@ function App() {
       // Convert and validate date string to ISO format if it's a due date
       if (field === 'dueDate' && typeof newValue === 'string') {
         if (newValue) {
-          const date = new Date(newValue)
+          const date = new Date(`${newValue}T00:00:00`)
           if (isNaN(date.getTime())) {
             console.error('Invalid date:', newValue)
             return // Don't save invalid dates
           }
           updatedTodo.dueDate = date.toISOString()
+          updatedTodo.allDay = true
         } else {
           updatedTodo.dueDate = undefined // Clear the due date if empty
         }
@@ -434,6 +644,50 @@ function App() {
     }
   }
 
+  // Skip one occurrence of a recurring item; it is kept as a skipped record
+  // and the next occurrence is created
+  const handleSkipOccurrence = async (todo: TodoItem): Promise<void> => {
+    if (!todo.recurrenceId || !todo.dueDate) return
+
+    try {
+      await axios.post(`${API_BASE}/recurring/${todo.recurrenceId}/exceptions`, {
+        date: todo.dueDate,
+        action: 'skip',
+      })
+      await loadTodos()
+      await loadRecurringDefs()
+    } catch (error) {
+      console.error('Error skipping occurrence:', error)
+    }
+  }
+
+  // Pause or resume the recurring definition behind todo
+  const handleTogglePaused = async (todo: TodoItem): Promise<void> => {
+    const recDef = recurringDefs.find(d => d.id === todo.recurrenceId)
+    if (!recDef) return
+
+    try {
+      await axios.post(`${API_BASE}/recurring/${recDef.id}/${recDef.paused ? 'resume' : 'pause'}`)
+      await loadTodos()
+      await loadRecurringDefs()
+    } catch (error) {
+      console.error('Error pausing recurring item:', error)
+    }
+  }
+
+  // Show the completion history and streaks of the recurring item behind todo
+  const handleShowHistory = async (todo: TodoItem): Promise<void> => {
+    try {
+      const response = await axios.get<RecurringHistory>(`${API_BASE}/recurring/${todo.recurrenceId}/history`)
+      setHistory(response.data)
+    } catch (error) {
+      console.error('Error loading history:', error)
+    }
+  }
+
+  const isPaused = (todo: TodoItem): boolean =>
+    !!recurringDefs.find(d => d.id === todo.recurrenceId)?.paused
+
   const handleToggleComplete = async (todo: TodoItem): Promise<void> => {
     try {
       await axios.put(`${API_BASE}/todos/${todo.id}`, {
@@ -623,26 +877,41 @@ function App() {
               {formData.isRecurring && (editingRecurringDefId || !editingId || !originallyRecurring) && (
                 <div className="recurring-options">
                   <select
+                    aria-label="Frequency"
                     value={formData.frequency}
-                    onChange={(e) => setFormData({ ...formData, frequency: e.target.value as 'daily' | 'weekly' | 'monthly' })}
+                    onChange={(e) => setFormData({ ...formData, frequency: e.target.value as FormData['frequency'] })}
                     className="input"
                   >
                     <option value="daily">Daily</option>
+                    <option value="weekdays">Every weekday</option>
                     <option value="weekly">Weekly</option>
                     <option value="monthly">Monthly</option>
+                    <option value="yearly">Yearly</option>
+                    <option value="custom">Custom (RRULE)</option>
                   </select>
-                  <input
-                    type="number"
-                    min="1"
-                    placeholder="Interval"
-                    value={formData.interval}
-                    onChange={(e) => setFormData({ ...formData, interval: e.target.value })}
-                    className="input"
-                  />
+                  {formData.frequency === 'custom' ? (
+                    <input
+                      type="text"
+                      placeholder="e.g. FREQ=MONTHLY;BYDAY=-1FR"
+                      value={formData.rrule}
+                      onChange={(e) => setFormData({ ...formData, rrule: e.target.value })}
+                      className="input"
+                      required
+                    />
+                  ) : (
+                    <input
+                      type="number"
+                      min="1"
+                      placeholder="Interval"
+                      value={formData.interval}
+                      onChange={(e) => setFormData({ ...formData, interval: e.target.value })}
+                      className="input"
+                    />
+                  )}
                 </div>
               )}
 
-              {formData.isRecurring && (editingRecurringDefId || !editingId || !originallyRecurring) && formData.frequency === 'weekly' && (
+              {formData.isRecurring && (editingRecurringDefId || !editingId || !originallyRecurring) && formData.frequency === 'weekly' && !formData.repeatAfterCompletion && (
                 <div className="days-of-week">
                   <p className="days-label">Select days:</p>
                   <div className="day-checkboxes">
@@ -666,6 +935,223 @@ function App() {
                 </div>
               )}
 
+              {formData.isRecurring && (editingRecurringDefId || !editingId || !originallyRecurring) && formData.frequency === 'monthly' && !formData.repeatAfterCompletion && (
+                <div className="monthly-options">
+                  <select
+                    aria-label="Day of month"
+                    value={formData.monthlyMode}
+                    onChange={(e) => setFormData({ ...formData, monthlyMode: e.target.value as FormData['monthlyMode'] })}
+                    className="input"
+                  >
+                    <option value="startDay">Same day as start date</option>
+                    <option value="dayOfMonth">On day</option>
+                    <option value="lastDay">Last day of month</option>
+                    <option value="weekday">On the nth weekday</option>
+                  </select>
+                  {formData.monthlyMode === 'dayOfMonth' && (
+                    <input
+                      type="number"
+                      min="1"
+                      max="31"
+                      aria-label="Day"
+                      value={formData.dayOfMonth}
+                      onChange={(e) => setFormData({ ...formData, dayOfMonth: e.target.value })}
+                      className="input"
+                    />
+                  )}
+                  {formData.monthlyMode === 'weekday' && (
+                    <>
+                      <select
+                        aria-label="Week of month"
+                        value={formData.weekOfMonth}
+                        onChange={(e) => setFormData({ ...formData, weekOfMonth: e.target.value })}
+                        className="input"
+                      >
+                        <option value="1">First</option>
+                        <option value="2">Second</option>
+                        <option value="3">Third</option>
+                        <option value="4">Fourth</option>
+                        <option value="5">Fifth</option>
+                        <option value="-1">Last</option>
+                      </select>
+                      <select
+                        aria-label="Weekday"
+                        value={formData.dayOfWeek}
+                        onChange={(e) => setFormData({ ...formData, dayOfWeek: e.target.value })}
+                        className="input"
+                      >
+                        {['Monday', 'Tuesday', 'Wednesday', 'Thursday', 'Friday', 'Saturday', 'Sunday'].map((day) => (
+                          <option key={day} value={day}>{day}</option>
+                        ))}
+                      </select>
+                    </>
+                  )}
+                  {(formData.monthlyMode === 'startDay' || formData.monthlyMode === 'dayOfMonth') && (
+                    <small className="monthly-hint">Shorter months use their last day</small>
+                  )}
+                </div>
+              )}
+
+              {formData.isRecurring && (editingRecurringDefId || !editingId || !originallyRecurring) && ['daily', 'weekdays', 'weekly', 'monthly'].includes(formData.frequency) && !formData.repeatAfterCompletion && (
+                <div className="active-months">
+                  <p className="days-label">Active months (leave empty for all year):</p>
+                  <div className="month-checkboxes">
+                    {MONTH_NAMES.map((name, index) => (
+                      <label key={name} className="day-checkbox-label">
+                        <input
+                          type="checkbox"
+                          aria-label={`Active in ${name}`}
+                          checked={formData.activeMonths.includes(index + 1)}
+                          onChange={(e) => {
+                            if (e.target.checked) {
+                              setFormData({ ...formData, activeMonths: [...formData.activeMonths, index + 1] })
+                            } else {
+                              setFormData({ ...formData, activeMonths: formData.activeMonths.filter(m => m !== index + 1) })
+                            }
+                          }}
+                        />
+                        {name.substring(0, 3)}
+                      </label>
+                    ))}
+                  </div>
+                </div>
+              )}
+
+              {formData.isRecurring && (editingRecurringDefId || !editingId || !originallyRecurring) && formData.frequency !== 'custom' && (
+                <div className="repeat-mode">
+                  <label className="checkbox-label">
+                    <input
+                      type="checkbox"
+                      checked={formData.repeatAfterCompletion}
+                      onChange={(e) => setFormData({ ...formData, repeatAfterCompletion: e.target.checked })}
+                    />
+                    Repeat after completion
+                  </label>
+                  <small className="monthly-hint">The next one is due an interval after this one is done</small>
+                </div>
+              )}
+
+              {formData.isRecurring && (editingRecurringDefId || !editingId) && formData.assignedTo.length > 1 && (
+                <label className="input-label rotation">
+                  Assign each occurrence to:
+                  <select
+                    aria-label="Rotation"
+                    value={formData.rotation}
+                    onChange={(e) => setFormData({ ...formData, rotation: e.target.value as FormData['rotation'] })}
+                    className="input"
+                  >
+                    <option value="">Everyone</option>
+                    <option value="roundRobin">One person, taking turns</option>
+                    <option value="leastRecent">Whoever did it least recently</option>
+                  </select>
+                </label>
+              )}
+
+              {formData.isRecurring && (editingRecurringDefId || !editingId) && !formData.repeatAfterCompletion && (
+                <label className="input-label missed-policy">
+                  If an occurrence is missed:
+                  <select
+                    aria-label="Missed occurrences"
+                    value={formData.missedPolicy}
+                    onChange={(e) => setFormData({ ...formData, missedPolicy: e.target.value as FormData['missedPolicy'] })}
+                    className="input"
+                  >
+                    <option value="">Leave it and mark it missed</option>
+                    <option value="collapse">Keep one overdue item</option>
+                    <option value="catchUp">Keep every missed item</option>
+                    <option value="skip">Record it as missed and move on</option>
+                  </select>
+                </label>
+              )}
+
+              {formData.isRecurring && (editingRecurringDefId || !editingId) && holidayCalendars.length > 0 && (
+                <div className="holiday-options">
+                  <label className="input-label">
+                    Holidays:
+                    <select
+                      aria-label="Holiday calendar"
+                      value={formData.holidayRegion}
+                      onChange={(e) => setFormData({ ...formData, holidayRegion: e.target.value })}
+                      className="input"
+                    >
+                      <option value="">Ignore holidays</option>
+                      {holidayCalendars.map((calendar) => (
+                        <option key={calendar.region} value={calendar.region}>{calendar.region}</option>
+                      ))}
+                    </select>
+                  </label>
+                  {formData.holidayRegion && (
+                    <select
+                      aria-label="On a holiday"
+                      value={formData.holidayRule}
+                      onChange={(e) => setFormData({ ...formData, holidayRule: e.target.value as FormData['holidayRule'] })}
+                      className="input"
+                    >
+                      <option value="">Move to the next working day</option>
+                      <option value="skip">Skip it</option>
+                    </select>
+                  )}
+                </div>
+              )}
+
+              {formData.isRecurring && (editingRecurringDefId || !editingId) && (
+                <div className="end-conditions">
+                  <label className="input-label">
+                    Ends on (optional):
+                    <input
+                      type="date"
+                      aria-label="End date"
+                      value={formData.endDate}
+                      onChange={(e) => setFormData({ ...formData, endDate: e.target.value })}
+                      className="input"
+                    />
+                  </label>
+                  {!formData.repeatAfterCompletion && (
+                    <label className="input-label">
+                      Or after
+                      <input
+                        type="number"
+                        min="1"
+                        aria-label="Occurrences"
+                        placeholder="∞"
+                        value={formData.maxOccurrences}
+                        onChange={(e) => setFormData({ ...formData, maxOccurrences: e.target.value })}
+                        className="input"
+                      />
+                      occurrences
+                    </label>
+                  )}
+                </div>
+              )}
+
+              {editingRecurringDefId && editScopeDate && (
+                <label className="input-label edit-scope">
+                  Apply changes to:
+                  <select
+                    aria-label="Apply changes to"
+                    value={editScope}
+                    onChange={(e) => setEditScope(e.target.value as EditScope)}
+                    className="input"
+                  >
+                    <option value="all">All occurrences</option>
+                    <option value="following">This and following occurrences</option>
+                    <option value="this">This occurrence only</option>
+                  </select>
+                </label>
+              )}
+
+              {showPreview && preview && (
+                <div className="recurrence-preview" aria-live="polite">
+                  {preview.error ? (
+                    <span className="recurrence-preview-error">{preview.error}</span>
+                  ) : preview.occurrences.length > 0 ? (
+                    <>Next: {preview.occurrences.map(formatOccurrence).join(', ')}</>
+                  ) : (
+                    <>No upcoming occurrences</>
+                  )}
+                </div>
+              )}
+
               {/* Show due date field for non-recurring items OR when editing a recurring instance */}
               {(!formData.isRecurring || (editingId !== null && formData.isRecurring && !editingRecurringDefId)) && (
                 <div className="due-date-section">
@@ -683,20 +1169,18 @@ function App() {
                         const todo = todos.find(t => t.id === editingId)
                         if (todo?.recurrenceId) {
                           const recDef = recurringDefs.find(d => d.id === todo.recurrenceId)
-                          if (recDef?.pattern && todo.dueDate) {
+                          // Holidays can move the next instance later, so
+                          // only the backend knows when it is due
+                          if (recDef?.pattern && todo.dueDate && !recDef.holidayRegion) {
                             const nextInstance = calculateNextInstanceDate(
                               todo.dueDate,
-                              {
-                                frequency: recDef.pattern.frequency,
-                                interval: recDef.pattern.interval,
-                                daysOfWeek: recDef.pattern.daysOfWeek
-                              }
+                              recDef.pattern
                             )
                             if (nextInstance) {
                               // Set max to one day before next instance
                               const maxDate = new Date(nextInstance)
                               maxDate.setDate(maxDate.getDate() - 1)
-                              return maxDate.toISOString().split('T')[0]
+                              return maxDate.toLocaleDateString('en-CA')
                             }
                           }
                         }
@@ -737,6 +1221,28 @@ function App() {
           )}
         </div>
 
+        {history && (
+          <div className="recurring-history" aria-label="History">
+            <strong>{history.instances[0]?.title ?? 'History'}</strong>
+            <span>Current streak: {history.currentStreak}</span>
+            <span>Longest streak: {history.longestStreak}</span>
+            <span>
+              On time: {history.onTimeRate !== undefined ? `${Math.round(history.onTimeRate * 100)}%` : '–'}
+            </span>
+            <span>
+              {history.completed} done, {history.skipped} skipped, {history.missed} missed
+            </span>
+            {history.completedBy && (
+              <span>
+                {Object.entries(history.completedBy).map(([who, count]) => `${who}: ${count}`).join(', ')}
+              </span>
+            )}
+            <button type="button" onClick={() => setHistory(null)} className="btn btn-icon" title="Close history">
+              ✖️
+            </button>
+          </div>
+        )}
+
         <table className="todo-table">
           <thead>
             <tr>
@@ -937,7 +1443,7 @@ function App() {
                             {inlineEditingId === todo.id && inlineEditField === 'dueDate' ? (
                               <input
                                 type="date"
-                                defaultValue={todo.dueDate ? new Date(todo.dueDate).toISOString().split('T')[0] : ''}
+                                defaultValue={todo.dueDate ? new Date(todo.dueDate).toLocaleDateString('en-CA') : ''}
                                 onBlur={(e) => {
                                   handleInlineEditSave(todo, 'dueDate', e.target.value)
                                 }}
@@ -958,8 +1464,22 @@ function App() {
                                 style={{ cursor: todo.completed ? 'default' : 'pointer' }}
                               >
                                 {todo.dueDate ? (
-                                  <span className="due-date">
+                                  <span
+                                    className="due-date"
+                                    title={todo.originalDueDate ? `Moved from ${new Date(todo.originalDueDate).toLocaleDateString()}` : undefined}
+                                  >
+                                    {todo.skipped && <span className="skipped-label">Skipped </span>}
+                                    {todo.missed && <span className="missed-label">Missed </span>}
+                                    {!!todo.missedOccurrences && (
+                                      <span className="missed-label" title="Earlier missed occurrences folded into this one">
+                                        +{todo.missedOccurrences} missed{' '}
+                                      </span>
+                                    )}
+                                    {isPaused(todo) && <span className="paused-label">Paused </span>}
                                     {todo.isRecurring && <span className="recurring-badge">🔄 </span>}
+                                    {isFinalInstance(todo) && (
+                                      <span className="series-ended" title="Last occurrence of this recurring item">Last </span>
+                                    )}
                                     📅 {new Date(todo.dueDate).toLocaleDateString()}
                                   </span>
                                 ) : (
@@ -980,6 +1500,33 @@ function App() {
                             >
                               ✏️
                             </button>
+                            {todo.isRecurring && todo.recurrenceId && todo.dueDate && !todo.completed && (
+                              <button
+                                onClick={() => handleSkipOccurrence(todo)}
+                                className="btn btn-icon"
+                                title="Skip this occurrence"
+                              >
+                                ⏭️
+                              </button>
+                            )}
+                            {todo.isRecurring && todo.recurrenceId && (
+                              <button
+                                onClick={() => handleTogglePaused(todo)}
+                                className="btn btn-icon"
+                                title={isPaused(todo) ? 'Resume recurring item' : 'Pause recurring item'}
+                              >
+                                {isPaused(todo) ? '▶️' : '⏸️'}
+                              </button>
+                            )}
+                            {todo.isRecurring && todo.recurrenceId && (
+                              <button
+                                onClick={() => handleShowHistory(todo)}
+                                className="btn btn-icon"
+                                title="Show history"
+                              >
+                                📊
+                              </button>
+                            )}
                             <button
                               onClick={() => handleDelete(todo.id)}
                               className="btn btn-icon btn-danger"
@@ -1,7 +1,7 @@
 import React, { createContext, useContext, useState, useEffect, ReactNode } from 'react'
 import { PublicClientApplication } from '@azure/msal-browser'
 import { MsalProvider, useMsal, useIsAuthenticated } from '@azure/msal-react'
-import { authConfig, msalConfig, type UserInfo, type MockUser } from './authConfig'
+import { authConfig, msalConfig, type UserInfo } from './authConfig'
 
 interface AuthContextType {
   isAuthenticated: boolean
@@ -88,13 +88,111 @@ function ProdAuthContent({ children }: { children: ReactNode }) {
   )
 }
 
-// Development Auth Provider with mock authentication
+// Development mode signs in with the backend's mock OpenID Connect provider,
+// using the authorization code flow with PKCE as MSAL does against Entra ID
+const DEV_AUTH_REQUEST = 'dev_auth_request'
+
+interface DevAuthRequest {
+  state: string
+  nonce: string
+  codeVerifier: string
+}
+
+function base64UrlEncode(bytes: Uint8Array): string {
+  let binary = ''
+  bytes.forEach(b => { binary += String.fromCharCode(b) })
+  return btoa(binary).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '')
+}
+
+function randomString(): string {
+  return base64UrlEncode(crypto.getRandomValues(new Uint8Array(32)))
+}
+
+// decodeJwtClaims returns a token's claims without verifying it; the backend
+// verifies every token it is sent
+function decodeJwtClaims(token: string): Record<string, unknown> {
+  const payload = token.split('.')[1].replace(/-/g, '+').replace(/_/g, '/')
+  return JSON.parse(atob(payload.padEnd(Math.ceil(payload.length / 4) * 4, '=')))
+}
+
+function devRedirectUri(): string {
+  return `${window.location.origin}/`
+}
+
+let devCallbackHandled = false
+
+function redeemDevAuthCode(params: URLSearchParams): Promise<UserInfo & { token: string }> {
+  const stored = sessionStorage.getItem(DEV_AUTH_REQUEST)
+  sessionStorage.removeItem(DEV_AUTH_REQUEST)
+  const request: DevAuthRequest | null = stored ? JSON.parse(stored) : null
+
+  if (params.get('error')) {
+    return Promise.reject(new Error(params.get('error_description') || params.get('error') || 'Sign-in failed'))
+  }
+  if (!request || params.get('state') !== request.state) {
+    return Promise.reject(new Error('Sign-in response does not match the request'))
+  }
+
+  return fetch('/api/auth/dev/token', {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/x-www-form-urlencoded',
+    },
+    body: new URLSearchParams({
+      grant_type: 'authorization_code',
+      code: params.get('code') || '',
+      redirect_uri: devRedirectUri(),
+      client_id: authConfig?.clientId || '',
+      code_verifier: request.codeVerifier,
+    }),
+  })
+    .then(res => res.json())
+    .then(data => {
+      if (data.error) {
+        throw new Error(data.error_description || data.error)
+      }
+      const claims = decodeJwtClaims(data.id_token)
+      if (claims.nonce !== request.nonce || claims.aud !== authConfig?.clientId) {
+        throw new Error('ID token was not issued for this sign-in')
+      }
+      return {
+        token: data.access_token as string,
+        email: claims.email as string,
+        name: claims.name as string | undefined,
+      }
+    })
+}
+
 function DevAuthProvider({ children }: { children: ReactNode }) {
   const [isAuthenticated, setIsAuthenticated] = useState<boolean>(false)
   const [user, setUser] = useState<UserInfo | null>(null)
   const [accessToken, setAccessToken] = useState<string | null>(null)
 
   useEffect(() => {
+    const params = new URLSearchParams(window.location.search)
+    if (params.has('code') || params.has('error')) {
+      // Returning from the authorize endpoint. The code can only be redeemed
+      // once, even if this effect runs twice.
+      if (devCallbackHandled) return
+      devCallbackHandled = true
+      window.history.replaceState(null, '', window.location.pathname)
+
+      redeemDevAuthCode(params)
+        .then(({ token, ...userInfo }) => {
+          sessionStorage.setItem('dev_access_token', token)
+          sessionStorage.setItem('dev_user', JSON.stringify(userInfo))
+
+          setAccessToken(token)
+          setUser(userInfo)
+          setIsAuthenticated(true)
+        })
+        .catch(error => {
+          console.error('Failed to get dev token:', error)
+          alert('Failed to login. Please try again.')
+        })
+      return
+    }
+
     // Check if we have a token in sessionStorage
     const storedToken = sessionStorage.getItem('dev_access_token')
     const storedUser = sessionStorage.getItem('dev_user')
@@ -106,54 +204,29 @@ function DevAuthProvider({ children }: { children: ReactNode }) {
     }
   }, [])
 
-  const login = () => {
-    // Show a simple prompt to select a user
-    if (!authConfig?.users) return
-
-    const userList = authConfig.users.map((u: MockUser, idx: number) => 
-      `${idx + 1}. ${u.name} (${u.email})`
-    ).join('\n')
+  const login = async () => {
+    // The authorize endpoint shows a page for choosing who to sign in as
+    if (!authConfig) return
 
-    const selection = prompt(`Select a user to login:\n\n${userList}\n\nEnter number (1-${authConfig.users.length}):`)
-    
-    if (!selection) return
-
-    const index = parseInt(selection) - 1
-    if (index >= 0 && index < authConfig.users.length) {
-      const selectedUser = authConfig.users[index]
-      
-      // Make a request to get the token
-      fetch('/api/auth/dev/token', {
-        method: 'POST',
-        headers: {
-          'Content-Type': 'application/x-www-form-urlencoded',
-        },
-        body: new URLSearchParams({
-          grant_type: 'authorization_code',
-          code: 'mock-code',
-          username: selectedUser.sub,
-        }),
-      })
-        .then(res => res.json())
-        .then(data => {
-          const token = data.access_token
-          const userInfo = {
-            email: selectedUser.email,
-            name: selectedUser.name,
-          }
-          
-          sessionStorage.setItem('dev_access_token', token)
-          sessionStorage.setItem('dev_user', JSON.stringify(userInfo))
-          
-          setAccessToken(token)
-          setUser(userInfo)
-          setIsAuthenticated(true)
-        })
-        .catch(error => {
-          console.error('Failed to get dev token:', error)
-          alert('Failed to login. Please try again.')
-        })
+    const request: DevAuthRequest = {
+      state: randomString(),
+      nonce: randomString(),
+      codeVerifier: randomString(),
     }
+    const challenge = await crypto.subtle.digest('SHA-256', new TextEncoder().encode(request.codeVerifier))
+    sessionStorage.setItem(DEV_AUTH_REQUEST, JSON.stringify(request))
+
+    const params = new URLSearchParams({
+      response_type: 'code',
+      client_id: authConfig.clientId,
+      redirect_uri: devRedirectUri(),
+      scope: 'openid profile email',
+      state: request.state,
+      nonce: request.nonce,
+      code_challenge: base64UrlEncode(new Uint8Array(challenge)),
+      code_challenge_method: 'S256',
+    })
+    window.location.assign(`/api/auth/dev/authorize?${params}`)
   }
 
   const logout = () => {
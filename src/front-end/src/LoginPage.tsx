@@ -25,7 +25,7 @@ export default function LoginPage() {
         
         {mode === 'dev' && (
           <p className="dev-hint">
-            Click "Sign In" to select a test user
+            Click "Sign In" to choose a test user or enter any email
           </p>
         )}
       </div>
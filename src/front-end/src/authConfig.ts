@@ -15,6 +15,9 @@ export interface MockUser {
   email: string
   name: string
   sub: string
+  roles?: string[]
+  groups?: string[]
+  claims?: Record<string, unknown>
 }
 
 export interface UserInfo {
@@ -1,9 +1,16 @@
 // Type definitions for the To-Do List application
 
 export interface RecurrencePattern {
-  frequency: 'daily' | 'weekly' | 'monthly'
-  interval: number
+  frequency?: 'daily' | 'weekdays' | 'weekly' | 'monthly' | 'yearly' // weekdays is Monday to Friday
+  interval?: number
   daysOfWeek?: string[]
+  dayOfMonth?: number // Monthly: 1-31 (clamped to shorter months) or -1 for the last day
+  weekOfMonth?: number // Monthly: 1-5 or -1 (last), used with dayOfWeek
+  dayOfWeek?: string
+  activeMonths?: number[] // 1-12; occurrences outside these months are skipped
+  exceptionDates?: string[] // Days whose occurrences were skipped or moved
+  mode?: 'afterCompletion' // Next instance is due interval after the previous one was completed
+  rrule?: string // RFC 5545 RRULE; overrides the fields above when set
 }
 
 export interface TodoItem {
@@ -17,7 +24,14 @@ export interface TodoItem {
   recurrenceId?: number
   dueDate?: string
   completedAt?: string
+  completedBy?: string // Email of the user who completed the item
+  missed?: boolean // The next recurring occurrence fell due before this one was done
+  missedOccurrences?: number // Overdue occurrences folded into this one
   createdAt: string
+  nextInstanceId?: number
+  skipped?: boolean // Recurring occurrence that was skipped rather than done
+  originalDueDate?: string // Scheduled due date of a moved recurring occurrence
+  allDay?: boolean // dueDate is the start of the day the item is due, not a time
 }
 
 export interface RecurringItemDefinition {
@@ -28,17 +42,92 @@ export interface RecurringItemDefinition {
   pattern: RecurrencePattern
   startDate: string
   createdAt: string
+  endDate?: string
+  maxOccurrences?: number // Counted from startDate; absent means unlimited
+  finished: boolean // Every occurrence allowed by endDate/maxOccurrences has been scheduled
+  finishedAt?: string
+  paused: boolean // No instances are generated while paused
+  pausedAt?: string
+  rotation?: AssigneeRotation // Assign each instance to one of assignedTo in turn
+  missedPolicy?: MissedPolicy // Absent to leave missed instances pending, marked missed
+  missedCount: number // Occurrences whose next occurrence fell due before they were done
+  timeZone?: string // IANA time zone the recurrence is evaluated in; absent for the server default
+  allDay?: boolean // Instances are due on a day rather than at a time
+  holidayRegion?: string // Holiday calendar whose holidays occurrences avoid
+  holidayRule?: HolidayRule // Absent to move occurrences on a holiday to the next working day
 }
 
+export type MissedPolicy = 'collapse' | 'catchUp' | 'skip'
+
+export type HolidayRule = 'skip'
+
+// A region's holidays, from GET /api/holidays
+export interface HolidayCalendar {
+  region: string
+  holidays: { date: string; name?: string }[] // Date order, YYYY-MM-DD
+}
+
+export interface AssigneeRotation {
+  mode: 'roundRobin' | 'leastRecent'
+  next?: number // roundRobin: index in assignedTo of the next assignee
+  lastCompleted?: Record<string, string> // When each assignee last completed an instance
+}
+
+// One instance a recurring definition has had, kept after it is deleted
+export interface InstanceRecord {
+  todoId: number
+  recurrenceId: number
+  title: string
+  assignedTo?: string[]
+  dueDate?: string
+  originalDueDate?: string
+  completed: boolean
+  completedAt?: string
+  completedBy?: string
+  skipped?: boolean
+  missed?: boolean
+  missedOccurrences?: number
+  createdAt: string
+  removedAt?: string // When the instance was deleted or unlinked from the definition
+}
+
+export interface RecurringHistory {
+  recurrenceId: number
+  instances: InstanceRecord[] // Oldest occurrence first
+  completed: number
+  skipped: number
+  missed: number
+  currentStreak: number // Occurrences completed in a row up to the latest one due
+  longestStreak: number
+  onTimeRate?: number // 0-1; absent until an occurrence has been completed or missed
+  completedBy?: Record<string, number>
+}
+
+// Which occurrences an edit to a recurring definition applies to
+export type EditScope = 'all' | 'this' | 'following'
+
 export interface FormData {
   title: string
   description: string
   assignedTo: string[]
   currentAssignee: string
   isRecurring: boolean
-  frequency: 'daily' | 'weekly' | 'monthly'
+  frequency: 'daily' | 'weekdays' | 'weekly' | 'monthly' | 'yearly' | 'custom'
   interval: string
   daysOfWeek: string[]
+  rrule: string // Used when frequency is 'custom'
+  monthlyMode: 'startDay' | 'dayOfMonth' | 'lastDay' | 'weekday'
+  dayOfMonth: string
+  weekOfMonth: string
+  dayOfWeek: string
+  activeMonths: number[] // Empty means all year
+  repeatAfterCompletion: boolean
+  endDate: string // Format: YYYY-MM-DD; empty means no end date
+  maxOccurrences: string // Empty means unlimited
+  rotation: '' | AssigneeRotation['mode'] // Empty assigns every instance to everyone
+  missedPolicy: '' | MissedPolicy // Empty leaves missed instances pending
+  holidayRegion: string // Empty for no holiday calendar
+  holidayRule: '' | HolidayRule // Empty moves occurrences on a holiday to the next working day
   dueDate: string // Format: YYYY-MM-DD for date input, converted to ISO 8601 for API
 }
 
@@ -17,21 +17,12 @@ export class TodoHelpers {
       timeout: 10000,
     });
 
-    // Set up dialog handler for dev mode user selection
-    const userMapping: Record<string, string> = {
-      alice: "1",
-      bob: "2",
-      charlie: "3",
-    };
-
-    // Listen for the prompt dialog and respond with the user selection
-    this.page.once("dialog", async (dialog) => {
-      await dialog.accept(userMapping[user]);
-    });
-
     // Click Sign In button
     await this.page.click('button:has-text("Sign In")');
 
+    // Choose the user on the dev mode sign-in page
+    await this.page.click(`button[name="user"][value="${user}"]`);
+
     // Wait for the main app to load
     await this.page.waitForSelector(".todo-table", { timeout: 10000 });
   }
@@ -92,9 +83,19 @@ export class TodoHelpers {
     description?: string;
     assignee?: string;
     isRecurring?: boolean;
-    frequency?: "daily" | "weekly" | "monthly";
+    frequency?: "daily" | "weekdays" | "weekly" | "monthly" | "yearly" | "custom";
     interval?: number;
     daysOfWeek?: string[];
+    rrule?: string;
+    dayOfMonth?: number | "last";
+    weekOfMonth?: "1" | "2" | "3" | "4" | "5" | "-1";
+    dayOfWeek?: string;
+    activeMonths?: string[];
+    repeatAfterCompletion?: boolean;
+    endDate?: string;
+    maxOccurrences?: number;
+    holidayRegion?: string;
+    holidayRule?: "" | "skip";
     dueDate?: string;
   }) {
     // Click "Add New Item" button
@@ -135,13 +136,38 @@ export class TodoHelpers {
       );
 
       // Wait for recurring options to appear
-      await this.page.waitForSelector("select", { timeout: 5000 });
+      await this.page.waitForSelector('select[aria-label="Frequency"]', {
+        timeout: 5000,
+      });
 
       if (data.frequency) {
-        await this.page.selectOption("select", data.frequency);
+        await this.page.selectOption(
+          'select[aria-label="Frequency"]',
+          data.frequency,
+        );
+      }
+
+      if (data.frequency === "monthly") {
+        if (data.dayOfMonth === "last") {
+          await this.page.selectOption('select[aria-label="Day of month"]', "lastDay");
+        } else if (data.dayOfMonth) {
+          await this.page.selectOption('select[aria-label="Day of month"]', "dayOfMonth");
+          await this.page.fill('input[aria-label="Day"]', data.dayOfMonth.toString());
+        } else if (data.weekOfMonth && data.dayOfWeek) {
+          await this.page.selectOption('select[aria-label="Day of month"]', "weekday");
+          await this.page.selectOption('select[aria-label="Week of month"]', data.weekOfMonth);
+          await this.page.selectOption('select[aria-label="Weekday"]', data.dayOfWeek);
+        }
       }
 
-      if (data.interval) {
+      if (data.rrule && data.frequency === "custom") {
+        await this.page.fill(
+          'input[placeholder="e.g. FREQ=MONTHLY;BYDAY=-1FR"]',
+          data.rrule,
+        );
+      }
+
+      if (data.interval && data.frequency !== "custom") {
         await this.page.fill(
           'input[type="number"][placeholder="Interval"]',
           data.interval.toString(),
@@ -157,6 +183,34 @@ export class TodoHelpers {
           );
         }
       }
+
+      if (data.activeMonths) {
+        for (const month of data.activeMonths) {
+          await this.page.check(`input[aria-label="Active in ${month}"]`);
+        }
+      }
+
+      if (data.repeatAfterCompletion) {
+        await this.page.check(
+          'label:has-text("Repeat after completion") input[type="checkbox"]',
+        );
+      }
+
+      if (data.endDate) {
+        await this.page.fill('input[aria-label="End date"]', data.endDate);
+      }
+
+      if (data.maxOccurrences) {
+        await this.page.fill(
+          'input[aria-label="Occurrences"]',
+          data.maxOccurrences.toString(),
+        );
+      }
+
+      if (data.holidayRegion) {
+        await this.page.selectOption('select[aria-label="Holiday calendar"]', data.holidayRegion);
+        await this.page.selectOption('select[aria-label="On a holiday"]', data.holidayRule ?? "");
+      }
     } else if (data.dueDate) {
       await this.page.fill('input[type="date"]', data.dueDate);
     }
@@ -321,6 +375,56 @@ export class TodoHelpers {
     await this.page.waitForTimeout(300);
   }
 
+  /**
+   * Skip the pending occurrence of a recurring item
+   */
+  async skipOccurrence(itemTitle: string) {
+    const row = this.page
+      .locator("tr", { has: this.page.locator(`text="${itemTitle}"`) })
+      .first();
+
+    await row.locator('button[title="Skip this occurrence"]').click();
+
+    await this.page
+      .waitForLoadState("networkidle", { timeout: 3000 })
+      .catch(() => {});
+    await this.page.waitForTimeout(300);
+  }
+
+  /**
+   * Pause or resume the recurring item behind a row
+   */
+  async togglePaused(itemTitle: string) {
+    const row = this.page
+      .locator("tr", { has: this.page.locator(`text="${itemTitle}"`) })
+      .first();
+
+    await row
+      .locator('button[title="Pause recurring item"], button[title="Resume recurring item"]')
+      .click();
+
+    await this.page
+      .waitForLoadState("networkidle", { timeout: 3000 })
+      .catch(() => {});
+    await this.page.waitForTimeout(300);
+  }
+
+  /**
+   * Show the history and streaks of the recurring item behind a row
+   */
+  async showHistory(itemTitle: string) {
+    const row = this.page
+      .locator("tr", { has: this.page.locator(`text="${itemTitle}"`) })
+      .first();
+
+    await row.locator('button[title="Show history"]').click();
+
+    await this.page
+      .waitForLoadState("networkidle", { timeout: 3000 })
+      .catch(() => {});
+    await this.page.waitForTimeout(300);
+  }
+
   /**
    * Check if item is completed
    */
@@ -416,6 +520,33 @@ export class TodoHelpers {
     await this.page.waitForTimeout(500);
   }
 
+  /**
+   * Change the server's dev clock, e.g. { freeze: "2030-01-09T12:00:00Z" },
+   * { advance: "168h" } or { reset: true }
+   */
+  async setServerClock(request: {
+    reset?: boolean;
+    freeze?: string;
+    unfreeze?: boolean;
+    advance?: string;
+    runScheduler?: boolean;
+  }) {
+    await this.page.evaluate(async (body) => {
+      const token = sessionStorage.getItem("dev_access_token");
+      const response = await fetch("/api/dev/clock", {
+        method: "POST",
+        headers: {
+          Authorization: `Bearer ${token}`,
+          "Content-Type": "application/json",
+        },
+        body: JSON.stringify(body),
+      });
+      if (!response.ok) {
+        throw new Error(`Setting the server clock failed: ${await response.text()}`);
+      }
+    }, request);
+  }
+
   /**
    * Clear all to-do items (for cleanup)
    */
@@ -23,4 +23,13 @@ test.describe('Smoke Tests', () => {
     await expect(page.locator('.quick-add-row')).toBeVisible();
     await expect(page.locator('input[placeholder="Type to add new item..."]')).toBeVisible();
   });
+
+  test('should sign in as the user chosen on the sign-in page', async ({ page }) => {
+    const helpers = new TodoHelpers(page);
+    await helpers.navigateAndLogin('bob');
+
+    // The chosen identity is the one signed in, and the code is gone from the URL
+    await expect(page.locator('.user-email')).toHaveText('bob@example.com');
+    expect(new URL(page.url()).search).toBe('');
+  });
 });
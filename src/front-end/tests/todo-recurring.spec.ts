@@ -7,6 +7,7 @@ test.describe('Recurring To-Do Items', () => {
   test.beforeEach(async ({ page }) => {
     helpers = new TodoHelpers(page);
     await helpers.navigateAndLogin('alice');
+    await helpers.setServerClock({ reset: true });
     await helpers.clearAllTodos();
   });
 
@@ -59,6 +60,445 @@ test.describe('Recurring To-Do Items', () => {
     expect(await helpers.hasRecurringBadge('Monthly report')).toBe(true);
   });
 
+  test('should create a monthly item on the last Friday of the month', async ({ page }) => {
+    await helpers.addTodoWithForm({
+      title: 'Bin night',
+      isRecurring: true,
+      frequency: 'monthly',
+      interval: 1,
+      weekOfMonth: '-1',
+      dayOfWeek: 'Friday'
+    });
+
+    await expect(page.locator('text=Bin night').first()).toBeVisible();
+
+    // The first instance is due on a Friday in the last week of its month
+    // (the backend evaluates the rule in UTC, the start date's zone)
+    const dueDate = await page.evaluate(async () => {
+      const token = sessionStorage.getItem('dev_access_token');
+      const response = await fetch('/api/todos', { headers: { Authorization: `Bearer ${token}` } });
+      const todos: { title: string; dueDate: string }[] = await response.json();
+      return todos.find((todo) => todo.title === 'Bin night')?.dueDate;
+    });
+    expect(dueDate).toBeTruthy();
+    const due = new Date(dueDate!);
+    expect(due.getUTCDay()).toBe(5);
+    const sameWeekdayNextWeek = new Date(due);
+    sameWeekdayNextWeek.setUTCDate(due.getUTCDate() + 7);
+    expect(sameWeekdayNextWeek.getUTCMonth()).not.toBe(due.getUTCMonth());
+
+    // The rule is loaded back into the form when editing the definition
+    await helpers.editRecurringDefinition('Bin night');
+    await expect(page.locator('select[aria-label="Day of month"]')).toHaveValue('weekday');
+    await expect(page.locator('select[aria-label="Week of month"]')).toHaveValue('-1');
+    await expect(page.locator('select[aria-label="Weekday"]')).toHaveValue('Friday');
+  });
+
+  test('should create a monthly item on the last day of the month', async ({ page }) => {
+    await helpers.addTodoWithForm({
+      title: 'Pay credit card',
+      isRecurring: true,
+      frequency: 'monthly',
+      interval: 1,
+      dayOfMonth: 'last'
+    });
+
+    await expect(page.locator('text=Pay credit card').first()).toBeVisible();
+
+    await helpers.editRecurringDefinition('Pay credit card');
+    await expect(page.locator('select[aria-label="Day of month"]')).toHaveValue('lastDay');
+  });
+
+  test('should create a yearly recurring item', async ({ page }) => {
+    await helpers.addTodoWithForm({
+      title: 'Renew car insurance',
+      isRecurring: true,
+      frequency: 'yearly',
+      interval: 1
+    });
+
+    await expect(page.locator('text=Renew car insurance').first()).toBeVisible();
+    expect(await helpers.hasRecurringBadge('Renew car insurance')).toBe(true);
+
+    await helpers.editRecurringDefinition('Renew car insurance');
+    await expect(page.locator('select[aria-label="Frequency"]')).toHaveValue('yearly');
+  });
+
+  test('should only schedule a seasonal item in its active months', async ({ page }) => {
+    const activeMonths = ['April', 'May', 'June', 'July', 'August', 'September', 'October'];
+    await helpers.addTodoWithForm({
+      title: 'Mow the lawn',
+      isRecurring: true,
+      frequency: 'weekly',
+      interval: 1,
+      daysOfWeek: ['Saturday'],
+      activeMonths
+    });
+
+    await expect(page.locator('text=Mow the lawn').first()).toBeVisible();
+
+    // Every instance falls on a Saturday between April and October
+    const dueDates = await page.evaluate(async () => {
+      const token = sessionStorage.getItem('dev_access_token');
+      const response = await fetch('/api/todos', { headers: { Authorization: `Bearer ${token}` } });
+      const todos: { title: string; dueDate?: string }[] = await response.json();
+      return todos.filter((todo) => todo.title === 'Mow the lawn' && todo.dueDate).map((todo) => todo.dueDate!);
+    });
+    expect(dueDates.length).toBeGreaterThan(0);
+    for (const dueDate of dueDates) {
+      const due = new Date(dueDate);
+      expect(due.getUTCDay()).toBe(6);
+      expect(due.getUTCMonth() + 1).toBeGreaterThanOrEqual(4);
+      expect(due.getUTCMonth() + 1).toBeLessThanOrEqual(10);
+    }
+
+    // The window is loaded back into the form when editing the definition
+    await helpers.editRecurringDefinition('Mow the lawn');
+    for (const month of activeMonths) {
+      await expect(page.locator(`input[aria-label="Active in ${month}"]`)).toBeChecked();
+    }
+    await expect(page.locator('input[aria-label="Active in January"]')).not.toBeChecked();
+  });
+
+  test('should finish a recurring item once its occurrences run out', async ({ page }) => {
+    await helpers.addTodoWithForm({
+      title: 'Physio exercises',
+      isRecurring: true,
+      frequency: 'daily',
+      interval: 1,
+      maxOccurrences: 1
+    });
+
+    // The only occurrence is scheduled, so the definition is finished
+    const row = page.locator('tr', { has: page.locator('text="Physio exercises"') }).first();
+    await expect(row.locator('.series-ended')).toBeVisible();
+
+    const definition = await page.evaluate(async () => {
+      const token = sessionStorage.getItem('dev_access_token');
+      const response = await fetch('/api/recurring', { headers: { Authorization: `Bearer ${token}` } });
+      const defs: { title: string; maxOccurrences?: number; finished: boolean }[] = await response.json();
+      return defs.find((def) => def.title === 'Physio exercises');
+    });
+    expect(definition?.maxOccurrences).toBe(1);
+    expect(definition?.finished).toBe(true);
+
+    await helpers.editRecurringDefinition('Physio exercises');
+    await expect(page.locator('input[aria-label="Occurrences"]')).toHaveValue('1');
+    await page.click('button:has-text("Cancel")');
+
+    // Completing it does not create another occurrence
+    await helpers.toggleComplete('Physio exercises');
+    await page.waitForTimeout(500);
+    await expect(page.locator('text="Physio exercises"')).toHaveCount(1);
+  });
+
+  test('should schedule the next instance from when the last one was completed', async ({ page }) => {
+    await helpers.addTodoWithForm({
+      title: 'Change the filter',
+      isRecurring: true,
+      frequency: 'daily',
+      interval: 5,
+      repeatAfterCompletion: true
+    });
+
+    await expect(page.locator('text=Change the filter').first()).toBeVisible();
+
+    await helpers.editRecurringDefinition('Change the filter');
+    await expect(page.locator('label:has-text("Repeat after completion") input[type="checkbox"]')).toBeChecked();
+    await page.click('button:has-text("Cancel")');
+
+    await helpers.toggleComplete('Change the filter');
+    await expect(page.locator('text="Change the filter"')).toHaveCount(2, { timeout: 5000 });
+
+    // The next instance is due five days after the first was completed
+    const instances = await page.evaluate(async () => {
+      const token = sessionStorage.getItem('dev_access_token');
+      const response = await fetch('/api/todos', { headers: { Authorization: `Bearer ${token}` } });
+      const todos: { title: string; completed: boolean; completedAt?: string; dueDate?: string }[] = await response.json();
+      return todos.filter((todo) => todo.title === 'Change the filter');
+    });
+    const completed = instances.find((todo) => todo.completed);
+    const next = instances.find((todo) => !todo.completed);
+    expect(completed?.completedAt).toBeTruthy();
+    expect(next?.dueDate).toBeTruthy();
+    const days = (new Date(next!.dueDate!).getTime() - new Date(completed!.completedAt!).getTime()) / (24 * 60 * 60 * 1000);
+    // Allow for a daylight saving change in the server's time zone
+    expect(Math.abs(days - 5)).toBeLessThan(0.05);
+  });
+
+  test('should skip one occurrence and keep a record of it', async ({ page }) => {
+    await helpers.addTodoWithForm({
+      title: 'Take out the bins',
+      isRecurring: true,
+      frequency: 'weekly',
+      interval: 1
+    });
+
+    await helpers.skipOccurrence('Take out the bins');
+
+    // The skipped occurrence is kept and the next one is created
+    await expect(page.locator('text="Take out the bins"')).toHaveCount(2, { timeout: 5000 });
+    await expect(page.locator('.skipped-label')).toHaveCount(1);
+
+    const state = await page.evaluate(async () => {
+      const token = sessionStorage.getItem('dev_access_token');
+      const headers = { Authorization: `Bearer ${token}` };
+      const todos: { title: string; skipped?: boolean; completed: boolean }[] =
+        await (await fetch('/api/todos', { headers })).json();
+      const defs: { title: string; pattern: { exceptionDates?: string[] } }[] =
+        await (await fetch('/api/recurring', { headers })).json();
+      return {
+        instances: todos.filter((todo) => todo.title === 'Take out the bins'),
+        exceptionDates: defs.find((def) => def.title === 'Take out the bins')?.pattern.exceptionDates,
+      };
+    });
+    expect(state.instances.filter((todo) => todo.skipped && todo.completed)).toHaveLength(1);
+    expect(state.instances.filter((todo) => !todo.completed)).toHaveLength(1);
+    expect(state.exceptionDates).toHaveLength(1);
+  });
+
+  test('should pause and resume a recurring item', async ({ page }) => {
+    await helpers.addTodoWithForm({
+      title: 'Water the garden',
+      isRecurring: true,
+      frequency: 'daily',
+      interval: 1
+    });
+
+    await helpers.togglePaused('Water the garden');
+    await expect(page.locator('.paused-label')).toHaveCount(1, { timeout: 5000 });
+
+    // Completing an instance of a paused item does not create the next one
+    await helpers.toggleComplete('Water the garden');
+    await expect(page.locator('text="Water the garden"')).toHaveCount(1);
+
+    // Resuming picks the series up again
+    await helpers.togglePaused('Water the garden');
+    await expect(page.locator('.paused-label')).toHaveCount(0, { timeout: 5000 });
+    await expect(page.locator('text="Water the garden"')).toHaveCount(2, { timeout: 5000 });
+  });
+
+  test('should keep the history of a recurring item after its instances are deleted', async ({ page }) => {
+    await helpers.addTodoWithForm({
+      title: 'Feed the fish',
+      isRecurring: true,
+      frequency: 'daily',
+      interval: 1
+    });
+
+    // Complete the first instance, then delete it
+    await helpers.toggleComplete('Feed the fish');
+    await expect(page.locator('text="Feed the fish"')).toHaveCount(2, { timeout: 5000 });
+    const completedId = await page.evaluate(async () => {
+      const token = sessionStorage.getItem('dev_access_token');
+      const todos: { id: number; title: string; completed: boolean }[] =
+        await (await fetch('/api/todos', { headers: { Authorization: `Bearer ${token}` } })).json();
+      return todos.find((todo) => todo.title === 'Feed the fish' && todo.completed)?.id;
+    });
+    await page.evaluate(async (id) => {
+      const token = sessionStorage.getItem('dev_access_token');
+      await fetch(`/api/todos/${id}`, { method: 'DELETE', headers: { Authorization: `Bearer ${token}` } });
+    }, completedId);
+    await page.reload();
+    await expect(page.locator('text="Feed the fish"')).toHaveCount(1, { timeout: 5000 });
+
+    // The completion still counts towards the streak
+    await helpers.showHistory('Feed the fish');
+    await expect(page.locator('.recurring-history')).toContainText('Current streak: 1', { timeout: 5000 });
+    await expect(page.locator('.recurring-history')).toContainText('1 done');
+  });
+
+  test('should schedule next week\'s instance after the server clock moves on', async ({ page }) => {
+    // Freeze the server on a Wednesday so the Monday due dates are known
+    await helpers.setServerClock({ freeze: '2030-01-09T12:00:00Z' });
+    await helpers.addTodoWithForm({
+      title: 'Mop the kitchen',
+      isRecurring: true,
+      frequency: 'weekly',
+      interval: 1,
+      daysOfWeek: ['Monday']
+    });
+
+    // Completing the first instance two days late creates the following week's
+    await helpers.setServerClock({ advance: '168h' });
+    await helpers.toggleComplete('Mop the kitchen');
+    await expect(page.locator('text="Mop the kitchen"')).toHaveCount(2, { timeout: 5000 });
+
+    const dueDates = await page.evaluate(async () => {
+      const token = sessionStorage.getItem('dev_access_token');
+      const todos: { title: string; dueDate: string }[] =
+        await (await fetch('/api/todos', { headers: { Authorization: `Bearer ${token}` } })).json();
+      return todos
+        .filter((todo) => todo.title === 'Mop the kitchen')
+        .map((todo) => new Date(todo.dueDate).toLocaleDateString('en-CA'))
+        .sort();
+    });
+    expect(dueDates).toEqual(['2030-01-14', '2030-01-21']);
+
+    await helpers.setServerClock({ reset: true });
+  });
+
+  test('should skip holidays and weekends for an every-weekday item', async ({ page }) => {
+    // The e2e calendar makes Wednesday 9 and Thursday 10 January 2030 holidays
+    await helpers.setServerClock({ freeze: '2030-01-09T12:00:00Z' });
+    await helpers.addTodoWithForm({
+      title: 'Check the support queue',
+      isRecurring: true,
+      frequency: 'weekdays',
+      interval: 1,
+      holidayRegion: 'e2e',
+      holidayRule: 'skip'
+    });
+
+    // The first working day is Friday, and the next the following Monday
+    await helpers.toggleComplete('Check the support queue');
+    await expect(page.locator('text="Check the support queue"')).toHaveCount(2, { timeout: 5000 });
+
+    const dueDates = await page.evaluate(async () => {
+      const token = sessionStorage.getItem('dev_access_token');
+      const todos: { title: string; dueDate: string }[] =
+        await (await fetch('/api/todos', { headers: { Authorization: `Bearer ${token}` } })).json();
+      return todos
+        .filter((todo) => todo.title === 'Check the support queue')
+        .map((todo) => new Date(todo.dueDate).toLocaleDateString('en-CA'))
+        .sort();
+    });
+    expect(dueDates).toEqual(['2030-01-11', '2030-01-14']);
+
+    await helpers.setServerClock({ reset: true });
+  });
+
+  test('should split a recurring item when editing this and following occurrences', async ({ page }) => {
+    await helpers.addTodoWithForm({
+      title: 'Vacuum the stairs',
+      isRecurring: true,
+      frequency: 'weekly',
+      interval: 1
+    });
+
+    await helpers.editRecurringDefinition('Vacuum the stairs');
+    await page.fill('input[placeholder="Title"]', 'Vacuum the stairs fortnightly');
+    await page.selectOption('select[aria-label="Apply changes to"]', 'following');
+    await page.click('button[type="submit"]');
+
+    await expect(page.locator('text="Vacuum the stairs fortnightly"')).toHaveCount(1, { timeout: 5000 });
+
+    // The original series ends where the new one begins
+    const defs = await page.evaluate(async () => {
+      const token = sessionStorage.getItem('dev_access_token');
+      const response = await fetch('/api/recurring', { headers: { Authorization: `Bearer ${token}` } });
+      return (await response.json()) as { title: string; endDate?: string }[];
+    });
+    expect(defs.map((def) => def.title).sort()).toEqual(['Vacuum the stairs', 'Vacuum the stairs fortnightly']);
+    expect(defs.find((def) => def.title === 'Vacuum the stairs')?.endDate).toBeDefined();
+  });
+
+  test('should rotate a recurring chore between assignees', async ({ page }) => {
+    await page.click('button:has-text("Add New Item")');
+    await page.fill('input[placeholder="Title"]', 'Empty the dishwasher');
+    for (const person of ['alice', 'bob']) {
+      await page.fill('input[placeholder="Add assignee (press Enter)"]', person);
+      await page.press('input[placeholder="Add assignee (press Enter)"]', 'Enter');
+    }
+    await page.check('label:has-text("Make this a recurring item") input[type="checkbox"]');
+    await page.selectOption('select[aria-label="Frequency"]', 'daily');
+    await page.selectOption('select[aria-label="Rotation"]', 'roundRobin');
+    await page.click('button[type="submit"]');
+
+    await expect(page.locator('text="Empty the dishwasher"').first()).toBeVisible({ timeout: 5000 });
+
+    // Each instance goes to one person, taking turns, and the turn is saved
+    const state = await page.evaluate(async () => {
+      const token = sessionStorage.getItem('dev_access_token');
+      const headers = { Authorization: `Bearer ${token}` };
+      const todos: { title: string; assignedTo: string[]; dueDate: string }[] =
+        await (await fetch('/api/todos', { headers })).json();
+      const defs: { title: string; rotation?: { mode: string; next: number } }[] =
+        await (await fetch('/api/recurring', { headers })).json();
+      return {
+        assignees: todos
+          .filter((todo) => todo.title === 'Empty the dishwasher')
+          .sort((a, b) => a.dueDate.localeCompare(b.dueDate))
+          .map((todo) => todo.assignedTo),
+        rotation: defs.find((def) => def.title === 'Empty the dishwasher')?.rotation,
+      };
+    });
+    expect(state.assignees[0]).toEqual(['alice']);
+    if (state.assignees.length > 1) {
+      expect(state.assignees[1]).toEqual(['bob']);
+    }
+    expect(state.rotation?.mode).toBe('roundRobin');
+  });
+
+  test('should preview upcoming occurrences before saving', async ({ page }) => {
+    await page.click('button:has-text("Add New Item")');
+    await page.fill('input[placeholder="Title"]', 'Stretch');
+    await page.check('label:has-text("Make this a recurring item") input[type="checkbox"]');
+    await page.selectOption('select[aria-label="Frequency"]', 'daily');
+
+    const preview = page.locator('.recurrence-preview');
+    await expect(preview).toContainText('Next:', { timeout: 5000 });
+
+    // A mistaken RRULE is reported instead
+    await page.selectOption('select[aria-label="Frequency"]', 'custom');
+    await page.fill('input[placeholder="e.g. FREQ=MONTHLY;BYDAY=-1FR"]', 'FREQ=SOMETIMES');
+    await expect(page.locator('.recurrence-preview-error')).toBeVisible({ timeout: 5000 });
+
+    // The same dates are available for a saved definition
+    const occurrences = await page.evaluate(async () => {
+      const token = sessionStorage.getItem('dev_access_token');
+      const headers = { Authorization: `Bearer ${token}`, 'Content-Type': 'application/json' };
+      const response = await fetch('/api/recurring/preview', {
+        method: 'POST',
+        headers,
+        body: JSON.stringify({ pattern: { frequency: 'weekly', interval: 1, daysOfWeek: ['Monday', 'Thursday'] }, count: 3 }),
+      });
+      return (await response.json()).occurrences as string[];
+    });
+    expect(occurrences).toHaveLength(3);
+    expect(occurrences.map((value) => new Date(value).getDay()).every((day) => day === 1 || day === 4)).toBe(true);
+  });
+
+  test('should create a recurring to-do item from an RRULE', async ({ page }) => {
+    await helpers.addTodoWithForm({
+      title: 'Pay rent',
+      isRecurring: true,
+      frequency: 'custom',
+      rrule: 'FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1'
+    });
+
+    await expect(page.locator('text=Pay rent').first()).toBeVisible();
+    expect(await helpers.hasRecurringBadge('Pay rent')).toBe(true);
+
+    // The rule is loaded back into the form when editing the definition
+    await helpers.editRecurringDefinition('Pay rent');
+    await expect(page.locator('select[aria-label="Frequency"]')).toHaveValue('custom');
+    await expect(page.locator('input[placeholder="e.g. FREQ=MONTHLY;BYDAY=-1FR"]'))
+      .toHaveValue('FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1');
+  });
+
+  test('should create the next instance when a recurring item is completed', async () => {
+    await helpers.addTodoWithForm({
+      title: 'Water plants',
+      isRecurring: true,
+      frequency: 'daily',
+      interval: 1
+    });
+
+    const countInstances = async () =>
+      (await helpers.getTodoTitles()).filter((title) => title === 'Water plants').length;
+    expect(await countInstances()).toBe(1);
+
+    // Completing the instance creates the next occurrence
+    await helpers.toggleComplete('Water plants');
+    await expect.poll(countInstances).toBe(2);
+
+    // Un-completing and completing again must not create a duplicate
+    await helpers.toggleComplete('Water plants');
+    await helpers.toggleComplete('Water plants');
+    expect(await countInstances()).toBe(2);
+  });
+
   test('should edit a recurring instance without affecting definition', async ({ page }) => {
     // Create recurring item
     await helpers.addTodoWithForm({